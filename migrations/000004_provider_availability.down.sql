@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+
+DROP TABLE IF EXISTS provider_blackouts;
+DROP TABLE IF EXISTS provider_working_hours;
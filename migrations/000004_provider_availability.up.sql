@@ -0,0 +1,30 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+
+CREATE TABLE provider_working_hours (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider_id UUID NOT NULL REFERENCES service_providers(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday >= 0 AND weekday <= 6),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL CHECK (end_time > start_time)
+);
+
+CREATE INDEX idx_provider_working_hours_provider_id ON provider_working_hours(provider_id, weekday);
+
+
+CREATE TABLE provider_blackouts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider_id UUID NOT NULL REFERENCES service_providers(id) ON DELETE CASCADE,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL CHECK (ends_at > starts_at),
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_provider_blackouts_provider_id ON provider_blackouts(provider_id, starts_at);
+
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+    provider_id WITH =,
+    tsrange(scheduled_date, scheduled_date + duration_hours * INTERVAL '1 hour') WITH &&
+) WHERE (status IN ('pending', 'accepted'));
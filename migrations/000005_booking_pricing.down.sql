@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS currency;
+ALTER TABLE bookings DROP COLUMN IF EXISTS hourly_rate;
+
+ALTER TABLE provider_services DROP COLUMN IF EXISTS price;
@@ -0,0 +1,5 @@
+ALTER TABLE provider_services ADD COLUMN price DECIMAL(10, 2) CHECK (price >= 0);
+
+
+ALTER TABLE bookings ADD COLUMN hourly_rate DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE bookings ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'KZT';
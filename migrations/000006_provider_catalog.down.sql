@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_provider_services_updated_at ON provider_services;
+
+ALTER TABLE provider_services DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE provider_services DROP COLUMN IF EXISTS created_at;
+ALTER TABLE provider_services DROP COLUMN IF EXISTS description;
+ALTER TABLE provider_services DROP COLUMN IF EXISTS title;
+
+DROP INDEX IF EXISTS idx_services_category;
+ALTER TABLE services DROP COLUMN IF EXISTS category;
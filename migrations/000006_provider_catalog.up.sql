@@ -0,0 +1,18 @@
+ALTER TABLE services ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_services_category ON services(category);
+
+UPDATE services SET category = 'cleaning' WHERE name IN ('Home Cleaning');
+UPDATE services SET category = 'repair' WHERE name IN ('Plumbing', 'Electrical', 'HVAC', 'Appliance Repair', 'General Handyman');
+UPDATE services SET category = 'renovation' WHERE name IN ('Painting', 'Carpentry', 'Roofing');
+UPDATE services SET category = 'outdoor' WHERE name IN ('Landscaping', 'Pest Control');
+UPDATE services SET category = 'moving' WHERE name IN ('Moving & Storage');
+
+
+ALTER TABLE provider_services ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE provider_services ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE provider_services ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE provider_services ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE TRIGGER update_provider_services_updated_at BEFORE UPDATE ON provider_services
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
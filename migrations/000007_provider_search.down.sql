@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_service_providers_location_lower;
+DROP INDEX IF EXISTS idx_service_providers_experience;
+DROP INDEX IF EXISTS idx_service_providers_hourly_rate;
+DROP INDEX IF EXISTS idx_service_providers_search_vector;
+
+DROP TRIGGER IF EXISTS update_users_provider_search_vector ON users;
+DROP TRIGGER IF EXISTS update_service_providers_search_vector ON service_providers;
+
+DROP FUNCTION IF EXISTS update_users_provider_search_vector();
+DROP FUNCTION IF EXISTS update_service_providers_search_vector();
+DROP FUNCTION IF EXISTS provider_search_document(TEXT, TEXT, TEXT);
+
+ALTER TABLE service_providers DROP COLUMN IF EXISTS search_vector;
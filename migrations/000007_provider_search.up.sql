@@ -0,0 +1,51 @@
+ALTER TABLE service_providers ADD COLUMN search_vector tsvector;
+
+
+CREATE OR REPLACE FUNCTION provider_search_document(full_name TEXT, bio TEXT, location TEXT)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('simple', COALESCE(full_name, '')), 'A') ||
+           setweight(to_tsvector('simple', COALESCE(location, '')), 'B') ||
+           setweight(to_tsvector('simple', COALESCE(bio, '')), 'C');
+$$ LANGUAGE sql IMMUTABLE;
+
+
+CREATE OR REPLACE FUNCTION update_service_providers_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector = provider_search_document(
+        (SELECT full_name FROM users WHERE id = NEW.user_id),
+        NEW.bio,
+        NEW.location
+    );
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_service_providers_search_vector BEFORE INSERT OR UPDATE OF bio, location, user_id ON service_providers
+    FOR EACH ROW EXECUTE FUNCTION update_service_providers_search_vector();
+
+
+CREATE OR REPLACE FUNCTION update_users_provider_search_vector()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE service_providers
+    SET search_vector = provider_search_document(NEW.full_name, bio, location)
+    WHERE user_id = NEW.id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER update_users_provider_search_vector AFTER UPDATE OF full_name ON users
+    FOR EACH ROW EXECUTE FUNCTION update_users_provider_search_vector();
+
+
+UPDATE service_providers sp
+SET search_vector = provider_search_document(u.full_name, sp.bio, sp.location)
+FROM users u
+WHERE u.id = sp.user_id;
+
+
+CREATE INDEX idx_service_providers_search_vector ON service_providers USING gin(search_vector);
+CREATE INDEX idx_service_providers_hourly_rate ON service_providers(hourly_rate);
+CREATE INDEX idx_service_providers_experience ON service_providers(experience_years);
+CREATE INDEX idx_service_providers_location_lower ON service_providers(LOWER(location));
@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS haversine_km(DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION);
+
+DROP INDEX IF EXISTS idx_service_providers_coordinates;
+
+ALTER TABLE service_providers DROP CONSTRAINT IF EXISTS service_providers_coordinates_pair;
+ALTER TABLE service_providers DROP COLUMN IF EXISTS longitude;
+ALTER TABLE service_providers DROP COLUMN IF EXISTS latitude;
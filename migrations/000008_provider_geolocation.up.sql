@@ -0,0 +1,15 @@
+ALTER TABLE service_providers ADD COLUMN latitude DOUBLE PRECISION CHECK (latitude >= -90 AND latitude <= 90);
+ALTER TABLE service_providers ADD COLUMN longitude DOUBLE PRECISION CHECK (longitude >= -180 AND longitude <= 180);
+ALTER TABLE service_providers ADD CONSTRAINT service_providers_coordinates_pair
+    CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+CREATE INDEX idx_service_providers_coordinates ON service_providers(latitude, longitude);
+
+
+CREATE OR REPLACE FUNCTION haversine_km(lat1 DOUBLE PRECISION, lng1 DOUBLE PRECISION, lat2 DOUBLE PRECISION, lng2 DOUBLE PRECISION)
+RETURNS DOUBLE PRECISION AS $$
+    SELECT 2 * 6371.0088 * asin(LEAST(1.0, sqrt(
+        power(sin(radians(lat2 - lat1) / 2), 2) +
+        cos(radians(lat1)) * cos(radians(lat2)) * power(sin(radians(lng2 - lng1) / 2), 2)
+    )));
+$$ LANGUAGE sql IMMUTABLE STRICT;
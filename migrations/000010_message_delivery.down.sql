@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_messages_unread;
+DROP INDEX IF EXISTS idx_messages_undelivered;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS read_at,
+    DROP COLUMN IF EXISTS delivered_at,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,7 @@
+ALTER TABLE messages
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'sent' CHECK (status IN ('sent', 'delivered', 'read')),
+    ADD COLUMN delivered_at TIMESTAMP,
+    ADD COLUMN read_at TIMESTAMP;
+
+CREATE INDEX idx_messages_undelivered ON messages(receiver_id, created_at) WHERE status = 'sent';
+CREATE INDEX idx_messages_unread ON messages(receiver_id, sender_id) WHERE status <> 'read';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_booking_id;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS kind,
+    DROP COLUMN IF EXISTS booking_id;
@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    ADD COLUMN booking_id UUID REFERENCES bookings(id) ON DELETE SET NULL,
+    ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT 'user' CHECK (kind IN ('user', 'system'));
+
+CREATE INDEX idx_messages_booking_id ON messages(booking_id, created_at DESC) WHERE booking_id IS NOT NULL;
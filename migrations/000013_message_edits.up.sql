@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    ADD COLUMN edited_at TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reports;
+DROP TYPE IF EXISTS report_status;
+DROP TYPE IF EXISTS report_target;
+DROP TABLE IF EXISTS user_blocks;
@@ -0,0 +1,32 @@
+CREATE TABLE user_blocks (
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CHECK (blocker_id <> blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+
+CREATE TYPE report_target AS ENUM ('user', 'message', 'review');
+CREATE TYPE report_status AS ENUM ('open', 'resolved', 'dismissed');
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type report_target NOT NULL,
+    target_id UUID NOT NULL,
+    reported_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(50) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status report_status NOT NULL DEFAULT 'open',
+    resolution_note TEXT NOT NULL DEFAULT '',
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+CREATE INDEX idx_reports_reported_user_id ON reports(reported_user_id);
+CREATE UNIQUE INDEX idx_reports_open_once ON reports(reporter_id, target_type, target_id) WHERE status = 'open';
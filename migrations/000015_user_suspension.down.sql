@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_suspended_at;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS suspension_reason,
+    DROP COLUMN IF EXISTS suspended_at;
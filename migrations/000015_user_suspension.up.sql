@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMP,
+    ADD COLUMN suspension_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_users_suspended_at ON users(suspended_at) WHERE suspended_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS login_attempts;
+DROP TYPE IF EXISTS login_outcome;
@@ -0,0 +1,14 @@
+CREATE TYPE login_outcome AS ENUM ('success', 'invalid_credentials', 'throttled', 'suspended');
+
+CREATE TABLE login_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    email VARCHAR(255) NOT NULL,
+    ip_address TEXT NOT NULL DEFAULT '',
+    outcome login_outcome NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created_at);
+CREATE INDEX idx_login_attempts_ip_address ON login_attempts(ip_address, created_at);
+CREATE INDEX idx_login_attempts_user_id ON login_attempts(user_id) WHERE user_id IS NOT NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE event_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    routing_key VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP,
+    failed_at TIMESTAMP
+);
+
+CREATE INDEX idx_event_outbox_pending ON event_outbox(created_at) WHERE published_at IS NULL AND failed_at IS NULL;
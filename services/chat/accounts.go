@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"qasynda/shared/pkg/models"
+)
+
+var (
+	ErrAccountSuspended = errors.New("account suspended")
+	errAccountInvalid   = errors.New("account no longer valid")
+)
+
+// AccountClient asks the user service whether the account behind a token may
+// still be used. Tokens are verified locally, so without it a suspended user
+// could keep chatting until their token expired. It is only consulted when a
+// socket authenticates or renews its token, so answers are not cached.
+type AccountClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewAccountClient(baseURL string) *AccountClient {
+	return &AccountClient{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}
+}
+
+// Check returns ErrAccountSuspended or errAccountInvalid for accounts that
+// must be turned away, and any other error if the user service could not be
+// asked, in which case the caller has to refuse the token as well.
+func (a *AccountClient) Check(ctx context.Context, token string) error {
+	body, err := json.Marshal(&models.ValidateTokenRequest{Token: token})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/validate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return ErrAccountSuspended
+	case http.StatusUnauthorized, http.StatusNotFound:
+		return errAccountInvalid
+	}
+	return fmt.Errorf("user service returned %d validating token", resp.StatusCode)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/cache"
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountClientCheck(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusOK, nil},
+		{http.StatusForbidden, ErrAccountSuspended},
+		{http.StatusUnauthorized, errAccountInvalid},
+		{http.StatusNotFound, errAccountInvalid},
+	}
+	for _, tc := range cases {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req models.ValidateTokenRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			assert.Equal(t, "/validate", r.URL.Path)
+			assert.Equal(t, "token", req.Token)
+			w.WriteHeader(tc.status)
+		}))
+		err := NewAccountClient(srv.URL).Check(t.Context(), "token")
+		assert.Equal(t, tc.want, err, tc.status)
+		srv.Close()
+	}
+}
+
+// Anything but a clear answer is an error, so callers refuse the token.
+func TestAccountClientCheckFailsClosed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	err := NewAccountClient(srv.URL).Check(t.Context(), "token")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrAccountSuspended)
+
+	srv.Close()
+	assert.Error(t, NewAccountClient(srv.URL).Check(t.Context(), "token"))
+}
+
+func TestAuthenticateRejectsRevokedTokens(t *testing.T) {
+	keys := auth.Secret("test-secret")
+	userID := uuid.NewString()
+	token, err := auth.IssueToken(keys, userID, "a@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+
+	revoked := auth.NewRevocationList(cache.NewMemory())
+	hub := NewHub(nil, nil, nil, nil, nil, nil, revoked, FilterChain{})
+
+	claims, err := hub.authenticate(t.Context(), token, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	assert.NoError(t, revoked.RevokeUser(t.Context(), userID))
+	_, err = hub.authenticate(t.Context(), token, keys)
+	assert.ErrorIs(t, err, errTokenRevoked)
+	status, _ := authStatus(err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
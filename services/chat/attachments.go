@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"qasynda/shared/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxAttachmentSize        = 10 << 20
+	maxAttachmentsPerMessage = 5
+	maxFileNameLength        = 255
+	attachmentURLTTL         = 15 * time.Minute
+	sniffLength              = 512
+
+	// Uploads that no message claims within unlinkedAttachmentTTL are swept
+	// every attachmentSweepInterval.
+	unlinkedAttachmentTTL   = 24 * time.Hour
+	attachmentSweepInterval = time.Hour
+	attachmentSweepBatch    = 100
+)
+
+// allowedAttachmentTypes maps each accepted MIME type, as sniffed from the
+// file contents, to the extension used for its storage key.
+var allowedAttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var (
+	ErrAttachmentTooLarge  = fmt.Errorf("attachment must be at most %d MB", maxAttachmentSize>>20)
+	ErrUnsupportedType     = errors.New("only JPEG, PNG, GIF, WebP images and PDF files are allowed")
+	ErrAttachmentNotFound  = errors.New("attachment not found")
+	ErrTooManyAttachments  = fmt.Errorf("a message can carry at most %d attachments", maxAttachmentsPerMessage)
+	ErrInvalidAttachmentID = errors.New("attachment_ids must be uuids")
+)
+
+// sniffContentType identifies a file from its first bytes, ignoring whatever
+// type the client claimed.
+func sniffContentType(head []byte) (string, error) {
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "", ErrUnsupportedType
+	}
+	if _, ok := allowedAttachmentTypes[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == "/" || name == "" {
+		name = "attachment"
+	}
+	for utf8.RuneCountInString(name) > maxFileNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+func attachmentKey(uploaderID, attachmentID uuid.UUID, contentType string) string {
+	return "attachments/" + uploaderID.String() + "/" + attachmentID.String() + allowedAttachmentTypes[contentType]
+}
+
+// claimAttachments loads attachments the sender uploaded for a new message.
+// Each one must exist, belong to the sender and not be linked to a message
+// yet.
+func claimAttachments(ctx context.Context, store IStore, senderID uuid.UUID, ids []uuid.UUID) ([]*Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if len(ids) > maxAttachmentsPerMessage {
+		return nil, ErrTooManyAttachments
+	}
+
+	attachments, err := store.GetAttachments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*Attachment, len(attachments))
+	for _, a := range attachments {
+		byID[a.ID] = a
+	}
+
+	claimed := make([]*Attachment, 0, len(ids))
+	for _, id := range ids {
+		a, ok := byID[id]
+		if !ok || a.UploaderID != senderID || a.MessageID != nil {
+			return nil, ErrAttachmentNotFound
+		}
+		claimed = append(claimed, a)
+	}
+	return claimed, nil
+}
+
+func signAttachments(ctx context.Context, blobs BlobStore, attachments []*Attachment) error {
+	for _, a := range attachments {
+		url, err := blobs.SignedURL(ctx, a.StorageKey, attachmentURLTTL)
+		if err != nil {
+			return err
+		}
+		a.URL = url
+	}
+	return nil
+}
+
+// loadAttachments fills in the attachments of stored messages with fresh
+// download URLs.
+func loadAttachments(ctx context.Context, store IStore, blobs BlobStore, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(messages))
+	byID := make(map[uuid.UUID]*Message, len(messages))
+	for _, m := range messages {
+		if m.DeletedAt != nil {
+			continue
+		}
+		ids = append(ids, m.ID)
+		byID[m.ID] = m
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	attachments, err := store.ListMessageAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if err := signAttachments(ctx, blobs, attachments); err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		if m, ok := byID[*a.MessageID]; ok {
+			m.Attachments = append(m.Attachments, a)
+		}
+	}
+	return nil
+}
+
+// StartAttachmentSweeper deletes abandoned uploads, rows and blobs, until ctx
+// is cancelled.
+func StartAttachmentSweeper(ctx context.Context, store IStore, blobs BlobStore) {
+	ticker := time.NewTicker(attachmentSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		sweepUnlinkedAttachments(ctx, store, blobs, time.Now().Add(-unlinkedAttachmentTTL))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweepUnlinkedAttachments(ctx context.Context, store IStore, blobs BlobStore, cutoff time.Time) {
+	swept := 0
+	for ctx.Err() == nil {
+		attachments, err := store.DeleteUnlinkedAttachments(ctx, cutoff, attachmentSweepBatch)
+		if err != nil {
+			logger.Error("failed to sweep unlinked attachments", err)
+			return
+		}
+		for _, a := range attachments {
+			if err := blobs.Delete(ctx, a.StorageKey); err != nil {
+				logger.Error("failed to delete attachment blob "+a.StorageKey, err)
+			}
+		}
+		swept += len(attachments)
+		if len(attachments) < attachmentSweepBatch {
+			break
+		}
+	}
+	if swept > 0 {
+		logger.Info("swept unlinked attachments", "count", swept)
+	}
+}
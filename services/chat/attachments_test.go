@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffContentType(t *testing.T) {
+	cases := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"jpeg", "\xFF\xD8\xFF\xE0\x00\x10JFIF\x00", "image/jpeg"},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png"},
+		{"gif", "GIF89a\x01\x00\x01\x00", "image/gif"},
+		{"webp", "RIFF\x24\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"pdf", "%PDF-1.7\n%\xE2\xE3\xCF\xD3", "application/pdf"},
+		{"html", "<!DOCTYPE html><html><script>alert(1)</script>", ""},
+		{"svg", `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`, ""},
+		{"text", "just some notes", ""},
+		{"zip", "PK\x03\x04\x14\x00\x00\x00", ""},
+		{"exe", "MZ\x90\x00\x03\x00\x00\x00", ""},
+		{"empty", "", ""},
+	}
+	for _, tc := range cases {
+		got, err := sniffContentType([]byte(tc.head))
+		if tc.want == "" {
+			assert.ErrorIs(t, err, ErrUnsupportedType, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+// A client-supplied file name or extension never changes what is accepted.
+func TestSniffContentTypeIgnoresDisguise(t *testing.T) {
+	_, err := sniffContentType([]byte("<html><body>fake.png</body></html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	assert.Equal(t, "report.pdf", sanitizeFileName("  report.pdf "))
+	assert.Equal(t, "passwd", sanitizeFileName("../../etc/passwd"))
+	assert.Equal(t, "photo.jpg", sanitizeFileName(`C:\Users\me\photo.jpg`))
+	assert.Equal(t, "attachment", sanitizeFileName(""))
+	assert.Equal(t, "attachment", sanitizeFileName("/"))
+
+	long := sanitizeFileName(strings.Repeat("ж", maxFileNameLength+10))
+	assert.Equal(t, maxFileNameLength, len([]rune(long)))
+}
+
+func TestAttachmentKey(t *testing.T) {
+	uploader, id := uuid.New(), uuid.New()
+	assert.Equal(t, "attachments/"+uploader.String()+"/"+id.String()+".png", attachmentKey(uploader, id, "image/png"))
+}
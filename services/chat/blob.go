@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"qasynda/shared/pkg/config"
+)
+
+var (
+	ErrBlobNotFound     = errors.New("blob not found")
+	ErrInvalidBlobKey   = errors.New("invalid blob key")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+	ErrMissingURLSecret = errors.New("BLOB_URL_SECRET must be set to sign attachment URLs")
+)
+
+// BlobStore keeps attachment bytes outside the database. Keys are slash
+// separated paths chosen by the caller.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+func NewBlobStore(cfg config.BlobConfig) (BlobStore, error) {
+	switch cfg.Driver {
+	case "fs":
+		return NewFileBlobStore(cfg.Dir, cfg.PublicURL, cfg.URLSecret)
+	case "s3":
+		return NewS3BlobStore(cfg.S3Endpoint, cfg.S3PublicEndpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey)
+	}
+	return nil, fmt.Errorf("unknown blob driver %q", cfg.Driver)
+}
+
+// FileBlobStore writes blobs under a local directory. Download URLs point at
+// the chat service's own download endpoint and carry an HMAC over the key and
+// expiry, so they can be shared without a bearer token.
+type FileBlobStore struct {
+	root      string
+	publicURL string
+	secret    []byte
+}
+
+func NewFileBlobStore(root, publicURL, secret string) (*FileBlobStore, error) {
+	if secret == "" {
+		return nil, ErrMissingURLSecret
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileBlobStore{
+		root:      root,
+		publicURL: strings.TrimRight(publicURL, "/"),
+		secret:    []byte(secret),
+	}, nil
+}
+
+func (s *FileBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidBlobKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *FileBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *FileBlobStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileBlobStore) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("key", key)
+	q.Set("expires", expires)
+	q.Set("sig", s.sign(key, expires))
+	return s.publicURL + "/attachments/download?" + q.Encode(), nil
+}
+
+// Verify checks a signature produced by SignedURL.
+func (s *FileBlobStore) Verify(key, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *FileBlobStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
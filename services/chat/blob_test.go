@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFileBlobStore(t *testing.T) *FileBlobStore {
+	store, err := NewFileBlobStore(t.TempDir(), "http://chat.test/api/chat/", "test-secret")
+	assert.NoError(t, err)
+	return store
+}
+
+func TestFileBlobStoreRequiresSecret(t *testing.T) {
+	_, err := NewFileBlobStore(t.TempDir(), "http://chat.test", "")
+	assert.ErrorIs(t, err, ErrMissingURLSecret)
+}
+
+func TestFileBlobStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileBlobStore(t)
+
+	key := "attachments/user/file.png"
+	assert.NoError(t, store.Put(ctx, key, strings.NewReader("png bytes"), 9, "image/png"))
+
+	r, err := store.Get(ctx, key)
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(r)
+		r.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "png bytes", string(body))
+	}
+
+	assert.NoError(t, store.Delete(ctx, key))
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	// Deleting twice is not an error.
+	assert.NoError(t, store.Delete(ctx, key))
+}
+
+func TestFileBlobStoreRejectsEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileBlobStore(t)
+
+	for _, key := range []string{"", "/", "../outside", "attachments/../../etc/passwd"} {
+		err := store.Put(ctx, key, strings.NewReader("x"), 1, "text/plain")
+		assert.ErrorIs(t, err, ErrInvalidBlobKey, key)
+		_, err = store.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrInvalidBlobKey, key)
+	}
+}
+
+func TestFileBlobStoreSignedURL(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileBlobStore(t)
+
+	raw, err := store.SignedURL(ctx, "attachments/user/file.pdf", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "http://chat.test/api/chat/attachments/download?"), raw)
+
+	u, err := url.Parse(raw)
+	assert.NoError(t, err)
+	q := u.Query()
+	key, expires, sig := q.Get("key"), q.Get("expires"), q.Get("sig")
+	assert.Equal(t, "attachments/user/file.pdf", key)
+	assert.NoError(t, store.Verify(key, expires, sig))
+
+	assert.ErrorIs(t, store.Verify("attachments/user/other.pdf", expires, sig), ErrInvalidSignature)
+	assert.ErrorIs(t, store.Verify(key, expires, strings.Repeat("0", len(sig))), ErrInvalidSignature)
+	assert.ErrorIs(t, store.Verify(key, "not-a-number", sig), ErrInvalidSignature)
+
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	assert.ErrorIs(t, store.Verify(key, later, sig), ErrInvalidSignature)
+
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	assert.ErrorIs(t, store.Verify(key, past, store.sign(key, past)), ErrInvalidSignature)
+
+	other, err := NewFileBlobStore(t.TempDir(), "http://chat.test", "another-secret")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, other.Verify(key, expires, sig), ErrInvalidSignature)
+}
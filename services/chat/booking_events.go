@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	bookingEventsQueue     = "chat.booking_events"
+	bookingEventsDeadQueue = "chat.booking_events.dead"
+	consumerReconnectMin   = time.Second
+	consumerReconnectMax   = 30 * time.Second
+)
+
+var errBookingEventsClosed = errors.New("booking events channel closed")
+
+// systemMessageNamespace seeds the ids of generated messages so a redelivered
+// event produces the same id and is ignored on insert.
+var systemMessageNamespace = uuid.MustParse("6f1c5a9e-2f44-4c1b-9d0e-7b3a2c1d8e55")
+
+var bookingStatusText = map[string]string{
+	"accepted":  "Booking accepted",
+	"rejected":  "Booking declined",
+	"completed": "Booking marked as completed",
+	"cancelled": "Booking cancelled",
+}
+
+// bookingStatusMessage turns a status change into a system message from the
+// user who made the change to the other party.
+func bookingStatusMessage(event *models.BookingStatusChangedEvent) (*Message, error) {
+	bookingID, err := uuid.Parse(event.BookingID)
+	if err != nil {
+		return nil, err
+	}
+	clientID, err := uuid.Parse(event.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	providerID, err := uuid.Parse(event.ProviderUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, receiver := clientID, providerID
+	if event.ChangedBy == event.ProviderUserID {
+		sender, receiver = providerID, clientID
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, event.ChangedAt)
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	content, ok := bookingStatusText[event.To]
+	if !ok {
+		content = "Booking status changed to " + event.To
+	}
+
+	return &Message{
+		ID:         uuid.NewSHA1(systemMessageNamespace, []byte(event.BookingID+"|"+event.From+"|"+event.To+"|"+event.ChangedAt)),
+		SenderID:   sender,
+		ReceiverID: receiver,
+		Content:    content,
+		BookingID:  &bookingID,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+// StartBookingEventsConsumer consumes booking events until ctx is cancelled,
+// reconnecting with exponential backoff whenever the connection or channel
+// goes away.
+func StartBookingEventsConsumer(ctx context.Context, url string, hub *Hub) {
+	wait := consumerReconnectMin
+	for {
+		consumed, err := consumeBookingEvents(ctx, url, hub)
+		if ctx.Err() != nil {
+			logger.Info("Booking events consumer stopping...")
+			return
+		}
+		if consumed {
+			wait = consumerReconnectMin
+		}
+		logger.Error("booking events consumer disconnected, reconnecting in "+wait.String(), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, consumerReconnectMax)
+	}
+}
+
+// consumeBookingEvents runs one connection's worth of consuming. It reports
+// whether it got as far as consuming, so a long-lived session resets the
+// backoff, and why it stopped.
+func consumeBookingEvents(ctx context.Context, url string, hub *Hub) (bool, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	if err := ch.ExchangeDeclare(models.MarketplaceEventsExchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return false, err
+	}
+	if _, err := ch.QueueDeclare(bookingEventsQueue, true, false, false, false, nil); err != nil {
+		return false, err
+	}
+	if _, err := ch.QueueDeclare(bookingEventsDeadQueue, true, false, false, false, nil); err != nil {
+		return false, err
+	}
+	if err := ch.QueueBind(bookingEventsQueue, models.BookingStatusChangedKey, models.MarketplaceEventsExchange, false, nil); err != nil {
+		return false, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		return false, err
+	}
+
+	msgs, err := ch.Consume(bookingEventsQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return false, err
+	}
+
+	deadLetter := func(d amqp.Delivery, cause error) error {
+		return publishConfirmed(ctx, ch, bookingEventsDeadQueue, amqp.Publishing{
+			ContentType: d.ContentType,
+			MessageId:   d.MessageId,
+			Headers: amqp.Table{
+				headerLastError: cause.Error(),
+				headerFailedAt:  time.Now().UTC(),
+			},
+			Body: d.Body,
+		})
+	}
+
+	logger.Info("Booking events consumer started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case d, ok := <-msgs:
+			if !ok {
+				return true, errBookingEventsClosed
+			}
+			handleBookingEvent(ctx, hub, d, deadLetter)
+		}
+	}
+}
+
+// handleBookingEvent posts the system message for one event. Events that can
+// never be saved, because they are malformed or name users that do not
+// exist, are moved to the dead-letter queue; anything else is requeued to be
+// tried again.
+func handleBookingEvent(ctx context.Context, hub *Hub, d amqp.Delivery, deadLetter func(amqp.Delivery, error) error) {
+	var event models.BookingStatusChangedEvent
+	if err := json.Unmarshal(d.Body, &event); err != nil {
+		logger.Error("failed to unmarshal booking event", err)
+		settleBookingEvent(d, deadLetter, err)
+		return
+	}
+
+	msg, err := bookingStatusMessage(&event)
+	if err != nil {
+		logger.Error("invalid booking event", err)
+		settleBookingEvent(d, deadLetter, err)
+		return
+	}
+
+	if err := hub.SendSystemMessage(ctx, msg); err != nil {
+		logger.Error("failed to save booking system message", err)
+		if isPermanentError(err) {
+			settleBookingEvent(d, deadLetter, err)
+			return
+		}
+		time.Sleep(retryBaseDelay)
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+// settleBookingEvent acks d once it is safely in the dead-letter queue, and
+// requeues it if even that fails so nothing is lost.
+func settleBookingEvent(d amqp.Delivery, deadLetter func(amqp.Delivery, error) error, cause error) {
+	if err := deadLetter(d, cause); err != nil {
+		logger.Error("failed to move booking event to "+bookingEventsDeadQueue, err)
+		time.Sleep(retryBaseDelay)
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
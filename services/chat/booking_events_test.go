@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// recordingAcknowledger remembers how a delivery was settled.
+type recordingAcknowledger struct {
+	acked   bool
+	nacked  bool
+	requeue bool
+}
+
+func (a *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *recordingAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeue = true, requeue
+	return nil
+}
+
+func (a *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func bookingEventDelivery(t *testing.T) (amqp.Delivery, *recordingAcknowledger) {
+	body, err := json.Marshal(&models.BookingStatusChangedEvent{
+		BookingID:      uuid.NewString(),
+		ClientID:       uuid.NewString(),
+		ProviderUserID: uuid.NewString(),
+		From:           "pending",
+		To:             "accepted",
+		ChangedAt:      "2024-03-01T12:30:00Z",
+	})
+	assert.NoError(t, err)
+	ack := new(recordingAcknowledger)
+	return amqp.Delivery{Acknowledger: ack, Body: body}, ack
+}
+
+func TestHandleBookingEvent(t *testing.T) {
+	cases := []struct {
+		name       string
+		saveErr    error
+		deadLetter bool
+	}{
+		{"saved", nil, false},
+		{"missing user", &pq.Error{Code: "23503"}, true},
+	}
+	for _, tc := range cases {
+		mockStore := new(MockStore)
+		mockStore.On("SaveMessage", mock.Anything, mock.Anything).Return(tc.saveErr)
+		hub := newTestHub(mockStore)
+
+		var dead []error
+		d, ack := bookingEventDelivery(t)
+		handleBookingEvent(t.Context(), hub, d, func(d amqp.Delivery, cause error) error {
+			dead = append(dead, cause)
+			return nil
+		})
+
+		assert.True(t, ack.acked, tc.name)
+		assert.False(t, ack.nacked, tc.name)
+		assert.Equal(t, tc.deadLetter, len(dead) == 1, tc.name)
+	}
+}
+
+func TestHandleBookingEventMalformed(t *testing.T) {
+	ack := new(recordingAcknowledger)
+	var dead []error
+	handleBookingEvent(t.Context(), newTestHub(new(MockStore)), amqp.Delivery{Acknowledger: ack, Body: []byte("{")}, func(d amqp.Delivery, cause error) error {
+		dead = append(dead, cause)
+		return nil
+	})
+
+	assert.Len(t, dead, 1)
+	assert.True(t, ack.acked)
+}
+
+// A database that is down is worth waiting for, so the event goes back on the
+// queue instead of to the dead letters.
+func TestHandleBookingEventTransientError(t *testing.T) {
+	mockStore := new(MockStore)
+	mockStore.On("SaveMessage", mock.Anything, mock.Anything).Return(errors.New("connection refused"))
+
+	d, ack := bookingEventDelivery(t)
+	handleBookingEvent(t.Context(), newTestHub(mockStore), d, func(d amqp.Delivery, cause error) error {
+		t.Error("transient failure was dead-lettered")
+		return nil
+	})
+
+	assert.False(t, ack.acked)
+	assert.True(t, ack.nacked)
+	assert.True(t, ack.requeue)
+}
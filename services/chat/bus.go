@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"qasynda/shared/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	eventsExchange = "chat.events"
+	headerNode     = "x-node"
+)
+
+var (
+	errBusClosed   = errors.New("chat event bus channel closed")
+	errBusNotReady = errors.New("chat event bus is reconnecting")
+)
+
+// Bus carries socket frames between chat instances. Every instance owns an
+// exclusive queue on the chat.events topic exchange and binds it to
+// user.<id> for each user that has a session on that instance, so a frame
+// published for a user reaches only the nodes that can deliver it. When the
+// connection drops, Consume reconnects and declares a new queue with the same
+// bindings.
+type Bus struct {
+	url    string
+	nodeID string
+
+	mu    sync.Mutex
+	conn  *amqp.Connection
+	ch    *amqp.Channel
+	queue string
+	// bound holds the users with a session on this node, whether or not
+	// their binding could be made yet; reconnecting binds all of them.
+	bound map[string]bool
+}
+
+func userRoutingKey(userID string) string {
+	return "user." + userID
+}
+
+func NewBus(url, nodeID string) (*Bus, error) {
+	b := &Bus{url: url, nodeID: nodeID, bound: make(map[string]bool)}
+	if err := b.connect(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// connect opens a connection and channel, declares this node's queue and
+// binds it for every user in bound.
+func (b *Bus) connect() error {
+	conn, err := amqp.Dial(b.url)
+	if err != nil {
+		return err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if err := ch.ExchangeDeclare(eventsExchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return err
+	}
+
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for userID := range b.bound {
+		if err := ch.QueueBind(q.Name, userRoutingKey(userID), eventsExchange, false, nil); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	b.conn, b.ch, b.queue = conn, ch, q.Name
+	return nil
+}
+
+// Sync binds or unbinds the user's routing key to match whether this node
+// currently holds a session for them. isOnline is evaluated under the bus lock
+// so concurrent connects and disconnects always settle on the latest state.
+func (b *Bus) Sync(userID string, isOnline func(string) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	online := isOnline(userID)
+	if b.bound[userID] == online {
+		return
+	}
+	if online {
+		b.bound[userID] = true
+	} else {
+		delete(b.bound, userID)
+	}
+
+	var err error
+	if online {
+		err = b.ch.QueueBind(b.queue, userRoutingKey(userID), eventsExchange, false, nil)
+	} else {
+		err = b.ch.QueueUnbind(b.queue, userRoutingKey(userID), eventsExchange, nil)
+	}
+	if err != nil {
+		// The channel is gone; connect applies bound once it is back.
+		logger.Error("failed to update chat binding for "+userID, err)
+	}
+}
+
+// Publish hands a frame to every other node with a session for userID.
+func (b *Bus) Publish(ctx context.Context, userID string, payload []byte) error {
+	b.mu.Lock()
+	ch := b.ch
+	b.mu.Unlock()
+	if ch.IsClosed() {
+		return errBusNotReady
+	}
+	return ch.PublishWithContext(ctx, eventsExchange, userRoutingKey(userID), false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Headers:     amqp.Table{headerNode: b.nodeID},
+		Body:        payload,
+	})
+}
+
+// Consume calls deliver for every frame published by other nodes until ctx is
+// cancelled, reconnecting with exponential backoff whenever the connection or
+// channel goes away.
+func (b *Bus) Consume(ctx context.Context, deliver func(userID string, payload []byte)) {
+	wait := consumerReconnectMin
+	for {
+		consumed, err := b.consume(ctx, deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		if consumed {
+			wait = consumerReconnectMin
+		}
+		logger.Error("chat event bus disconnected, reconnecting in "+wait.String(), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, consumerReconnectMax)
+
+		if err := b.connect(); err != nil {
+			logger.Error("failed to reconnect chat event bus", err)
+		}
+	}
+}
+
+// consume reads frames from the current channel until it closes, reporting
+// whether it got as far as consuming.
+func (b *Bus) consume(ctx context.Context, deliver func(userID string, payload []byte)) (bool, error) {
+	b.mu.Lock()
+	ch, queue := b.ch, b.queue
+	b.mu.Unlock()
+
+	msgs, err := ch.Consume(queue, "", true, true, false, false, nil)
+	if err != nil {
+		return false, err
+	}
+
+	logger.Info("Chat event bus started on node " + b.nodeID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case d, ok := <-msgs:
+			if !ok {
+				return true, errBusClosed
+			}
+			if node, _ := d.Headers[headerNode].(string); node == b.nodeID {
+				continue
+			}
+			userID := strings.TrimPrefix(d.RoutingKey, userRoutingKey(""))
+			deliver(userID, d.Body)
+		}
+	}
+}
+
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ch.Close()
+	b.conn.Close()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultConversationLimit = 20
+	maxConversationLimit     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// conversationCursor marks the last conversation of a page. The next page
+// continues strictly after it in (last_message_at, peer_id) DESC order, so
+// new messages arriving between requests never shift or repeat entries.
+type conversationCursor struct {
+	LastMessageAt time.Time
+	PeerID        uuid.UUID
+}
+
+func (c *conversationCursor) encode() string {
+	raw := c.LastMessageAt.UTC().Format(time.RFC3339Nano) + "|" + c.PeerID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeConversationCursor(s string) (*conversationCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	at, peer, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	peerID, err := uuid.Parse(peer)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &conversationCursor{LastMessageAt: t, PeerID: peerID}, nil
+}
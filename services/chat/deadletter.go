@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const maxDeadLetterBatch = 100
+
+type DeadLetter struct {
+	MessageID string
+	Message   *Message
+	Body      []byte
+	Attempts  int
+	LastError string
+	FailedAt  time.Time
+}
+
+func toDeadLetter(d amqp.Delivery) *DeadLetter {
+	dl := &DeadLetter{
+		MessageID: d.MessageId,
+		Body:      d.Body,
+		Attempts:  deliveryAttempts(d),
+	}
+	if v, ok := d.Headers[headerLastError].(string); ok {
+		dl.LastError = v
+	}
+	if v, ok := d.Headers[headerFailedAt].(time.Time); ok {
+		dl.FailedAt = v
+	}
+
+	var msg Message
+	if err := json.Unmarshal(d.Body, &msg); err == nil {
+		dl.Message = &msg
+		if dl.MessageID == "" {
+			dl.MessageID = msg.ID.String()
+		}
+	}
+	return dl
+}
+
+// fetchDeadLetters pulls up to limit messages off the dead-letter queue
+// without acking them. They stay invisible to other readers until the caller
+// acks or nacks them, or the channel is closed.
+func fetchDeadLetters(ch *amqp.Channel, limit int) ([]amqp.Delivery, error) {
+	var deliveries []amqp.Delivery
+	for len(deliveries) < limit {
+		d, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return deliveries, err
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// PeekDeadLetters returns the first messages in the dead-letter queue and the
+// queue depth. The messages are put back untouched.
+func (p *RabbitMQProducer) PeekDeadLetters(limit int) ([]*DeadLetter, int, error) {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(deadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, err := fetchDeadLetters(ch, limit)
+	if len(deliveries) > 0 {
+		defer deliveries[len(deliveries)-1].Nack(true, true)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	letters := make([]*DeadLetter, 0, len(deliveries))
+	for _, d := range deliveries {
+		letters = append(letters, toDeadLetter(d))
+	}
+	return letters, q.Messages, nil
+}
+
+// ReplayDeadLetters moves dead-lettered messages back onto the main queue with
+// a fresh attempt count. When ids is empty every fetched message is replayed;
+// otherwise only those whose message id is listed, and the rest are put back.
+func (p *RabbitMQProducer) ReplayDeadLetters(ctx context.Context, ids []string, limit int) (int, error) {
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	deliveries, err := fetchDeadLetters(ch, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, d := range deliveries {
+		dl := toDeadLetter(d)
+		if len(wanted) > 0 && !wanted[dl.MessageID] {
+			if err := d.Nack(false, true); err != nil {
+				return replayed, err
+			}
+			continue
+		}
+
+		err := publishConfirmed(ctx, ch, messagesQueue, amqp.Publishing{
+			ContentType: d.ContentType,
+			MessageId:   dl.MessageID,
+			Body:        d.Body,
+		})
+		if err != nil {
+			return replayed, err
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// messageEditWindow is how long after sending a user can still edit or
+// delete a message.
+const messageEditWindow = 15 * time.Minute
+
+var (
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrEditWindowExpired = fmt.Errorf("messages can only be changed within %d minutes of sending", int(messageEditWindow/time.Minute))
+)
+
+// changeableMessage loads a message senderID may still edit or delete.
+// Other users' messages, system messages and deleted ones all look missing.
+func (h *Hub) changeableMessage(ctx context.Context, senderID, id uuid.UUID) (*Message, error) {
+	msg, err := h.store.GetMessage(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != senderID || msg.Kind != KindUser || msg.DeletedAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	if time.Since(msg.CreatedAt) > messageEditWindow {
+		return nil, ErrEditWindowExpired
+	}
+	return msg, nil
+}
+
+// EditMessage changes the text of one of senderID's messages and pushes the
+// new version to both users. skip is the connection the edit came from, if
+// any.
+func (h *Hub) EditMessage(ctx context.Context, senderID uuid.UUID, skip *Client, id uuid.UUID, content string) (*Message, error) {
+	content, err := h.filter.Filter(content)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := h.changeableMessage(ctx, senderID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ok, err := h.store.EditMessage(ctx, id, senderID, content, now, now.Add(-messageEditWindow))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrEditWindowExpired
+	}
+	msg.Content = content
+	msg.EditedAt = &now
+
+	if err := loadAttachments(ctx, h.store, h.blobs, []*Message{msg}); err != nil {
+		return nil, err
+	}
+
+	h.broadcastChange(ctx, FrameEdit, msg, skip)
+	return msg, nil
+}
+
+// DeleteMessage soft-deletes one of senderID's messages and tells both users
+// to remove it.
+func (h *Hub) DeleteMessage(ctx context.Context, senderID uuid.UUID, skip *Client, id uuid.UUID) (*Message, error) {
+	msg, err := h.changeableMessage(ctx, senderID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ok, err := h.store.DeleteMessage(ctx, id, senderID, now, now.Add(-messageEditWindow))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrEditWindowExpired
+	}
+	msg.Content = ""
+	msg.DeletedAt = &now
+
+	h.broadcastChange(ctx, FrameDelete, msg, skip)
+	return msg, nil
+}
+
+// broadcastChange pushes an edit or delete to the sender's sessions, and to
+// the receiver's unless one of them has blocked the other since.
+func (h *Hub) broadcastChange(ctx context.Context, frameType string, msg *Message, skip *Client) {
+	payload := encodeFrame(&Frame{Type: frameType, Message: msg})
+	receiverID, senderID := msg.ReceiverID.String(), msg.SenderID.String()
+	if !h.peerBlocked(ctx, senderID, receiverID) {
+		h.deliver(receiverID, payload, skip)
+	}
+	if senderID != receiverID {
+		h.deliver(senderID, payload, skip)
+	}
+}
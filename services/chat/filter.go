@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const redactedText = "[hidden]"
+
+var ErrProhibitedContent = errors.New("message contains prohibited words")
+
+// ContentFilter inspects message text before it is stored. It either returns
+// the text to send, possibly rewritten, or an error that rejects the message.
+type ContentFilter interface {
+	Filter(content string) (string, error)
+}
+
+// FilterChain runs filters in order, feeding each the previous one's output.
+type FilterChain []ContentFilter
+
+func (fc FilterChain) Filter(content string) (string, error) {
+	for _, f := range fc {
+		var err error
+		if content, err = f.Filter(content); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// BannedWordsFilter rejects messages containing any of its words as a whole
+// word, ignoring case.
+type BannedWordsFilter struct {
+	pattern *regexp.Regexp
+}
+
+func NewBannedWordsFilter(words []string) *BannedWordsFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return &BannedWordsFilter{}
+	}
+	// \b only knows ASCII word characters, so boundaries are spelled out to
+	// work for Cyrillic as well.
+	return &BannedWordsFilter{
+		pattern: regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}])(?:` + strings.Join(quoted, "|") + `)(?:$|[^\p{L}\p{N}])`),
+	}
+}
+
+func (f *BannedWordsFilter) Filter(content string) (string, error) {
+	if f.pattern != nil && f.pattern.MatchString(content) {
+		return "", ErrProhibitedContent
+	}
+	return content, nil
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+\s*@\s*[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+?\(?\d[\d\s\-().]{5,}\d`)
+	// datePattern spots dates that phonePattern would otherwise take for a
+	// number, as in "2024-01-15 10:00".
+	datePattern = regexp.MustCompile(`\d{4}-\d{1,2}-\d{1,2}|\d{1,2}[./]\d{1,2}[./]\d{4}`)
+)
+
+// A run of digits only counts as a phone number within the lengths E.164
+// allows for national and international formats, which leaves prices and
+// short codes alone.
+const (
+	minPhoneDigits = 10
+	maxPhoneDigits = 15
+)
+
+// ContactRedactor hides email addresses and phone numbers so clients and
+// providers keep bookings on the platform.
+type ContactRedactor struct{}
+
+func (ContactRedactor) Filter(content string) (string, error) {
+	content = emailPattern.ReplaceAllString(content, redactedText)
+	content = phonePattern.ReplaceAllStringFunc(content, func(match string) string {
+		digits := 0
+		for _, r := range match {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits < minPhoneDigits || digits > maxPhoneDigits || datePattern.MatchString(match) {
+			return match
+		}
+		return redactedText
+	})
+	return content, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBannedWordsFilter(t *testing.T) {
+	f := NewBannedWordsFilter([]string{"scam", " дурак ", "", "c++"})
+
+	rejected := []string{
+		"this is a scam",
+		"SCAM!",
+		"scam",
+		"ты дурак.",
+		"Дурак",
+		"I write c++ daily",
+	}
+	for _, content := range rejected {
+		_, err := f.Filter(content)
+		assert.ErrorIs(t, err, ErrProhibitedContent, content)
+	}
+
+	allowed := []string{
+		"scampi for dinner",
+		"antiscam measures",
+		"дураками не рождаются",
+		"c and c#",
+		"",
+	}
+	for _, content := range allowed {
+		out, err := f.Filter(content)
+		assert.NoError(t, err, content)
+		assert.Equal(t, content, out)
+	}
+}
+
+func TestBannedWordsFilterWithoutWords(t *testing.T) {
+	for _, words := range [][]string{nil, {"", "  "}} {
+		out, err := NewBannedWordsFilter(words).Filter("anything goes")
+		assert.NoError(t, err)
+		assert.Equal(t, "anything goes", out)
+	}
+}
+
+func TestContactRedactorPhones(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"call +7 (701) 123-45-67 now", "call [hidden] now"},
+		{"(701) 123-45-67", "[hidden]"},
+		{"8 701 123 45 67", "[hidden]"},
+		{"8-701-123-45-67", "[hidden]"},
+		{"87011234567", "[hidden]"},
+		{"+77011234567", "[hidden]"},
+		{"+1 (555) 010-9999.", "[hidden]."},
+		{"tel:+7-701-123-4567, thanks", "tel:[hidden], thanks"},
+		{"two: 87011234567 and 87027654321", "two: [hidden] and [hidden]"},
+
+		// Too few digits for a phone number.
+		{"price 150000 tenge", "price 150000 tenge"},
+		{"price 5 000 000 tg", "price 5 000 000 tg"},
+		{"numbers 123456789", "numbers 123456789"},
+		{"room 12, 3rd floor", "room 12, 3rd floor"},
+		{"1990-2000 and 2010-2020", "1990-2000 and 2010-2020"},
+
+		// Too many digits, like card or order numbers.
+		{"order #1234567890123456", "order #1234567890123456"},
+
+		// Dates and times are not phone numbers.
+		{"meet 2024-01-15 10:00", "meet 2024-01-15 10:00"},
+		{"between 10:00-18:00 on 12.05.2024", "between 10:00-18:00 on 12.05.2024"},
+	}
+	for _, tc := range cases {
+		out, err := ContactRedactor{}.Filter(tc.in)
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, out, tc.in)
+	}
+}
+
+func TestContactRedactorEmails(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"write to john.doe@mail.ru", "write to [hidden]"},
+		{"ask@example.com.", "[hidden]."},
+		{"me @ gmail.com please", "[hidden] please"},
+		{"first+tag@sub.example.co.uk or x_y@ex-ample.kz", "[hidden] or [hidden]"},
+		{"a@b.c", "a@b.c"},
+		{"ping @team", "ping @team"},
+	}
+	for _, tc := range cases {
+		out, err := ContactRedactor{}.Filter(tc.in)
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, out, tc.in)
+	}
+}
+
+type rejectFilter struct{ err error }
+
+func (f rejectFilter) Filter(string) (string, error) { return "", f.err }
+
+type suffixFilter string
+
+func (f suffixFilter) Filter(content string) (string, error) { return content + string(f), nil }
+
+func TestFilterChain(t *testing.T) {
+	out, err := FilterChain{suffixFilter("-a"), suffixFilter("-b")}.Filter("x")
+	assert.NoError(t, err)
+	assert.Equal(t, "x-a-b", out)
+
+	// Each filter sees the previous one's output.
+	chain := FilterChain{ContactRedactor{}, NewBannedWordsFilter([]string{"hidden"})}
+	_, err = chain.Filter("call 87011234567")
+	assert.ErrorIs(t, err, ErrProhibitedContent)
+
+	// A rejection stops the chain.
+	stop := errors.New("stop")
+	out, err = FilterChain{rejectFilter{stop}, suffixFilter("-never")}.Filter("x")
+	assert.ErrorIs(t, err, stop)
+	assert.Empty(t, out)
+
+	out, err = FilterChain{}.Filter("untouched")
+	assert.NoError(t, err)
+	assert.Equal(t, "untouched", out)
+}
@@ -8,17 +8,26 @@ import (
 	"syscall"
 	"time"
 
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/cache"
 	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/db"
 	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func main() {
 	logger.Init()
 	cfg := config.Load()
 
+	if err := models.RegisterValidators(); err != nil {
+		logger.Error("failed to register validators", err)
+		os.Exit(1)
+	}
+
 	database, err := db.Connect(cfg.DBUrl)
 	if err != nil {
 		logger.Error("failed to connect to db", err)
@@ -40,16 +49,62 @@ func main() {
 
 	go StartConsumer(ctx, cfg.RabbitMQUrl, store)
 
-	hub := NewHub(store, rmq)
+	nodeID := config.GetChatNodeID()
+	if nodeID == "" {
+		nodeID = uuid.NewString()
+	}
+
+	bus, err := NewBus(cfg.RabbitMQUrl, nodeID)
+	if err != nil {
+		logger.Error("failed to connect chat event bus", err)
+		os.Exit(1)
+	}
+	defer bus.Close()
+
+	blobs, err := NewBlobStore(config.GetBlobConfig())
+	if err != nil {
+		logger.Error("failed to init blob store", err)
+		os.Exit(1)
+	}
+
+	go StartAttachmentSweeper(ctx, store, blobs)
+
+	marketplace := NewMarketplaceClient(cfg.Services.MarketplaceUrl)
+
+	filter := FilterChain{NewBannedWordsFilter(config.GetChatBannedWords()), ContactRedactor{}}
+
+	accounts := NewAccountClient(cfg.Services.UserUrl)
+
+	revocations, err := cache.New(cfg.RedisUrl)
+	if err != nil {
+		logger.Error("failed to connect to redis", err)
+		os.Exit(1)
+	}
+	revoked := auth.NewRevocationList(revocations)
+
+	hub := NewHub(store, rmq, bus, blobs, marketplace, accounts, revoked, filter)
+	go bus.Consume(ctx, hub.receiveRemote)
 	go hub.Run(ctx)
 
-	server := NewServer(store)
+	go StartBookingEventsConsumer(ctx, cfg.RabbitMQUrl, hub)
+
+	server := NewServer(store, rmq, blobs, marketplace, hub)
 
 	r := gin.Default()
 
 	r.GET("/history", server.GetHistory)
+	r.GET("/unread", server.GetUnread)
+	r.GET("/conversations", server.ListConversations)
+	r.POST("/attachments", server.UploadAttachment)
+	r.GET("/attachments/download", server.DownloadAttachment)
+	r.POST("/read", server.MarkRead)
+	r.PUT("/messages/:id", server.EditMessage)
+	r.DELETE("/messages/:id", server.DeleteMessage)
+	r.GET("/admin/dead-letters", server.ListDeadLetters)
+	r.POST("/admin/dead-letters/replay", server.ReplayDeadLetters)
+	keys := auth.NewJWKS(cfg.JWKSUrl())
 	r.GET("/ws", func(c *gin.Context) {
-		ServeWs(hub, c.Writer, c.Request)
+		ServeWs(hub, keys, c.Writer, c.Request)
 	})
 
 	port := config.GetChatPort()
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	bookingCacheTTL   = 5 * time.Minute
+	maxCachedBookings = 10000
+)
+
+var (
+	ErrBookingNotFound = errors.New("booking not found")
+	ErrNotBookingParty = errors.New("both users must be parties to the booking")
+)
+
+type bookingParties struct {
+	ClientID       uuid.UUID
+	ProviderUserID uuid.UUID
+	fetchedAt      time.Time
+}
+
+func (p *bookingParties) includes(userID uuid.UUID) bool {
+	return userID == p.ClientID || userID == p.ProviderUserID
+}
+
+// MarketplaceClient asks the marketplace service who the parties to a booking
+// are. The parties never change, so answers are cached for a few minutes to
+// keep a busy thread from costing one HTTP call per message.
+type MarketplaceClient struct {
+	baseURL string
+	client  *http.Client
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]*bookingParties
+}
+
+func NewMarketplaceClient(baseURL string) *MarketplaceClient {
+	return &MarketplaceClient{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		cache:   make(map[uuid.UUID]*bookingParties),
+	}
+}
+
+func (m *MarketplaceClient) parties(ctx context.Context, bookingID uuid.UUID) (*bookingParties, error) {
+	m.mu.Lock()
+	cached, ok := m.cache[bookingID]
+	m.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < bookingCacheTTL {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/bookings/%s", m.baseURL, bookingID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrBookingNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("marketplace returned %d for booking %s", resp.StatusCode, bookingID)
+	}
+
+	var booking models.BookingDetails
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		return nil, err
+	}
+
+	p := &bookingParties{fetchedAt: time.Now()}
+	if p.ClientID, err = uuid.Parse(booking.ClientID); err != nil {
+		return nil, err
+	}
+	if p.ProviderUserID, err = uuid.Parse(booking.ProviderUserID); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if len(m.cache) >= maxCachedBookings {
+		for id, entry := range m.cache {
+			if time.Since(entry.fetchedAt) >= bookingCacheTTL {
+				delete(m.cache, id)
+			}
+		}
+	}
+	m.cache[bookingID] = p
+	m.mu.Unlock()
+	return p, nil
+}
+
+// CheckParties returns ErrNotBookingParty unless every given user is the
+// booking's client or provider.
+func (m *MarketplaceClient) CheckParties(ctx context.Context, bookingID uuid.UUID, userIDs ...uuid.UUID) error {
+	p, err := m.parties(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		if !p.includes(id) {
+			return ErrNotBookingParty
+		}
+	}
+	return nil
+}
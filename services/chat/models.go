@@ -6,10 +6,59 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
+)
+
+const (
+	KindUser   = "user"
+	KindSystem = "system"
+)
+
 type Message struct {
-	ID         uuid.UUID `db:"id" json:"id"`
-	SenderID   uuid.UUID `db:"sender_id" json:"sender_id"`
-	ReceiverID uuid.UUID `db:"receiver_id" json:"receiver_id"`
-	Content    string    `db:"content" json:"content"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	ID          uuid.UUID  `db:"id" json:"id"`
+	SenderID    uuid.UUID  `db:"sender_id" json:"sender_id"`
+	ReceiverID  uuid.UUID  `db:"receiver_id" json:"receiver_id"`
+	Content     string     `db:"content" json:"content"`
+	BookingID   *uuid.UUID `db:"booking_id" json:"booking_id,omitempty"`
+	Kind        string     `db:"kind" json:"kind"`
+	Status      string     `db:"status" json:"status"`
+	DeliveredAt *time.Time `db:"delivered_at" json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `db:"read_at" json:"read_at,omitempty"`
+	EditedAt    *time.Time `db:"edited_at" json:"edited_at,omitempty"`
+	DeletedAt   *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+
+	Attachments []*Attachment `db:"-" json:"attachments,omitempty"`
+}
+
+type Attachment struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	MessageID   *uuid.UUID `db:"message_id" json:"-"`
+	UploaderID  uuid.UUID  `db:"uploader_id" json:"-"`
+	StorageKey  string     `db:"storage_key" json:"-"`
+	FileName    string     `db:"file_name" json:"file_name"`
+	ContentType string     `db:"content_type" json:"content_type"`
+	SizeBytes   int64      `db:"size_bytes" json:"size_bytes"`
+	CreatedAt   time.Time  `db:"created_at" json:"-"`
+
+	URL string `db:"-" json:"url,omitempty"`
+}
+
+type UnreadCount struct {
+	PeerID        uuid.UUID `db:"peer_id"`
+	Count         int       `db:"count"`
+	LastMessageAt time.Time `db:"last_message_at"`
+}
+
+type Conversation struct {
+	PeerID        uuid.UUID `db:"peer_id"`
+	LastMessageID uuid.UUID `db:"last_message_id"`
+	LastSenderID  uuid.UUID `db:"last_sender_id"`
+	LastContent   string    `db:"last_content"`
+	LastStatus    string    `db:"last_status"`
+	LastMessageAt time.Time `db:"last_message_at"`
+	UnreadCount   int       `db:"unread_count"`
 }
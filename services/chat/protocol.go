@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const protocolVersion = 1
+
+const (
+	FrameMessage = "message"
+	FrameTyping  = "typing"
+	FrameRead    = "read"
+	FrameEdit    = "edit"
+	FrameDelete  = "delete"
+	FrameAuth    = "auth"
+	FrameAck     = "ack"
+	FrameError   = "error"
+)
+
+const maxContentLength = 4000
+
+var (
+	ErrMalformedFrame     = errors.New("malformed frame")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	ErrUnknownFrameType   = errors.New("unknown frame type")
+	ErrInvalidReceiver    = errors.New("receiver_id must be a uuid")
+	ErrEmptyContent       = errors.New("content or attachment_ids is required")
+	ErrContentTooLong     = errors.New("content is too long")
+	ErrInvalidBookingID   = errors.New("booking_id must be a uuid")
+	ErrInvalidMessageID   = errors.New("message_id must be a uuid")
+	ErrEmptyEdit          = errors.New("content is required")
+	ErrMissingToken       = errors.New("token is required")
+)
+
+// OutgoingMessage is a validated message frame ready for the hub.
+type OutgoingMessage struct {
+	ReceiverID    uuid.UUID
+	Content       string
+	AttachmentIDs []uuid.UUID
+	BookingID     *uuid.UUID
+}
+
+// Frame is the envelope for everything sent over the chat socket in either
+// direction. Ref is chosen by the client and echoed back on the ack or error
+// for that frame so it can match replies to requests.
+type Frame struct {
+	V           int        `json:"v"`
+	Type        string     `json:"type"`
+	Ref         string     `json:"ref,omitempty"`
+	MessageID   string     `json:"message_id,omitempty"`
+	SenderID    string     `json:"sender_id,omitempty"`
+	ReceiverID  string     `json:"receiver_id,omitempty"`
+	Token       string     `json:"token,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	Attachments []string   `json:"attachment_ids,omitempty"`
+	BookingID   string     `json:"booking_id,omitempty"`
+	Typing      *bool      `json:"typing,omitempty"`
+	Message     *Message   `json:"message,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	Updated     int        `json:"updated,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// parseFrame decodes and validates an inbound frame. Frames without a type
+// are treated as the original {receiver_id, content} message format.
+func parseFrame(data []byte) (*Frame, error) {
+	var f Frame
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, ErrMalformedFrame
+	}
+	if f.Type == "" && f.V == 0 {
+		f.Type = FrameMessage
+	}
+	if f.V > protocolVersion {
+		return &f, ErrUnsupportedVersion
+	}
+
+	switch f.Type {
+	case FrameMessage:
+		if f.Content == "" && len(f.Attachments) == 0 {
+			return &f, ErrEmptyContent
+		}
+		if len(f.Attachments) > maxAttachmentsPerMessage {
+			return &f, ErrTooManyAttachments
+		}
+		if len([]rune(f.Content)) > maxContentLength {
+			return &f, ErrContentTooLong
+		}
+	case FrameTyping, FrameRead:
+	case FrameEdit, FrameDelete:
+		if _, err := uuid.Parse(f.MessageID); err != nil {
+			return &f, ErrInvalidMessageID
+		}
+		if f.Type == FrameEdit && f.Content == "" {
+			return &f, ErrEmptyEdit
+		}
+		if len([]rune(f.Content)) > maxContentLength {
+			return &f, ErrContentTooLong
+		}
+		return &f, nil
+	case FrameAuth:
+		if f.Token == "" {
+			return &f, ErrMissingToken
+		}
+		return &f, nil
+	default:
+		return &f, ErrUnknownFrameType
+	}
+
+	if _, err := uuid.Parse(f.ReceiverID); err != nil {
+		return &f, ErrInvalidReceiver
+	}
+	return &f, nil
+}
+
+func (f *Frame) outgoingMessage() (*OutgoingMessage, error) {
+	out := &OutgoingMessage{
+		ReceiverID:    uuid.MustParse(f.ReceiverID),
+		Content:       f.Content,
+		AttachmentIDs: make([]uuid.UUID, 0, len(f.Attachments)),
+	}
+
+	seen := make(map[uuid.UUID]bool, len(f.Attachments))
+	for _, raw := range f.Attachments {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, ErrInvalidAttachmentID
+		}
+		if !seen[id] {
+			seen[id] = true
+			out.AttachmentIDs = append(out.AttachmentIDs, id)
+		}
+	}
+
+	if f.BookingID != "" {
+		id, err := uuid.Parse(f.BookingID)
+		if err != nil {
+			return nil, ErrInvalidBookingID
+		}
+		out.BookingID = &id
+	}
+	return out, nil
+}
+
+func encodeFrame(f *Frame) []byte {
+	f.V = protocolVersion
+	payload, _ := json.Marshal(f)
+	return payload
+}
+
+func messageFrame(msg *Message) []byte {
+	return encodeFrame(&Frame{Type: FrameMessage, Message: msg})
+}
+
+func errorFrame(ref string, err error) []byte {
+	return encodeFrame(&Frame{Type: FrameError, Ref: ref, Error: err.Error()})
+}
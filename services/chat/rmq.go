@@ -3,16 +3,85 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"qasynda/shared/pkg/logger"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+const (
+	messagesQueue   = "chat_messages"
+	deadLetterQueue = "chat_messages.dead"
+
+	maxDeliveryAttempts = 5
+	retryBaseDelay      = time.Second
+	consumerPrefetch    = 20
+
+	headerAttempts  = "x-attempts"
+	headerLastError = "x-last-error"
+	headerFailedAt  = "x-failed-at"
+)
+
+var (
+	ErrPublishNotConfirmed = errors.New("broker did not confirm publish")
+	errConsumerClosed      = errors.New("chat messages channel closed")
+)
+
+// retryQueue returns the name of the delay queue used before the given
+// delivery attempt. Each one holds messages for a fixed TTL and then
+// dead-letters them back onto the main queue.
+func retryQueue(attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", messagesQueue, attempt)
+}
+
+func retryDelay(attempt int) time.Duration {
+	return retryBaseDelay << (attempt - 1)
+}
+
+func declareTopology(ch *amqp.Channel) error {
+	if _, err := ch.QueueDeclare(messagesQueue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+		return err
+	}
+	for attempt := 1; attempt < maxDeliveryAttempts; attempt++ {
+		args := amqp.Table{
+			"x-message-ttl":             int64(retryDelay(attempt) / time.Millisecond),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": messagesQueue,
+		}
+		if _, err := ch.QueueDeclare(retryQueue(attempt), true, false, false, false, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishConfirmed publishes on a channel in confirm mode and waits for the
+// broker to take responsibility for the message.
+func publishConfirmed(ctx context.Context, ch *amqp.Channel, queue string, msg amqp.Publishing) error {
+	msg.DeliveryMode = amqp.Persistent
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, msg)
+	if err != nil {
+		return err
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPublishNotConfirmed
+	}
+	return nil
+}
+
 type RabbitMQProducer struct {
 	conn *amqp.Connection
 	ch   *amqp.Channel
-	q    amqp.Queue
 }
 
 func NewRabbitMQProducer(url string) (*RabbitMQProducer, error) {
@@ -23,25 +92,23 @@ func NewRabbitMQProducer(url string) (*RabbitMQProducer, error) {
 
 	ch, err := conn.Channel()
 	if err != nil {
+		conn.Close()
 		return nil, err
 	}
 
-	q, err := ch.QueueDeclare(
-		"chat_messages",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
+	if err := declareTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
 		return nil, err
 	}
 
 	return &RabbitMQProducer{
 		conn: conn,
 		ch:   ch,
-		q:    q,
 	}, nil
 }
 
@@ -51,16 +118,14 @@ func (p *RabbitMQProducer) PublishMessage(msg *Message) error {
 		return err
 	}
 
-	return p.ch.PublishWithContext(
-		context.Background(),
-		"",
-		p.q.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return publishConfirmed(ctx, p.ch, messagesQueue, amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   msg.ID.String(),
+		Body:        body,
+	})
 }
 
 func (p *RabbitMQProducer) Close() {
@@ -68,46 +133,67 @@ func (p *RabbitMQProducer) Close() {
 	p.conn.Close()
 }
 
-func StartConsumer(ctx context.Context, url string, store *Store) {
+// StartConsumer saves messages from the fallback queue until ctx is
+// cancelled, reconnecting with exponential backoff whenever the connection
+// or channel goes away.
+func StartConsumer(ctx context.Context, url string, store IStore) {
+	wait := consumerReconnectMin
+	for {
+		consumed, err := consumeMessages(ctx, url, store)
+		if ctx.Err() != nil {
+			logger.Info("Chat Consumer stopping...")
+			return
+		}
+		if consumed {
+			wait = consumerReconnectMin
+		}
+		logger.Error("chat consumer disconnected, reconnecting in "+wait.String(), err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, consumerReconnectMax)
+	}
+}
+
+// consumeMessages runs one connection's worth of consuming, like
+// consumeBookingEvents.
+func consumeMessages(ctx context.Context, url string, store IStore) (bool, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
-		logger.Error("failed to connect to rabbitmq consumer", err)
-		return
+		return false, err
 	}
 	defer conn.Close()
 
 	ch, err := conn.Channel()
 	if err != nil {
-		logger.Error("failed to open channel", err)
-		return
+		return false, err
 	}
 	defer ch.Close()
 
-	q, err := ch.QueueDeclare(
-		"chat_messages",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		logger.Error("failed to declare queue", err)
-		return
+	if err := declareTopology(ch); err != nil {
+		return false, err
+	}
+	if err := ch.Qos(consumerPrefetch, 0, false); err != nil {
+		return false, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		return false, err
 	}
 
 	msgs, err := ch.Consume(
-		q.Name,
+		messagesQueue,
 		"",
-		true,
+		false,
 		false,
 		false,
 		false,
 		nil,
 	)
 	if err != nil {
-		logger.Error("failed to register consumer", err)
-		return
+		return false, err
 	}
 
 	logger.Info("Chat Consumer started")
@@ -115,22 +201,84 @@ func StartConsumer(ctx context.Context, url string, store *Store) {
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Info("Chat Consumer stopping...")
-			return
+			return true, ctx.Err()
 		case d, ok := <-msgs:
 			if !ok {
-				logger.Info("Chat Consumer channel closed")
-				return
-			}
-			var msg Message
-			if err := json.Unmarshal(d.Body, &msg); err != nil {
-				logger.Error("failed to unmarshal message", err)
-				continue
+				return true, errConsumerClosed
 			}
+			handleDelivery(ctx, ch, store, d)
+		}
+	}
+}
 
-			if err := store.SaveMessage(context.Background(), &msg); err != nil {
-				logger.Error("failed to save message to db", err)
-			}
+// handleDelivery saves one message and acks it only once it is either stored
+// or safely handed to a retry or dead-letter queue. If even that hand-off
+// fails the delivery is requeued so nothing is lost.
+func handleDelivery(ctx context.Context, ch *amqp.Channel, store IStore, d amqp.Delivery) {
+	var msg Message
+	if err := json.Unmarshal(d.Body, &msg); err != nil {
+		logger.Error("failed to unmarshal message", err)
+		settleFailure(ctx, ch, d, maxDeliveryAttempts, err)
+		return
+	}
+
+	err := store.SaveMessage(ctx, &msg)
+	if err == nil {
+		if err := d.Ack(false); err != nil {
+			logger.Error("failed to ack message", err)
 		}
+		return
+	}
+
+	logger.Error("failed to save message to db", err)
+	attempt := deliveryAttempts(d) + 1
+	if isPermanentError(err) {
+		attempt = maxDeliveryAttempts
+	}
+	settleFailure(ctx, ch, d, attempt, err)
+}
+
+func settleFailure(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, attempt int, cause error) {
+	target := deadLetterQueue
+	if attempt < maxDeliveryAttempts {
+		target = retryQueue(attempt)
+	}
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[headerAttempts] = int32(attempt)
+	headers[headerLastError] = cause.Error()
+	headers[headerFailedAt] = time.Now().UTC()
+
+	err := publishConfirmed(ctx, ch, target, amqp.Publishing{
+		ContentType: d.ContentType,
+		MessageId:   d.MessageId,
+		Headers:     headers,
+		Body:        d.Body,
+	})
+	if err != nil {
+		logger.Error("failed to move message to "+target, err)
+		if err := d.Nack(false, true); err != nil {
+			logger.Error("failed to requeue message", err)
+		}
+		return
+	}
+
+	if err := d.Ack(false); err != nil {
+		logger.Error("failed to ack message", err)
+	}
+}
+
+func deliveryAttempts(d amqp.Delivery) int {
+	switch v := d.Headers[headerAttempts].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
 	}
+	return 0
 }
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
+	"time"
 
 	"qasynda/shared/pkg/logger"
 	"qasynda/shared/pkg/models"
@@ -12,11 +18,15 @@ import (
 )
 
 type Server struct {
-	store *Store
+	store       IStore
+	rmq         *RabbitMQProducer
+	blobs       BlobStore
+	marketplace *MarketplaceClient
+	hub         *Hub
 }
 
-func NewServer(store *Store) *Server {
-	return &Server{store: store}
+func NewServer(store IStore, rmq *RabbitMQProducer, blobs BlobStore, marketplace *MarketplaceClient, hub *Hub) *Server {
+	return &Server{store: store, rmq: rmq, blobs: blobs, marketplace: marketplace, hub: hub}
 }
 
 func (s *Server) GetHistory(c *gin.Context) {
@@ -33,31 +43,456 @@ func (s *Server) GetHistory(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id_1"})
 		return
 	}
-	u2, err := uuid.Parse(userID2)
+
+	var messages []*Message
+	if bookingIDStr := c.Query("booking_id"); bookingIDStr != "" {
+		var bookingID uuid.UUID
+		bookingID, err = uuid.Parse(bookingIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking_id"})
+			return
+		}
+		if err := s.marketplace.CheckParties(c.Request.Context(), bookingID, u1); err != nil {
+			switch {
+			case errors.Is(err, ErrBookingNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, ErrNotBookingParty):
+				c.JSON(http.StatusForbidden, gin.H{"error": "user is not a party to this booking"})
+			default:
+				logger.Error("failed to check booking parties", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			}
+			return
+		}
+		messages, err = s.store.GetBookingHistory(c.Request.Context(), bookingID, limit, offset)
+	} else {
+		var u2 uuid.UUID
+		u2, err = uuid.Parse(userID2)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id_2"})
+			return
+		}
+		messages, err = s.store.GetHistory(c.Request.Context(), u1, u2, limit, offset)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id_2"})
+		logger.Error("failed to get history", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	messages, err := s.store.GetHistory(c.Request.Context(), u1, u2, limit, offset)
-	if err != nil {
-		logger.Error("failed to get history", err)
+	if err := loadAttachments(c.Request.Context(), s.store, s.blobs, messages); err != nil {
+		logger.Error("failed to load attachments", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
 	var respMessages []*models.Message
 	for _, m := range messages {
-		respMessages = append(respMessages, &models.Message{
-			ID:         m.ID.String(),
-			SenderID:   m.SenderID.String(),
-			ReceiverID: m.ReceiverID.String(),
-			Content:    m.Content,
-			Timestamp:  m.CreatedAt.String(),
-		})
+		respMessages = append(respMessages, toMessageResponse(m))
 	}
 
 	c.JSON(http.StatusOK, &models.GetHistoryResponse{
 		Messages: respMessages,
 	})
 }
+
+func (s *Server) ListConversations(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultConversationLimit)))
+	if limit <= 0 || limit > maxConversationLimit {
+		limit = defaultConversationLimit
+	}
+
+	var after *conversationCursor
+	if raw := c.Query("cursor"); raw != "" {
+		after, err = decodeConversationCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	conversations, err := s.store.ListConversations(c.Request.Context(), userID, after, limit+1)
+	if err != nil {
+		logger.Error("failed to list conversations", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.ListConversationsResponse{
+		Conversations: make([]*models.ConversationResponse, 0, len(conversations)),
+	}
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+		last := conversations[limit-1]
+		next := &conversationCursor{LastMessageAt: last.LastMessageAt, PeerID: last.PeerID}
+		resp.NextCursor = next.encode()
+	}
+
+	for _, conv := range conversations {
+		receiverID := conv.PeerID
+		if conv.LastSenderID == conv.PeerID {
+			receiverID = userID
+		}
+		resp.Conversations = append(resp.Conversations, &models.ConversationResponse{
+			PeerID: conv.PeerID.String(),
+			LastMessage: toMessageResponse(&Message{
+				ID:         conv.LastMessageID,
+				SenderID:   conv.LastSenderID,
+				ReceiverID: receiverID,
+				Content:    conv.LastContent,
+				Status:     conv.LastStatus,
+				CreatedAt:  conv.LastMessageAt,
+			}),
+			LastMessageAt: conv.LastMessageAt.Format(time.RFC3339),
+			UnreadCount:   conv.UnreadCount,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) MarkRead(c *gin.Context) {
+	var req models.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	peerID, err := uuid.Parse(req.PeerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid peer_id"})
+		return
+	}
+
+	updated, readAt, err := s.hub.MarkRead(c.Request.Context(), userID, nil, peerID)
+	if err != nil {
+		logger.Error("failed to mark messages read", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.MarkReadResponse{Updated: updated}
+	if updated > 0 {
+		resp.ReadAt = readAt.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) EditMessage(c *gin.Context) {
+	var req models.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+	if req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrEmptyEdit.Error()})
+		return
+	}
+	if len([]rune(req.Content)) > maxContentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrContentTooLong.Error()})
+		return
+	}
+
+	msg, err := s.hub.EditMessage(c.Request.Context(), userID, nil, messageID, req.Content)
+	if err != nil {
+		respondChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toMessageResponse(msg))
+}
+
+func (s *Server) DeleteMessage(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid message id"})
+		return
+	}
+
+	msg, err := s.hub.DeleteMessage(c.Request.Context(), userID, nil, messageID)
+	if err != nil {
+		respondChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toMessageResponse(msg))
+}
+
+func respondChangeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrEditWindowExpired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrProhibitedContent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Error("failed to change message", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
+
+func (s *Server) GetUnread(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	counts, err := s.store.UnreadCounts(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("failed to count unread messages", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.UnreadCountsResponse{
+		Conversations: make([]*models.UnreadConversation, 0, len(counts)),
+	}
+	for _, uc := range counts {
+		resp.Total += uc.Count
+		resp.Conversations = append(resp.Conversations, &models.UnreadConversation{
+			PeerID:        uc.PeerID.String(),
+			Count:         uc.Count,
+			LastMessageAt: uc.LastMessageAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) ListDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > maxDeadLetterBatch {
+		limit = maxDeadLetterBatch
+	}
+
+	letters, total, err := s.rmq.PeekDeadLetters(limit)
+	if err != nil {
+		logger.Error("failed to read dead letters", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.ListDeadLettersResponse{
+		DeadLetters: make([]*models.DeadLetterResponse, 0, len(letters)),
+		Total:       total,
+	}
+	for _, dl := range letters {
+		item := &models.DeadLetterResponse{
+			MessageID: dl.MessageID,
+			Attempts:  dl.Attempts,
+			LastError: dl.LastError,
+		}
+		if dl.Message != nil {
+			item.Message = toMessageResponse(dl.Message)
+		} else {
+			item.Body = string(dl.Body)
+		}
+		if !dl.FailedAt.IsZero() {
+			item.FailedAt = dl.FailedAt.Format(time.RFC3339)
+		}
+		resp.DeadLetters = append(resp.DeadLetters, item)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) ReplayDeadLetters(c *gin.Context) {
+	var req models.ReplayDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Limit <= 0 || req.Limit > maxDeadLetterBatch {
+		req.Limit = maxDeadLetterBatch
+	}
+
+	replayed, err := s.rmq.ReplayDeadLetters(c.Request.Context(), req.MessageIDs, req.Limit)
+	if err != nil {
+		logger.Error("failed to replay dead letters", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error", "replayed": replayed})
+		return
+	}
+
+	c.JSON(http.StatusOK, &models.ReplayDeadLettersResponse{Replayed: replayed})
+}
+
+func (s *Server) UploadAttachment(c *gin.Context) {
+	uploaderID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrAttachmentTooLarge.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": ErrAttachmentTooLarge.Error()})
+		return
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	head = head[:n]
+	if n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+
+	contentType, err := sniffContentType(head)
+	if err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachment := &Attachment{
+		ID:          uuid.New(),
+		UploaderID:  uploaderID,
+		FileName:    sanitizeFileName(header.Filename),
+		ContentType: contentType,
+		SizeBytes:   header.Size,
+		CreatedAt:   time.Now(),
+	}
+	attachment.StorageKey = attachmentKey(uploaderID, attachment.ID, contentType)
+
+	ctx := c.Request.Context()
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := s.blobs.Put(ctx, attachment.StorageKey, body, header.Size, contentType); err != nil {
+		logger.Error("failed to store attachment", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if err := s.store.CreateAttachment(ctx, attachment); err != nil {
+		logger.Error("failed to save attachment", err)
+		if err := s.blobs.Delete(ctx, attachment.StorageKey); err != nil {
+			logger.Error("failed to remove orphaned attachment", err)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	if err := signAttachments(ctx, s.blobs, []*Attachment{attachment}); err != nil {
+		logger.Error("failed to sign attachment url", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toAttachmentResponse(attachment))
+}
+
+// DownloadAttachment serves blobs for stores that sign their own URLs. Stores
+// such as S3 hand out presigned URLs to the bucket and never reach it.
+func (s *Server) DownloadAttachment(c *gin.Context) {
+	fs, ok := s.blobs.(*FileBlobStore)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	key := c.Query("key")
+	if err := fs.Verify(key, c.Query("expires"), c.Query("sig")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	rc, err := fs.Get(c.Request.Context(), key)
+	if errors.Is(err, ErrBlobNotFound) || errors.Is(err, ErrInvalidBlobKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "attachment not found"})
+		return
+	}
+	if err != nil {
+		logger.Error("failed to read attachment", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	defer rc.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, rc, map[string]string{
+		"Cache-Control":          "private, max-age=900",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func toAttachmentResponse(a *Attachment) *models.Attachment {
+	return &models.Attachment{
+		ID:          a.ID.String(),
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		SizeBytes:   a.SizeBytes,
+		URL:         a.URL,
+	}
+}
+
+func toMessageResponse(m *Message) *models.Message {
+	resp := &models.Message{
+		ID:         m.ID.String(),
+		SenderID:   m.SenderID.String(),
+		ReceiverID: m.ReceiverID.String(),
+		Content:    m.Content,
+		Kind:       m.Kind,
+		Status:     m.Status,
+		Timestamp:  m.CreatedAt.String(),
+	}
+	if m.BookingID != nil {
+		resp.BookingID = m.BookingID.String()
+	}
+	if m.EditedAt != nil {
+		resp.EditedAt = m.EditedAt.Format(time.RFC3339)
+	}
+	if m.DeletedAt != nil {
+		resp.DeletedAt = m.DeletedAt.Format(time.RFC3339)
+	}
+	for _, a := range m.Attachments {
+		resp.Attachments = append(resp.Attachments, toAttachmentResponse(a))
+	}
+	return resp
+}
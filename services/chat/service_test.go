@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) SaveMessage(ctx context.Context, msg *Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+func (m *MockStore) CreateAttachment(ctx context.Context, a *Attachment) error {
+	args := m.Called(ctx, a)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetAttachments(ctx context.Context, ids []uuid.UUID) ([]*Attachment, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]*Attachment), args.Error(1)
+}
+
+func (m *MockStore) DeleteUnlinkedAttachments(ctx context.Context, cutoff time.Time, limit int) ([]*Attachment, error) {
+	args := m.Called(ctx, cutoff, limit)
+	return args.Get(0).([]*Attachment), args.Error(1)
+}
+
+func (m *MockStore) ListMessageAttachments(ctx context.Context, messageIDs []uuid.UUID) ([]*Attachment, error) {
+	args := m.Called(ctx, messageIDs)
+	return args.Get(0).([]*Attachment), args.Error(1)
+}
+
+func (m *MockStore) GetMessage(ctx context.Context, id uuid.UUID) (*Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Message), args.Error(1)
+}
+
+func (m *MockStore) EditMessage(ctx context.Context, id, senderID uuid.UUID, content string, editedAt, editableSince time.Time) (bool, error) {
+	args := m.Called(ctx, id, senderID, content, editedAt, editableSince)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) DeleteMessage(ctx context.Context, id, senderID uuid.UUID, deletedAt, editableSince time.Time) (bool, error) {
+	args := m.Called(ctx, id, senderID, deletedAt, editableSince)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) IsBlocked(ctx context.Context, userA, userB uuid.UUID) (bool, error) {
+	args := m.Called(ctx, userA, userB)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) GetBookingHistory(ctx context.Context, bookingID uuid.UUID, limit, offset int) ([]*Message, error) {
+	args := m.Called(ctx, bookingID, limit, offset)
+	return args.Get(0).([]*Message), args.Error(1)
+}
+
+func (m *MockStore) ClaimUndelivered(ctx context.Context, receiverID uuid.UUID, limit int) ([]*Message, error) {
+	args := m.Called(ctx, receiverID, limit)
+	return args.Get(0).([]*Message), args.Error(1)
+}
+
+func (m *MockStore) ReleaseDelivered(ctx context.Context, ids []uuid.UUID) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+func (m *MockStore) MarkDelivered(ctx context.Context, ids []uuid.UUID) (int, error) {
+	args := m.Called(ctx, ids)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) MarkRead(ctx context.Context, readerID, peerID uuid.UUID) (int, time.Time, error) {
+	args := m.Called(ctx, readerID, peerID)
+	return args.Int(0), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockStore) UnreadCounts(ctx context.Context, userID uuid.UUID) ([]*UnreadCount, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*UnreadCount), args.Error(1)
+}
+
+func (m *MockStore) GetHistory(ctx context.Context, userID1, userID2 uuid.UUID, limit, offset int) ([]*Message, error) {
+	args := m.Called(ctx, userID1, userID2, limit, offset)
+	return args.Get(0).([]*Message), args.Error(1)
+}
+
+func (m *MockStore) ListConversations(ctx context.Context, userID uuid.UUID, after *conversationCursor, limit int) ([]*Conversation, error) {
+	args := m.Called(ctx, userID, after, limit)
+	return args.Get(0).([]*Conversation), args.Error(1)
+}
+
+func TestMarkRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	hub := newTestHub(mockStore)
+	server := NewServer(mockStore, nil, nil, nil, hub)
+
+	r := gin.Default()
+	r.POST("/read", server.MarkRead)
+
+	readerID, peerID := uuid.New(), uuid.New()
+	readAt := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+	mockStore.On("MarkRead", mock.Anything, readerID, peerID).Return(3, readAt, nil)
+	mockStore.On("IsBlocked", mock.Anything, readerID, peerID).Return(false, nil)
+	readerConn := connectTestClient(hub, readerID)
+	peerConn := connectTestClient(hub, peerID)
+
+	body, _ := json.Marshal(models.MarkReadRequest{UserID: readerID.String(), PeerID: peerID.String()})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/read", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.MarkReadResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 3, resp.Updated)
+	assert.Equal(t, "2024-03-01T12:30:00Z", resp.ReadAt)
+
+	// Both sides' open sockets get the receipt.
+	for _, conn := range []*Client{readerConn, peerConn} {
+		frames := received(conn)
+		if assert.Len(t, frames, 1) {
+			assert.Equal(t, FrameRead, frames[0].Type)
+			assert.Equal(t, readerID.String(), frames[0].SenderID)
+			assert.Equal(t, 3, frames[0].Updated)
+		}
+	}
+}
+
+func TestGetHistoryStoreError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, nil, nil, nil, nil)
+
+	r := gin.Default()
+	r.GET("/history", server.GetHistory)
+
+	u1, u2 := uuid.New(), uuid.New()
+	mockStore.On("GetHistory", mock.Anything, u1, u2, 20, 0).Return([]*Message(nil), errors.New("db down"))
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/history?user_id_1="+u1.String()+"&user_id_2="+u2.String(), nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
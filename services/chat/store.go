@@ -2,11 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+type IStore interface {
+	SaveMessage(ctx context.Context, msg *Message) error
+	CreateAttachment(ctx context.Context, a *Attachment) error
+	GetAttachments(ctx context.Context, ids []uuid.UUID) ([]*Attachment, error)
+	DeleteUnlinkedAttachments(ctx context.Context, cutoff time.Time, limit int) ([]*Attachment, error)
+	ListMessageAttachments(ctx context.Context, messageIDs []uuid.UUID) ([]*Attachment, error)
+	GetMessage(ctx context.Context, id uuid.UUID) (*Message, error)
+	EditMessage(ctx context.Context, id, senderID uuid.UUID, content string, editedAt, editableSince time.Time) (bool, error)
+	DeleteMessage(ctx context.Context, id, senderID uuid.UUID, deletedAt, editableSince time.Time) (bool, error)
+	IsBlocked(ctx context.Context, userA, userB uuid.UUID) (bool, error)
+	GetBookingHistory(ctx context.Context, bookingID uuid.UUID, limit, offset int) ([]*Message, error)
+	ClaimUndelivered(ctx context.Context, receiverID uuid.UUID, limit int) ([]*Message, error)
+	ReleaseDelivered(ctx context.Context, ids []uuid.UUID) error
+	MarkDelivered(ctx context.Context, ids []uuid.UUID) (int, error)
+	MarkRead(ctx context.Context, readerID, peerID uuid.UUID) (int, time.Time, error)
+	UnreadCounts(ctx context.Context, userID uuid.UUID) ([]*UnreadCount, error)
+	GetHistory(ctx context.Context, userID1, userID2 uuid.UUID, limit, offset int) ([]*Message, error)
+	ListConversations(ctx context.Context, userID uuid.UUID, after *conversationCursor, limit int) ([]*Conversation, error)
+}
+
 type Store struct {
 	db *sqlx.DB
 }
@@ -15,15 +38,282 @@ func NewStore(db *sqlx.DB) *Store {
 	return &Store{db: db}
 }
 
+// SaveMessage inserts the message and links its uploaded attachments in one
+// transaction. It is idempotent so a redelivered message is harmless.
 func (s *Store) SaveMessage(ctx context.Context, msg *Message) error {
 	query := `
-		INSERT INTO messages (id, sender_id, receiver_id, content, created_at)
-		VALUES (:id, :sender_id, :receiver_id, :content, :created_at)
+		INSERT INTO messages (id, sender_id, receiver_id, content, booking_id, kind, status, delivered_at, created_at)
+		VALUES (:id, :sender_id, :receiver_id, :content, :booking_id, :kind, :status, :delivered_at, :created_at)
+		ON CONFLICT (id) DO NOTHING
+	`
+	if msg.Status == "" {
+		msg.Status = MessageSent
+	}
+	if msg.Kind == "" {
+		msg.Kind = KindUser
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, query, msg); err != nil {
+		return err
+	}
+
+	if len(msg.Attachments) > 0 {
+		ids := make([]uuid.UUID, 0, len(msg.Attachments))
+		for _, a := range msg.Attachments {
+			ids = append(ids, a.ID)
+		}
+		linkQuery := `
+			UPDATE message_attachments SET message_id = $1
+			WHERE id = ANY($2) AND uploader_id = $3 AND message_id IS NULL
+		`
+		if _, err := tx.ExecContext(ctx, linkQuery, msg.ID, pq.Array(ids), msg.SenderID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) CreateAttachment(ctx context.Context, a *Attachment) error {
+	query := `
+		INSERT INTO message_attachments (id, uploader_id, storage_key, file_name, content_type, size_bytes, created_at)
+		VALUES (:id, :uploader_id, :storage_key, :file_name, :content_type, :size_bytes, :created_at)
+	`
+	_, err := s.db.NamedExecContext(ctx, query, a)
+	return err
+}
+
+func (s *Store) GetAttachments(ctx context.Context, ids []uuid.UUID) ([]*Attachment, error) {
+	var attachments []*Attachment
+	query := `SELECT * FROM message_attachments WHERE id = ANY($1)`
+	err := s.db.SelectContext(ctx, &attachments, query, pq.Array(ids))
+	return attachments, err
+}
+
+// DeleteUnlinkedAttachments removes up to limit uploads created before
+// cutoff that never made it onto a message, and returns them so their blobs
+// can be deleted too. Rows a sender is linking right now are skipped.
+func (s *Store) DeleteUnlinkedAttachments(ctx context.Context, cutoff time.Time, limit int) ([]*Attachment, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var attachments []*Attachment
+	query := `
+		SELECT * FROM message_attachments
+		WHERE message_id IS NULL AND created_at < $1
+		ORDER BY created_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	if err := tx.SelectContext(ctx, &attachments, query, cutoff, limit); err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(attachments))
+	for _, a := range attachments {
+		ids = append(ids, a.ID)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_attachments WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return attachments, tx.Commit()
+}
+
+func (s *Store) ListMessageAttachments(ctx context.Context, messageIDs []uuid.UUID) ([]*Attachment, error) {
+	var attachments []*Attachment
+	query := `
+		SELECT * FROM message_attachments
+		WHERE message_id = ANY($1)
+		ORDER BY created_at, id
+	`
+	err := s.db.SelectContext(ctx, &attachments, query, pq.Array(messageIDs))
+	return attachments, err
+}
+
+func (s *Store) GetMessage(ctx context.Context, id uuid.UUID) (*Message, error) {
+	var msg Message
+	query := `SELECT * FROM messages WHERE id = $1`
+	if err := s.db.GetContext(ctx, &msg, query, id); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// EditMessage replaces the content of a live user message sent by senderID
+// after editableSince. It reports whether a row matched.
+func (s *Store) EditMessage(ctx context.Context, id, senderID uuid.UUID, content string, editedAt, editableSince time.Time) (bool, error) {
+	query := `
+		UPDATE messages SET content = $1, edited_at = $2
+		WHERE id = $3 AND sender_id = $4 AND kind = 'user' AND deleted_at IS NULL AND created_at > $5
+	`
+	res, err := s.db.ExecContext(ctx, query, content, editedAt, id, senderID, editableSince)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteMessage soft-deletes a message under the same conditions as
+// EditMessage. The content is cleared; the row stays so the conversation
+// keeps its shape.
+func (s *Store) DeleteMessage(ctx context.Context, id, senderID uuid.UUID, deletedAt, editableSince time.Time) (bool, error) {
+	query := `
+		UPDATE messages SET content = '', deleted_at = $1
+		WHERE id = $2 AND sender_id = $3 AND kind = 'user' AND deleted_at IS NULL AND created_at > $4
+	`
+	res, err := s.db.ExecContext(ctx, query, deletedAt, id, senderID, editableSince)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *Store) IsBlocked(ctx context.Context, userA, userB uuid.UUID) (bool, error) {
+	var blocked bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
 	`
-	_, err := s.db.NamedExecContext(ctx, query, msg)
+	err := s.db.GetContext(ctx, &blocked, query, userA, userB)
+	return blocked, err
+}
+
+func (s *Store) GetBookingHistory(ctx context.Context, bookingID uuid.UUID, limit, offset int) ([]*Message, error) {
+	var messages []*Message
+	query := `
+		SELECT * FROM messages
+		WHERE booking_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	err := s.db.SelectContext(ctx, &messages, query, bookingID, limit, offset)
+	return messages, err
+}
+
+// ClaimUndelivered marks up to limit of the receiver's undelivered messages
+// delivered and returns them, oldest first. Rows another session is claiming
+// at the same time are skipped, so each message is handed out once. Messages
+// between users who have since blocked each other stay undelivered.
+func (s *Store) ClaimUndelivered(ctx context.Context, receiverID uuid.UUID, limit int) ([]*Message, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var messages []*Message
+	query := `
+		SELECT * FROM messages m
+		WHERE m.receiver_id = $1 AND m.status = 'sent'
+		  AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = m.sender_id AND b.blocked_id = m.receiver_id)
+			   OR (b.blocker_id = m.receiver_id AND b.blocked_id = m.sender_id)
+		  )
+		ORDER BY m.created_at ASC
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	if err := tx.SelectContext(ctx, &messages, query, receiverID, limit); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	ids := make([]uuid.UUID, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+		msg.Status = MessageDelivered
+		msg.DeliveredAt = &now
+	}
+	update := `UPDATE messages SET status = 'delivered', delivered_at = $2 WHERE id = ANY($1)`
+	if _, err := tx.ExecContext(ctx, update, pq.Array(ids), now); err != nil {
+		return nil, err
+	}
+	return messages, tx.Commit()
+}
+
+// ReleaseDelivered puts claimed messages that never reached a session back
+// to sent.
+func (s *Store) ReleaseDelivered(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `
+		UPDATE messages SET status = 'sent', delivered_at = NULL
+		WHERE id = ANY($1) AND status = 'delivered'
+	`
+	_, err := s.db.ExecContext(ctx, query, pq.Array(ids))
 	return err
 }
 
+func (s *Store) MarkDelivered(ctx context.Context, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	query := `
+		UPDATE messages SET status = 'delivered', delivered_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($1) AND status = 'sent'
+	`
+	res, err := s.db.ExecContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// MarkRead marks every message peerID has sent to readerID as read and
+// returns how many changed along with the read_at it stored.
+func (s *Store) MarkRead(ctx context.Context, readerID, peerID uuid.UUID) (int, time.Time, error) {
+	now := time.Now()
+	query := `
+		UPDATE messages
+		SET status = 'read',
+		    read_at = $3,
+		    delivered_at = COALESCE(delivered_at, $3)
+		WHERE receiver_id = $1 AND sender_id = $2 AND status <> 'read'
+	`
+	res, err := s.db.ExecContext(ctx, query, readerID, peerID, now)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), now, err
+}
+
+func (s *Store) UnreadCounts(ctx context.Context, userID uuid.UUID) ([]*UnreadCount, error) {
+	var counts []*UnreadCount
+	query := `
+		SELECT sender_id AS peer_id, COUNT(*) AS count, MAX(created_at) AS last_message_at
+		FROM messages
+		WHERE receiver_id = $1 AND status <> 'read'
+		GROUP BY sender_id
+		ORDER BY last_message_at DESC
+	`
+	err := s.db.SelectContext(ctx, &counts, query, userID)
+	return counts, err
+}
+
 func (s *Store) GetHistory(ctx context.Context, userID1, userID2 uuid.UUID, limit, offset int) ([]*Message, error) {
 	var messages []*Message
 	query := `
@@ -35,3 +325,55 @@ func (s *Store) GetHistory(ctx context.Context, userID1, userID2 uuid.UUID, limi
 	err := s.db.SelectContext(ctx, &messages, query, userID1, userID2, limit, offset)
 	return messages, err
 }
+
+// ListConversations returns the user's conversation partners with the latest
+// message exchanged with each, newest first, starting after the cursor.
+func (s *Store) ListConversations(ctx context.Context, userID uuid.UUID, after *conversationCursor, limit int) ([]*Conversation, error) {
+	var afterAt *time.Time
+	var afterPeer *uuid.UUID
+	if after != nil {
+		afterAt, afterPeer = &after.LastMessageAt, &after.PeerID
+	}
+
+	var conversations []*Conversation
+	query := `
+		WITH latest AS (
+			SELECT DISTINCT ON (peer_id) peer_id, id, sender_id, content, status, created_at
+			FROM (
+				SELECT CASE WHEN sender_id = $1 THEN receiver_id ELSE sender_id END AS peer_id,
+				       id, sender_id, content, status, created_at
+				FROM messages
+				WHERE sender_id = $1 OR receiver_id = $1
+			) m
+			ORDER BY peer_id, created_at DESC, id DESC
+		)
+		SELECT l.peer_id,
+		       l.id AS last_message_id,
+		       l.sender_id AS last_sender_id,
+		       l.content AS last_content,
+		       l.status AS last_status,
+		       l.created_at AS last_message_at,
+		       (SELECT COUNT(*) FROM messages u
+		        WHERE u.receiver_id = $1 AND u.sender_id = l.peer_id AND u.status <> 'read') AS unread_count
+		FROM latest l
+		WHERE $2::timestamp IS NULL OR (l.created_at, l.peer_id) < ($2::timestamp, $3::uuid)
+		ORDER BY l.created_at DESC, l.peer_id DESC
+		LIMIT $4
+	`
+	err := s.db.SelectContext(ctx, &conversations, query, userID, afterAt, afterPeer, limit)
+	return conversations, err
+}
+
+// isPermanentError reports whether retrying the insert can never succeed, for
+// example when the sender or receiver does not exist.
+func isPermanentError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code.Class() {
+	case "22", "23":
+		return true
+	}
+	return false
+}
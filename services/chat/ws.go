@@ -3,50 +3,152 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"qasynda/shared/pkg/auth"
 	"qasynda/shared/pkg/logger"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	bearerProtocol     = "bearer"
+	authTimeout        = 10 * time.Second
+	maxUndeliveredPush = 200
+)
+
+var (
+	errAuthRequired = errors.New("authentication required")
+	errReadFailed   = errors.New("could not mark messages read")
+	errSendFailed   = errors.New("could not send message")
+	errEditFailed   = errors.New("could not change message")
+	errTokenUser    = errors.New("token belongs to another user")
+
+	errAccountCheckFailed = errors.New("could not verify account, try again later")
+	errTokenRevoked       = errors.New("token revoked")
+
+	ErrUserBlocked = errors.New("you cannot message this user")
+)
+
+// clientErrors are returned to the client as they are; anything else is
+// logged and replaced with a generic error frame.
+var clientErrors = []error{
+	ErrAttachmentNotFound, ErrTooManyAttachments,
+	ErrBookingNotFound, ErrNotBookingParty, ErrUserBlocked,
+	ErrProhibitedContent, ErrMessageNotFound, ErrEditWindowExpired,
+}
+
+func isClientError(err error) bool {
+	for _, target := range clientErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{bearerProtocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
 type Client struct {
-	hub    *Hub
-	conn   *websocket.Conn
-	send   chan []byte
-	userID string
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	userID    string
+	keys      auth.Keys
+	expiresAt time.Time
+	renew     chan time.Time
+	// kick carries the reason the server is closing the connection.
+	kick chan string
 }
 
+// Hub tracks every open connection of every user, so a user with several
+// tabs or devices receives each message on all of them.
 type Hub struct {
-	clients    map[string]*Client
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan []byte
-	mu         sync.RWMutex
-	store      *Store
-	rmq        *RabbitMQProducer
+	clients     map[string]map[*Client]struct{}
+	register    chan *Client
+	unregister  chan *Client
+	broadcast   chan []byte
+	mu          sync.RWMutex
+	store       IStore
+	rmq         *RabbitMQProducer
+	bus         *Bus
+	blobs       BlobStore
+	marketplace *MarketplaceClient
+	accounts    *AccountClient
+	revoked     *auth.RevocationList
+	filter      ContentFilter
 }
 
-func NewHub(store *Store, rmq *RabbitMQProducer) *Hub {
+func NewHub(store IStore, rmq *RabbitMQProducer, bus *Bus, blobs BlobStore, marketplace *MarketplaceClient, accounts *AccountClient, revoked *auth.RevocationList, filter ContentFilter) *Hub {
 	return &Hub{
-		clients:    make(map[string]*Client),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan []byte),
-		store:      store,
-		rmq:        rmq,
+		clients:     make(map[string]map[*Client]struct{}),
+		register:    make(chan *Client),
+		unregister:  make(chan *Client),
+		broadcast:   make(chan []byte),
+		store:       store,
+		rmq:         rmq,
+		bus:         bus,
+		blobs:       blobs,
+		marketplace: marketplace,
+		accounts:    accounts,
+		revoked:     revoked,
+		filter:      filter,
+	}
+}
+
+// authenticate verifies token, checks it has not been revoked and asks the
+// user service whether its account may still be used, so signed-out and
+// suspended users cannot connect or renew.
+func (h *Hub) authenticate(ctx context.Context, token string, keys auth.Keys) (*auth.Claims, error) {
+	claims, err := auth.ParseToken(token, keys)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(claims.UserID); err != nil {
+		return nil, auth.ErrInvalidToken
+	}
+	if h.revoked != nil {
+		revoked, err := h.revoked.IsRevoked(ctx, claims)
+		if err != nil {
+			return nil, fmt.Errorf("check token revocation: %w", err)
+		}
+		if revoked {
+			return nil, errTokenRevoked
+		}
 	}
+	if h.accounts != nil {
+		if err := h.accounts.Check(ctx, token); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// authStatus maps an authenticate error to the status of a rejected upgrade.
+func authStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrAccountSuspended):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, errTokenRevoked):
+		return http.StatusUnauthorized, err.Error()
+	case errors.Is(err, auth.ErrInvalidToken), errors.Is(err, errAccountInvalid):
+		return http.StatusUnauthorized, "invalid token"
+	}
+	logger.Error("failed to verify chat account", err)
+	return http.StatusServiceUnavailable, errAccountCheckFailed.Error()
 }
 
 func (h *Hub) Run(ctx context.Context) {
@@ -56,54 +158,378 @@ func (h *Hub) Run(ctx context.Context) {
 			logger.Info("Chat Hub stopping...")
 			return
 		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client.userID] = client
-			h.mu.Unlock()
+			h.addClient(client)
 			logger.Info("Client registered: " + client.userID)
+			go h.pushUndelivered(ctx, client)
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client.userID]; ok {
-				delete(h.clients, client.userID)
-				close(client.send)
-			}
-			h.mu.Unlock()
+			h.removeClient(client)
 			logger.Info("Client unregistered: " + client.userID)
 		}
 	}
 }
 
-func (h *Hub) SendPrivateMessage(senderID, receiverID, content string) {
-	msg := &Message{
-		ID:         uuid.New(),
-		SenderID:   uuid.MustParse(senderID),
-		ReceiverID: uuid.MustParse(receiverID),
-		Content:    content,
-		CreatedAt:  time.Now(),
+func (h *Hub) syncPresence(userID string) {
+	if h.bus != nil {
+		h.bus.Sync(userID, h.isOnline)
 	}
+}
 
-	go func() {
-		if err := h.rmq.PublishMessage(msg); err != nil {
-			logger.Error("failed to publish message", err)
-		}
-	}()
+func (h *Hub) addClient(client *Client) {
+	h.mu.Lock()
+	sessions, ok := h.clients[client.userID]
+	if !ok {
+		sessions = make(map[*Client]struct{})
+		h.clients[client.userID] = sessions
+	}
+	sessions[client] = struct{}{}
+	h.mu.Unlock()
+
+	h.syncPresence(client.userID)
+}
 
+// removeClient drops a single connection and closes its send channel. The
+// user's other sessions are left alone. It is safe to call more than once.
+func (h *Hub) removeClient(client *Client) {
+	h.mu.Lock()
+	sessions := h.clients[client.userID]
+	if _, ok := sessions[client]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(sessions, client)
+	close(client.send)
+	if len(sessions) == 0 {
+		delete(h.clients, client.userID)
+	}
+	h.mu.Unlock()
+
+	h.syncPresence(client.userID)
+}
+
+func (h *Hub) isOnline(userID string) bool {
 	h.mu.RLock()
-	receiver, ok := h.clients[receiverID]
-	h.mu.RUnlock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0
+}
+
+// sendTo queues payload on one connection if it is still registered.
+func (h *Hub) sendTo(client *Client, payload []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	payload, _ := json.Marshal(msg)
+	if _, ok := h.clients[client.userID][client]; !ok {
+		return false
+	}
+	select {
+	case client.send <- payload:
+		return true
+	default:
+		return false
+	}
+}
 
-	if ok {
+// pushUndelivered sends a freshly connected client everything that arrived
+// while the user had no open sessions. Messages are claimed before they are
+// sent, and whatever the client did not take is released again.
+func (h *Hub) pushUndelivered(ctx context.Context, client *Client) {
+	receiverID, err := uuid.Parse(client.userID)
+	if err != nil {
+		return
+	}
+
+	messages, err := h.store.ClaimUndelivered(ctx, receiverID, maxUndeliveredPush)
+	if err != nil {
+		logger.Error("failed to load undelivered messages", err)
+		return
+	}
+
+	sent := 0
+	if err := loadAttachments(ctx, h.store, h.blobs, messages); err != nil {
+		logger.Error("failed to load attachments", err)
+	} else {
+		for _, msg := range messages {
+			if !h.sendTo(client, messageFrame(msg)) {
+				break
+			}
+			sent++
+		}
+	}
+
+	unsent := make([]uuid.UUID, 0, len(messages)-sent)
+	for _, msg := range messages[sent:] {
+		unsent = append(unsent, msg.ID)
+	}
+	if err := h.store.ReleaseDelivered(ctx, unsent); err != nil {
+		logger.Error("failed to release undelivered messages", err)
+	}
+}
+
+// deliver sends payload to the user's sessions on this node and only goes
+// through the bus when none of them took it, so frames between users on the
+// same node, typing included, never leave it.
+func (h *Hub) deliver(userID string, payload []byte, skip *Client) {
+	if h.deliverLocal(userID, payload, skip) > 0 {
+		return
+	}
+	h.publish(userID, payload)
+}
+
+func (h *Hub) publish(userID string, payload []byte) {
+	if h.bus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.bus.Publish(ctx, userID, payload); err != nil {
+		logger.Error("failed to publish chat event", err)
+	}
+}
+
+// claimDelivered marks a stored message delivered unless someone else got
+// to it first, and reports whether this caller may deliver it.
+func (h *Hub) claimDelivered(ctx context.Context, msg *Message) bool {
+	n, err := h.store.MarkDelivered(ctx, []uuid.UUID{msg.ID})
+	if err != nil {
+		logger.Error("failed to mark message delivered", err)
+		return false
+	}
+	if n == 0 {
+		return false
+	}
+	now := time.Now()
+	msg.Status = MessageDelivered
+	msg.DeliveredAt = &now
+	return true
+}
+
+// deliverMessage pushes a stored message to both users. A message stored as
+// sent has to be claimed before the receiver sees it, so it reaches them
+// either here, on the node that claims it, or through pushUndelivered, but
+// only once.
+func (h *Hub) deliverMessage(ctx context.Context, msg *Message, skip *Client) {
+	receiverID := msg.ReceiverID.String()
+	if msg.Status == MessageSent && h.isOnline(receiverID) {
+		h.claimDelivered(ctx, msg)
+	}
+
+	payload := messageFrame(msg)
+	if msg.Status == MessageSent {
+		h.publish(receiverID, payload)
+	} else {
+		h.deliver(receiverID, payload, skip)
+	}
+	if msg.SenderID != msg.ReceiverID {
+		h.deliver(msg.SenderID.String(), payload, skip)
+	}
+}
+
+// receiveRemote delivers a frame published by another node. A message that
+// node could not deliver is claimed here first, and released again if none
+// of the receiver's sessions took it after all.
+func (h *Hub) receiveRemote(userID string, payload []byte) {
+	var f Frame
+	if err := json.Unmarshal(payload, &f); err != nil || f.Type != FrameMessage || f.Message == nil ||
+		f.Message.Status != MessageSent || f.Message.ReceiverID.String() != userID {
+		h.deliverLocal(userID, payload, nil)
+		return
+	}
+
+	ctx := context.Background()
+	if !h.isOnline(userID) || !h.claimDelivered(ctx, f.Message) {
+		return
+	}
+	if h.deliverLocal(userID, messageFrame(f.Message), nil) == 0 {
+		if err := h.store.ReleaseDelivered(ctx, []uuid.UUID{f.Message.ID}); err != nil {
+			logger.Error("failed to release undelivered message", err)
+		}
+	}
+}
+
+// deliverLocal fans payload out to every connection of userID on this node
+// except skip and reports how many took it. A connection whose buffer is full
+// is dropped rather than blocking the rest.
+func (h *Hub) deliverLocal(userID string, payload []byte, skip *Client) int {
+	var slow []*Client
+	delivered := 0
+
+	// Sends happen under the read lock so removeClient cannot close a channel
+	// mid-send; they never block, so holding it is cheap.
+	h.mu.RLock()
+	for client := range h.clients[userID] {
+		if client == skip {
+			continue
+		}
 		select {
-		case receiver.send <- payload:
+		case client.send <- payload:
+			delivered++
 		default:
-			close(receiver.send)
-			h.mu.Lock()
-			delete(h.clients, receiverID)
-			h.mu.Unlock()
+			slow = append(slow, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range slow {
+		h.removeClient(client)
+	}
+	return delivered
+}
+
+// SendPrivateMessage delivers a message to all of the receiver's sessions and
+// echoes it to the sender's other sessions. origin is the connection the
+// message was written on.
+func (h *Hub) SendPrivateMessage(ctx context.Context, origin *Client, out *OutgoingMessage) (*Message, error) {
+	senderID := uuid.MustParse(origin.userID)
+	receiverID := out.ReceiverID.String()
+
+	if senderID != out.ReceiverID {
+		blocked, err := h.store.IsBlocked(ctx, senderID, out.ReceiverID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrUserBlocked
+		}
+	}
+
+	if out.BookingID != nil {
+		if err := h.marketplace.CheckParties(ctx, *out.BookingID, senderID, out.ReceiverID); err != nil {
+			return nil, err
+		}
+	}
+
+	content := out.Content
+	if content != "" {
+		var err error
+		if content, err = h.filter.Filter(content); err != nil {
+			return nil, err
+		}
+	}
+
+	attachments, err := claimAttachments(ctx, h.store, senderID, out.AttachmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	if err := signAttachments(ctx, h.blobs, attachments); err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		ID:          uuid.New(),
+		SenderID:    senderID,
+		ReceiverID:  out.ReceiverID,
+		Content:     content,
+		BookingID:   out.BookingID,
+		Kind:        KindUser,
+		Status:      MessageSent,
+		CreatedAt:   time.Now(),
+		Attachments: attachments,
+	}
+	if h.isOnline(receiverID) {
+		msg.Status = MessageDelivered
+		msg.DeliveredAt = &msg.CreatedAt
+	}
+
+	// The message is stored before anyone hears about it, so the sender's
+	// ack means it is safe and pushUndelivered can never race the insert.
+	if err := h.store.SaveMessage(ctx, msg); err != nil {
+		if isPermanentError(err) {
+			return nil, err
+		}
+		// While the database is struggling the queue keeps the message and
+		// retries the insert. It is not shown live; the receiver gets it
+		// from pushUndelivered once it is stored.
+		logger.Error("failed to save message, queueing it", err)
+		msg.Status = MessageSent
+		msg.DeliveredAt = nil
+		if err := h.rmq.PublishMessage(msg); err != nil {
+			return nil, err
 		}
+		return msg, nil
+	}
+
+	h.deliverMessage(ctx, msg, origin)
+	return msg, nil
+}
+
+// SendSystemMessage stores a message generated by the platform and pushes it
+// to every session of both users.
+func (h *Hub) SendSystemMessage(ctx context.Context, msg *Message) error {
+	msg.Kind = KindSystem
+	msg.Status = MessageSent
+	if h.isOnline(msg.ReceiverID.String()) {
+		msg.Status = MessageDelivered
+		msg.DeliveredAt = &msg.CreatedAt
+	}
+
+	if err := h.store.SaveMessage(ctx, msg); err != nil {
+		return err
 	}
 
+	h.deliverMessage(ctx, msg, nil)
+	return nil
+}
+
+// peerBlocked reports whether userID and peerID have blocked each other. A
+// failed lookup counts as blocked, so nothing slips through while the store
+// is unavailable.
+func (h *Hub) peerBlocked(ctx context.Context, userID, peerID string) bool {
+	if userID == peerID {
+		return false
+	}
+	a, errA := uuid.Parse(userID)
+	b, errB := uuid.Parse(peerID)
+	if errA != nil || errB != nil {
+		return true
+	}
+	blocked, err := h.store.IsBlocked(ctx, a, b)
+	if err != nil {
+		logger.Error("failed to check block", err)
+		return true
+	}
+	return blocked
+}
+
+// relay sends a frame about a conversation to the other party, unless one of
+// them has blocked the other, and to userID's own sessions other than skip.
+func (h *Hub) relay(ctx context.Context, userID string, skip *Client, peerID string, payload []byte) {
+	if !h.peerBlocked(ctx, userID, peerID) {
+		h.deliver(peerID, payload, skip)
+	}
+	if userID != peerID {
+		h.deliver(userID, payload, skip)
+	}
+}
+
+// SendTyping tells peerID's sessions whether the origin's user is typing.
+func (h *Hub) SendTyping(ctx context.Context, origin *Client, peerID string, typing bool) {
+	if h.peerBlocked(ctx, origin.userID, peerID) {
+		return
+	}
+	h.deliver(peerID, encodeFrame(&Frame{
+		Type:       FrameTyping,
+		SenderID:   origin.userID,
+		ReceiverID: peerID,
+		Typing:     &typing,
+	}), origin)
+}
+
+// MarkRead records that readerID has read everything peerID sent them and
+// sends the receipt to both sides in real time. skip is the session that asked,
+// if any, which gets its own acknowledgement instead.
+func (h *Hub) MarkRead(ctx context.Context, readerID uuid.UUID, skip *Client, peerID uuid.UUID) (int, time.Time, error) {
+	updated, readAt, err := h.store.MarkRead(ctx, readerID, peerID)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	h.relay(ctx, readerID.String(), skip, peerID.String(), encodeFrame(&Frame{
+		Type:       FrameRead,
+		SenderID:   readerID.String(),
+		ReceiverID: peerID.String(),
+		ReadAt:     &readAt,
+		Updated:    updated,
+	}))
+	return updated, readAt, nil
 }
 
 func (c *Client) readPump() {
@@ -117,20 +543,116 @@ func (c *Client) readPump() {
 			break
 		}
 
-		var req struct {
-			ReceiverID string `json:"receiver_id"`
-			Content    string `json:"content"`
+		frame, err := parseFrame(message)
+		if err == nil {
+			err = c.handleFrame(frame)
 		}
-		if err := json.Unmarshal(message, &req); err != nil {
-			logger.Error("invalid message format", err)
-			continue
+		if err != nil {
+			ref := ""
+			if frame != nil {
+				ref = frame.Ref
+			}
+			c.hub.sendTo(c, errorFrame(ref, err))
 		}
+	}
+}
 
-		c.hub.SendPrivateMessage(c.userID, req.ReceiverID, req.Content)
+func (c *Client) handleFrame(f *Frame) error {
+	switch f.Type {
+	case FrameMessage:
+		out, err := f.outgoingMessage()
+		if err != nil {
+			return err
+		}
+		msg, err := c.hub.SendPrivateMessage(context.Background(), c, out)
+		if isClientError(err) {
+			return err
+		}
+		if err != nil {
+			logger.Error("failed to send message", err)
+			return errSendFailed
+		}
+		c.hub.sendTo(c, encodeFrame(&Frame{Type: FrameAck, Ref: f.Ref, Message: msg}))
+	case FrameTyping:
+		c.hub.SendTyping(context.Background(), c, f.ReceiverID, f.Typing == nil || *f.Typing)
+	case FrameRead:
+		readerID, peerID := uuid.MustParse(c.userID), uuid.MustParse(f.ReceiverID)
+		updated, readAt, err := c.hub.MarkRead(context.Background(), readerID, c, peerID)
+		if err != nil {
+			logger.Error("failed to mark messages read", err)
+			return errReadFailed
+		}
+		c.hub.sendTo(c, encodeFrame(&Frame{Type: FrameAck, Ref: f.Ref, ReadAt: &readAt, Updated: updated}))
+	case FrameEdit, FrameDelete:
+		senderID, messageID := uuid.MustParse(c.userID), uuid.MustParse(f.MessageID)
+		var msg *Message
+		var err error
+		if f.Type == FrameEdit {
+			msg, err = c.hub.EditMessage(context.Background(), senderID, c, messageID, f.Content)
+		} else {
+			msg, err = c.hub.DeleteMessage(context.Background(), senderID, c, messageID)
+		}
+		if isClientError(err) {
+			return err
+		}
+		if err != nil {
+			logger.Error("failed to change message", err)
+			return errEditFailed
+		}
+		c.hub.sendTo(c, encodeFrame(&Frame{Type: FrameAck, Ref: f.Ref, Message: msg}))
+	case FrameAuth:
+		// Access tokens are short-lived, so clients send a fresh one before
+		// the current one runs out to keep the socket open. A revoked token
+		// or suspended account is disconnected straight away.
+		claims, err := c.hub.authenticate(context.Background(), f.Token, c.keys)
+		if errors.Is(err, ErrAccountSuspended) || errors.Is(err, errAccountInvalid) || errors.Is(err, errTokenRevoked) {
+			c.close(err.Error())
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if claims.UserID != c.userID {
+			return errTokenUser
+		}
+		if claims.ExpiresAt != nil {
+			select {
+			case <-c.renew:
+			default:
+			}
+			c.renew <- claims.ExpiresAt.Time
+		}
+		c.hub.sendTo(c, encodeFrame(&Frame{Type: FrameAck, Ref: f.Ref}))
+	}
+	return nil
+}
+
+// close asks writePump to close the connection with reason.
+func (c *Client) close(reason string) {
+	select {
+	case c.kick <- reason:
+	default:
 	}
 }
 
 func (c *Client) writePump() {
+	var expiry *time.Timer
+	var expired <-chan time.Time
+	setExpiry := func(at time.Time) {
+		if expiry != nil {
+			expiry.Stop()
+		}
+		expiry = time.NewTimer(time.Until(at))
+		expired = expiry.C
+	}
+	if !c.expiresAt.IsZero() {
+		setExpiry(c.expiresAt)
+	}
+	defer func() {
+		if expiry != nil {
+			expiry.Stop()
+		}
+	}()
 	defer c.conn.Close()
 	for {
 		select {
@@ -140,15 +662,30 @@ func (c *Client) writePump() {
 				return
 			}
 			c.conn.WriteMessage(websocket.TextMessage, message)
+		case at := <-c.renew:
+			setExpiry(at)
+		case reason := <-c.kick:
+			closeWithReason(c.conn, websocket.ClosePolicyViolation, reason)
+			return
+		case <-expired:
+			closeWithReason(c.conn, websocket.ClosePolicyViolation, "token expired")
+			return
 		}
 	}
 }
 
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		http.Error(w, "user_id required", http.StatusUnauthorized)
-		return
+func ServeWs(hub *Hub, keys auth.Keys, w http.ResponseWriter, r *http.Request) {
+	token, fromHandshake := handshakeToken(r)
+
+	var claims *auth.Claims
+	if fromHandshake {
+		var err error
+		claims, err = hub.authenticate(r.Context(), token, keys)
+		if err != nil {
+			status, msg := authStatus(err)
+			http.Error(w, msg, status)
+			return
+		}
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -157,9 +694,84 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), userID: userID}
+	if claims == nil {
+		claims, err = readAuthFrame(r.Context(), conn, hub, keys)
+		if err != nil {
+			code := websocket.ClosePolicyViolation
+			if errors.Is(err, errAccountCheckFailed) {
+				code = websocket.CloseTryAgainLater
+			}
+			closeWithReason(conn, code, err.Error())
+			conn.Close()
+			return
+		}
+	}
+
+	client := &Client{
+		hub:    hub,
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		userID: claims.UserID,
+		keys:   keys,
+		renew:  make(chan time.Time, 1),
+		kick:   make(chan string, 1),
+	}
+	if claims.ExpiresAt != nil {
+		client.expiresAt = claims.ExpiresAt.Time
+	}
 	client.hub.register <- client
 
 	go client.writePump()
 	go client.readPump()
 }
+
+// handshakeToken looks for a JWT in the ways a client can attach one to the
+// upgrade request. Browsers cannot set headers on a WebSocket, so they either
+// pass ?token= or offer the subprotocols "bearer, <token>".
+func handshakeToken(r *http.Request) (string, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, true
+	}
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer "), true
+	}
+	protocols := websocket.Subprotocols(r)
+	for i, p := range protocols {
+		if p == bearerProtocol && i+1 < len(protocols) {
+			return protocols[i+1], true
+		}
+	}
+	return "", false
+}
+
+// readAuthFrame waits for {"type":"auth","token":"..."} as the first frame
+// from a client that did not authenticate during the handshake.
+func readAuthFrame(ctx context.Context, conn *websocket.Conn, hub *Hub, keys auth.Keys) (*auth.Claims, error) {
+	conn.SetReadDeadline(time.Now().Add(authTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var frame struct {
+		Type  string `json:"type"`
+		Token string `json:"token"`
+	}
+	if err := conn.ReadJSON(&frame); err != nil || frame.Type != "auth" || frame.Token == "" {
+		return nil, errAuthRequired
+	}
+
+	claims, err := hub.authenticate(ctx, frame.Token, keys)
+	if err != nil {
+		if status, _ := authStatus(err); status == http.StatusServiceUnavailable {
+			return nil, errAccountCheckFailed
+		}
+		if errors.Is(err, errAccountInvalid) {
+			return nil, auth.ErrInvalidToken
+		}
+		return nil, err
+	}
+	return claims, nil
+}
+
+func closeWithReason(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
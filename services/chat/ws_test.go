@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestHub(store IStore) *Hub {
+	return NewHub(store, nil, nil, nil, nil, nil, nil, FilterChain{})
+}
+
+func connectTestClient(hub *Hub, userID uuid.UUID) *Client {
+	c := &Client{hub: hub, send: make(chan []byte, 8), userID: userID.String()}
+	hub.addClient(c)
+	return c
+}
+
+// received drains the frames queued for c.
+func received(c *Client) []*Frame {
+	var frames []*Frame
+	for {
+		select {
+		case payload := <-c.send:
+			var f Frame
+			json.Unmarshal(payload, &f)
+			frames = append(frames, &f)
+		default:
+			return frames
+		}
+	}
+}
+
+func TestSendTypingRespectsBlocks(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	mockStore := new(MockStore)
+	mockStore.On("IsBlocked", mock.Anything, alice, bob).Return(false, nil)
+	mockStore.On("IsBlocked", mock.Anything, alice, carol).Return(true, nil)
+
+	hub := newTestHub(mockStore)
+	aliceConn := connectTestClient(hub, alice)
+	bobConn := connectTestClient(hub, bob)
+	carolConn := connectTestClient(hub, carol)
+
+	hub.SendTyping(t.Context(), aliceConn, bob.String(), true)
+	frames := received(bobConn)
+	if assert.Len(t, frames, 1) {
+		assert.Equal(t, FrameTyping, frames[0].Type)
+		assert.Equal(t, alice.String(), frames[0].SenderID)
+		assert.True(t, *frames[0].Typing)
+	}
+
+	hub.SendTyping(t.Context(), aliceConn, carol.String(), true)
+	assert.Empty(t, received(carolConn))
+	assert.Empty(t, received(aliceConn))
+}
+
+func TestSendTypingFailsClosed(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	mockStore := new(MockStore)
+	mockStore.On("IsBlocked", mock.Anything, alice, bob).Return(false, errors.New("db down"))
+
+	hub := newTestHub(mockStore)
+	aliceConn := connectTestClient(hub, alice)
+	bobConn := connectTestClient(hub, bob)
+
+	hub.SendTyping(t.Context(), aliceConn, bob.String(), true)
+	assert.Empty(t, received(bobConn))
+}
+
+func TestMarkReadRespectsBlocks(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	readAt := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, blocked := range []bool{false, true} {
+		mockStore := new(MockStore)
+		mockStore.On("MarkRead", mock.Anything, alice, bob).Return(2, readAt, nil)
+		mockStore.On("IsBlocked", mock.Anything, alice, bob).Return(blocked, nil)
+
+		hub := newTestHub(mockStore)
+		aliceConn := connectTestClient(hub, alice)
+		aliceOther := connectTestClient(hub, alice)
+		bobConn := connectTestClient(hub, bob)
+
+		updated, at, err := hub.MarkRead(t.Context(), alice, aliceConn, bob)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, updated)
+		assert.Equal(t, readAt, at)
+
+		// The reader's other sessions always hear about it.
+		frames := received(aliceOther)
+		if assert.Len(t, frames, 1) {
+			assert.Equal(t, FrameRead, frames[0].Type)
+			assert.True(t, readAt.Equal(*frames[0].ReadAt))
+		}
+		assert.Empty(t, received(aliceConn))
+
+		frames = received(bobConn)
+		if blocked {
+			assert.Empty(t, frames)
+		} else if assert.Len(t, frames, 1) {
+			assert.True(t, readAt.Equal(*frames[0].ReadAt))
+		}
+	}
+}
+
+func TestDeleteMessageRespectsBlocks(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+
+	for _, blocked := range []bool{false, true} {
+		msg := &Message{ID: uuid.New(), SenderID: alice, ReceiverID: bob, Kind: KindUser, Content: "hi", CreatedAt: time.Now()}
+		mockStore := new(MockStore)
+		mockStore.On("GetMessage", mock.Anything, msg.ID).Return(msg, nil)
+		mockStore.On("DeleteMessage", mock.Anything, msg.ID, alice, mock.Anything, mock.Anything).Return(true, nil)
+		mockStore.On("IsBlocked", mock.Anything, alice, bob).Return(blocked, nil)
+
+		hub := newTestHub(mockStore)
+		aliceConn := connectTestClient(hub, alice)
+		aliceOther := connectTestClient(hub, alice)
+		bobConn := connectTestClient(hub, bob)
+
+		_, err := hub.DeleteMessage(t.Context(), alice, aliceConn, msg.ID)
+		assert.NoError(t, err)
+
+		frames := received(aliceOther)
+		if assert.Len(t, frames, 1) {
+			assert.Equal(t, FrameDelete, frames[0].Type)
+		}
+
+		frames = received(bobConn)
+		if blocked {
+			assert.Empty(t, frames)
+		} else if assert.Len(t, frames, 1) {
+			assert.Equal(t, FrameDelete, frames[0].Type)
+			assert.Equal(t, msg.ID, frames[0].Message.ID)
+		}
+	}
+}
+
+func TestPushUndelivered(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	messages := []*Message{
+		{ID: uuid.New(), SenderID: alice, ReceiverID: bob, Content: "one", Status: MessageDelivered},
+		{ID: uuid.New(), SenderID: alice, ReceiverID: bob, Content: "two", Status: MessageDelivered},
+	}
+
+	mockStore := new(MockStore)
+	mockStore.On("ClaimUndelivered", mock.Anything, bob, maxUndeliveredPush).Return(messages, nil)
+	mockStore.On("ListMessageAttachments", mock.Anything, mock.Anything).Return([]*Attachment{}, nil)
+	mockStore.On("ReleaseDelivered", mock.Anything, []uuid.UUID{messages[1].ID}).Return(nil)
+
+	hub := newTestHub(mockStore)
+	hub.blobs = newTestFileBlobStore(t)
+	bobConn := &Client{hub: hub, send: make(chan []byte, 1), userID: bob.String()}
+	hub.addClient(bobConn)
+
+	// Only one frame fits, so the second message goes back to the queue.
+	hub.pushUndelivered(t.Context(), bobConn)
+
+	frames := received(bobConn)
+	if assert.Len(t, frames, 1) {
+		assert.Equal(t, "one", frames[0].Message.Content)
+	}
+	mockStore.AssertExpectations(t)
+}
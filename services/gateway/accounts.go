@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"qasynda/shared/pkg/models"
+)
+
+const (
+	accountStatusTTL    = 30 * time.Second
+	maxCachedAccounts   = 10000
+	accountCheckTimeout = 3 * time.Second
+)
+
+var (
+	errAccountSuspended = errors.New("account suspended")
+	errAccountInvalid   = errors.New("account no longer valid")
+)
+
+type accountStatus struct {
+	err       error
+	checkedAt time.Time
+}
+
+// AccountGuard asks the user service whether the account behind a token may
+// still be used. Tokens are verified locally, so without it a suspended user
+// would keep access until their token expired. Answers are cached briefly to
+// keep it off the hot path.
+type AccountGuard struct {
+	users *UserClient
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]accountStatus
+}
+
+func NewAccountGuard(users *UserClient, ttl time.Duration) *AccountGuard {
+	return &AccountGuard{users: users, ttl: ttl, entries: make(map[string]accountStatus)}
+}
+
+// Check returns errAccountSuspended or errAccountInvalid for accounts that
+// must be turned away. If the user service cannot be asked, the last known
+// answer is used even when it has expired; with none, the error is returned
+// and the request must be refused.
+func (g *AccountGuard) Check(ctx context.Context, userID, token string) error {
+	g.mu.Lock()
+	cached, ok := g.entries[userID]
+	g.mu.Unlock()
+	if ok && time.Since(cached.checkedAt) < g.ttl {
+		return cached.err
+	}
+
+	var status error
+	_, err := g.users.ValidateToken(ctx, &models.ValidateTokenRequest{Token: token})
+	var httpErr *HTTPError
+	switch {
+	case err == nil:
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusForbidden:
+		status = errAccountSuspended
+	case errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusNotFound):
+		status = errAccountInvalid
+	default:
+		if ok {
+			return cached.err
+		}
+		return err
+	}
+
+	g.mu.Lock()
+	if len(g.entries) >= maxCachedAccounts {
+		for id, entry := range g.entries {
+			if time.Since(entry.checkedAt) >= g.ttl {
+				delete(g.entries, id)
+			}
+		}
+		if len(g.entries) >= maxCachedAccounts {
+			g.entries = make(map[string]accountStatus)
+		}
+	}
+	g.entries[userID] = accountStatus{err: status, checkedAt: time.Now()}
+	g.mu.Unlock()
+	return status
+}
+
+// Forget drops the cached answer so a change made through this gateway takes
+// effect on the next request.
+func (g *AccountGuard) Forget(userID string) {
+	g.mu.Lock()
+	delete(g.entries, userID)
+	g.mu.Unlock()
+}
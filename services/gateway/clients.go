@@ -7,7 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
 
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/cache"
 	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/models"
 )
@@ -16,15 +21,27 @@ type Clients struct {
 	User        *UserClient
 	Marketplace *MarketplaceClient
 	Chat        *ChatClient
+	Accounts    *AccountGuard
+	Revoked     *auth.RevocationList
 }
 
-func InitClients(cfg *config.Config) *Clients {
+func InitClients(cfg *config.Config) (*Clients, error) {
+	revocations, err := cache.New(cfg.RedisUrl)
+	if err != nil {
+		return nil, err
+	}
+
 	httpClient := &http.Client{}
+	// Account checks sit in front of every protected request, so they get
+	// their own client with a short timeout.
+	accountClient := &http.Client{Timeout: accountCheckTimeout}
 	return &Clients{
 		User:        NewUserClient(cfg.Services.UserUrl, httpClient),
 		Marketplace: NewMarketplaceClient(cfg.Services.MarketplaceUrl, httpClient),
 		Chat:        NewChatClient(cfg.Services.ChatUrl, httpClient),
-	}
+		Accounts:    NewAccountGuard(NewUserClient(cfg.Services.UserUrl, accountClient), accountStatusTTL),
+		Revoked:     auth.NewRevocationList(revocations),
+	}, nil
 }
 
 type UserClient struct {
@@ -40,8 +57,36 @@ func (c *UserClient) Register(ctx context.Context, req *models.RegisterRequest)
 	return doPost[models.RegisterRequest, models.AuthResponse](c.Client, c.BaseURL+"/register", req)
 }
 
-func (c *UserClient) Login(ctx context.Context, req *models.LoginRequest) (*models.AuthResponse, error) {
-	return doPost[models.LoginRequest, models.AuthResponse](c.Client, c.BaseURL+"/login", req)
+func (c *UserClient) Login(ctx context.Context, clientIP string, req *models.LoginRequest) (*models.AuthResponse, error) {
+	return doPostFrom[models.LoginRequest, models.AuthResponse](c.Client, c.BaseURL+"/login", clientIP, req)
+}
+
+func (c *UserClient) Refresh(ctx context.Context, req *models.RefreshRequest) (*models.AuthResponse, error) {
+	return doPost[models.RefreshRequest, models.AuthResponse](c.Client, c.BaseURL+"/refresh", req)
+}
+
+func (c *UserClient) Logout(ctx context.Context, req *models.LogoutRequest) (*map[string]interface{}, error) {
+	return doPost[models.LogoutRequest, map[string]interface{}](c.Client, c.BaseURL+"/logout", req)
+}
+
+func (c *UserClient) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) (*map[string]interface{}, error) {
+	return doPost[models.VerifyEmailRequest, map[string]interface{}](c.Client, c.BaseURL+"/verify-email", req)
+}
+
+func (c *UserClient) ResendVerification(ctx context.Context, req *models.ResendVerificationRequest) (*map[string]interface{}, error) {
+	return doPost[models.ResendVerificationRequest, map[string]interface{}](c.Client, c.BaseURL+"/verify-email/resend", req)
+}
+
+func (c *UserClient) ForgotPassword(ctx context.Context, clientIP string, req *models.ForgotPasswordRequest) (*map[string]interface{}, error) {
+	return doPostFrom[models.ForgotPasswordRequest, map[string]interface{}](c.Client, c.BaseURL+"/forgot-password", clientIP, req)
+}
+
+func (c *UserClient) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) (*models.ResetPasswordResponse, error) {
+	return doPost[models.ResetPasswordRequest, models.ResetPasswordResponse](c.Client, c.BaseURL+"/reset-password", req)
+}
+
+func (c *UserClient) GetJWKS(ctx context.Context) (*auth.JWKSet, error) {
+	return doGet[auth.JWKSet](c.Client, c.BaseURL+"/.well-known/jwks.json")
 }
 
 func (c *UserClient) ValidateToken(ctx context.Context, req *models.ValidateTokenRequest) (*models.UserResponse, error) {
@@ -53,11 +98,74 @@ func (c *UserClient) GetUser(ctx context.Context, req *models.GetUserRequest) (*
 	return doGet[models.UserResponse](c.Client, url)
 }
 
+// GetUsers looks up several users in one request; unknown ids are absent
+// from the result.
+func (c *UserClient) GetUsers(ctx context.Context, ids []string) (*models.UsersResponse, error) {
+	url := fmt.Sprintf("%s/users?ids=%s", c.BaseURL, neturl.QueryEscape(strings.Join(ids, ",")))
+	return doGet[models.UsersResponse](c.Client, url)
+}
+
 func (c *UserClient) ListProviders(ctx context.Context, req *models.ListProvidersRequest) (*models.ListProvidersResponse, error) {
 	url := fmt.Sprintf("%s/providers?limit=%d&offset=%d", c.BaseURL, req.Limit, req.Offset)
 	return doGet[models.ListProvidersResponse](c.Client, url)
 }
 
+func (c *UserClient) SearchProviders(ctx context.Context, req *models.SearchProvidersRequest) (*models.SearchProvidersResponse, error) {
+	url := fmt.Sprintf("%s/providers/search?%s", c.BaseURL, searchProvidersQuery(req).Encode())
+	return doGet[models.SearchProvidersResponse](c.Client, url)
+}
+
+func searchProvidersQuery(req *models.SearchProvidersRequest) neturl.Values {
+	query := neturl.Values{}
+	setIf := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	setIf("q", req.Query)
+	setIf("location", req.Location)
+	setIf("service_id", req.ServiceID)
+	setIf("sort", req.Sort)
+	if req.MinRating != nil {
+		query.Set("min_rating", strconv.FormatFloat(*req.MinRating, 'f', -1, 64))
+	}
+	if req.MinRate != nil {
+		query.Set("min_rate", strconv.FormatFloat(*req.MinRate, 'f', -1, 64))
+	}
+	if req.MaxRate != nil {
+		query.Set("max_rate", strconv.FormatFloat(*req.MaxRate, 'f', -1, 64))
+	}
+	if req.Available != nil {
+		query.Set("available", strconv.FormatBool(*req.Available))
+	}
+	if req.MinExperience != nil {
+		query.Set("min_experience", strconv.Itoa(*req.MinExperience))
+	}
+	query.Set("limit", strconv.Itoa(req.Limit))
+	query.Set("offset", strconv.Itoa(req.Offset))
+	return query
+}
+
+func (c *UserClient) GetProvider(ctx context.Context, providerID string) (*models.ProviderResponse, error) {
+	url := fmt.Sprintf("%s/providers/%s", c.BaseURL, providerID)
+	return doGet[models.ProviderResponse](c.Client, url)
+}
+
+func (c *UserClient) UpdateProviderProfile(ctx context.Context, userID string, req *models.UpdateProviderProfileRequest) (*models.ProviderResponse, error) {
+	url := fmt.Sprintf("%s/providers/%s/profile", c.BaseURL, userID)
+	return doPut[models.UpdateProviderProfileRequest, models.ProviderResponse](c.Client, url, req)
+}
+
+func (c *UserClient) NearbyProviders(ctx context.Context, req *models.NearbyProvidersRequest) (*models.ListProvidersResponse, error) {
+	query := neturl.Values{}
+	query.Set("lat", strconv.FormatFloat(req.Latitude, 'f', -1, 64))
+	query.Set("lng", strconv.FormatFloat(req.Longitude, 'f', -1, 64))
+	query.Set("radius_km", strconv.FormatFloat(req.RadiusKm, 'f', -1, 64))
+	query.Set("limit", strconv.Itoa(req.Limit))
+	url := fmt.Sprintf("%s/providers/nearby?%s", c.BaseURL, query.Encode())
+	return doGet[models.ListProvidersResponse](c.Client, url)
+}
+
 func (c *UserClient) UpdateProviderStatus(ctx context.Context, userID string, isAvailable bool) (*map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/providers/%s/status", c.BaseURL, userID)
 	reqBody := map[string]bool{"is_available": isAvailable}
@@ -69,6 +177,57 @@ func (c *UserClient) GetProviderStatus(ctx context.Context, userID string) (*map
 	return doGet[map[string]interface{}](c.Client, url)
 }
 
+func (c *UserClient) BlockUser(ctx context.Context, userID, blockedID string) (*map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/users/%s/block", c.BaseURL, neturl.PathEscape(blockedID))
+	return doPost[models.BlockUserRequest, map[string]interface{}](c.Client, url, &models.BlockUserRequest{UserID: userID})
+}
+
+func (c *UserClient) UnblockUser(ctx context.Context, userID, blockedID string) (*map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/users/%s/block?user_id=%s", c.BaseURL, neturl.PathEscape(blockedID), userID)
+	return doDelete[map[string]interface{}](c.Client, url)
+}
+
+func (c *UserClient) ListBlocks(ctx context.Context, userID string) (*models.ListBlocksResponse, error) {
+	url := fmt.Sprintf("%s/blocks?user_id=%s", c.BaseURL, userID)
+	return doGet[models.ListBlocksResponse](c.Client, url)
+}
+
+func (c *UserClient) CreateReport(ctx context.Context, req *models.CreateReportRequest) (*models.ReportResponse, error) {
+	return doPost[models.CreateReportRequest, models.ReportResponse](c.Client, c.BaseURL+"/reports", req)
+}
+
+func (c *UserClient) ListReports(ctx context.Context, req *models.ListReportsRequest) (*models.ListReportsResponse, error) {
+	query := neturl.Values{}
+	query.Set("status", req.Status)
+	query.Set("limit", strconv.Itoa(req.Limit))
+	query.Set("offset", strconv.Itoa(req.Offset))
+	url := fmt.Sprintf("%s/reports?%s", c.BaseURL, query.Encode())
+	return doGet[models.ListReportsResponse](c.Client, url)
+}
+
+func (c *UserClient) ResolveReport(ctx context.Context, reportID string, req *models.ResolveReportRequest) (*models.ReportResponse, error) {
+	url := fmt.Sprintf("%s/reports/%s", c.BaseURL, neturl.PathEscape(reportID))
+	return doPut[models.ResolveReportRequest, models.ReportResponse](c.Client, url, req)
+}
+
+func (c *UserClient) ListUsers(ctx context.Context, req *models.ListUsersRequest) (*models.ListUsersResponse, error) {
+	query := neturl.Values{}
+	query.Set("q", req.Query)
+	query.Set("role", req.Role)
+	if req.Suspended != nil {
+		query.Set("suspended", strconv.FormatBool(*req.Suspended))
+	}
+	query.Set("limit", strconv.Itoa(req.Limit))
+	query.Set("offset", strconv.Itoa(req.Offset))
+	url := fmt.Sprintf("%s/admin/users?%s", c.BaseURL, query.Encode())
+	return doGet[models.ListUsersResponse](c.Client, url)
+}
+
+func (c *UserClient) SetSuspension(ctx context.Context, userID string, req *models.SetSuspensionRequest) (*models.AdminUserResponse, error) {
+	url := fmt.Sprintf("%s/admin/users/%s/suspension", c.BaseURL, neturl.PathEscape(userID))
+	return doPut[models.SetSuspensionRequest, models.AdminUserResponse](c.Client, url, req)
+}
+
 type MarketplaceClient struct {
 	BaseURL string
 	Client  *http.Client
@@ -85,11 +244,26 @@ func (c *MarketplaceClient) CreateService(ctx context.Context, req *models.Creat
 func (c *MarketplaceClient) GetServices(ctx context.Context, req *models.GetServicesRequest) (*models.GetServicesResponse, error) {
 	url := c.BaseURL + "/services"
 	if req.Category != "" {
-		url += "?category=" + req.Category
+		url += "?category=" + neturl.QueryEscape(req.Category)
 	}
 	return doGet[models.GetServicesResponse](c.Client, url)
 }
 
+func (c *MarketplaceClient) OfferService(ctx context.Context, req *models.OfferServiceRequest) (*models.ServiceOfferingResponse, error) {
+	url := fmt.Sprintf("%s/services/%s/providers", c.BaseURL, req.ServiceID)
+	return doPost[models.OfferServiceRequest, models.ServiceOfferingResponse](c.Client, url, req)
+}
+
+func (c *MarketplaceClient) WithdrawService(ctx context.Context, userID, serviceID string) (*map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/services/%s/providers?user_id=%s", c.BaseURL, serviceID, userID)
+	return doDelete[map[string]interface{}](c.Client, url)
+}
+
+func (c *MarketplaceClient) ListServiceProviders(ctx context.Context, serviceID string) (*models.ListServiceProvidersResponse, error) {
+	url := fmt.Sprintf("%s/services/%s/providers", c.BaseURL, serviceID)
+	return doGet[models.ListServiceProvidersResponse](c.Client, url)
+}
+
 func (c *MarketplaceClient) CreateBooking(ctx context.Context, req *models.CreateBookingRequest) (*models.BookingResponse, error) {
 	return doPost[models.CreateBookingRequest, models.BookingResponse](c.Client, c.BaseURL+"/bookings", req)
 }
@@ -104,6 +278,71 @@ func (c *MarketplaceClient) UpdateBookingStatus(ctx context.Context, req *models
 	return doPut[models.UpdateBookingStatusRequest, models.BookingResponse](c.Client, url, req)
 }
 
+func (c *MarketplaceClient) CreateReview(ctx context.Context, req *models.CreateReviewRequest) (*models.ReviewResponse, error) {
+	url := fmt.Sprintf("%s/bookings/%s/review", c.BaseURL, req.BookingID)
+	return doPost[models.CreateReviewRequest, models.ReviewResponse](c.Client, url, req)
+}
+
+func (c *MarketplaceClient) ListReviews(ctx context.Context, req *models.ListReviewsRequest) (*models.ListReviewsResponse, error) {
+	url := fmt.Sprintf("%s/providers/%s/reviews?limit=%d&offset=%d", c.BaseURL, req.ProviderID, req.Limit, req.Offset)
+	return doGet[models.ListReviewsResponse](c.Client, url)
+}
+
+func (c *MarketplaceClient) SetWorkingHours(ctx context.Context, req *models.SetWorkingHoursRequest) (*models.WorkingHoursResponse, error) {
+	return doPut[models.SetWorkingHoursRequest, models.WorkingHoursResponse](c.Client, c.BaseURL+"/availability/working-hours", req)
+}
+
+func (c *MarketplaceClient) GetWorkingHours(ctx context.Context, providerID string) (*models.WorkingHoursResponse, error) {
+	url := fmt.Sprintf("%s/providers/%s/working-hours", c.BaseURL, providerID)
+	return doGet[models.WorkingHoursResponse](c.Client, url)
+}
+
+func (c *MarketplaceClient) CreateBlackout(ctx context.Context, req *models.CreateBlackoutRequest) (*models.BlackoutResponse, error) {
+	return doPost[models.CreateBlackoutRequest, models.BlackoutResponse](c.Client, c.BaseURL+"/availability/blackouts", req)
+}
+
+func (c *MarketplaceClient) ListBlackouts(ctx context.Context, userID string) (*models.ListBlackoutsResponse, error) {
+	url := fmt.Sprintf("%s/availability/blackouts?user_id=%s", c.BaseURL, userID)
+	return doGet[models.ListBlackoutsResponse](c.Client, url)
+}
+
+func (c *MarketplaceClient) DeleteBlackout(ctx context.Context, userID, blackoutID string) (*map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/availability/blackouts/%s?user_id=%s", c.BaseURL, blackoutID, userID)
+	return doDelete[map[string]interface{}](c.Client, url)
+}
+
+func (c *MarketplaceClient) GetAvailability(ctx context.Context, req *models.GetAvailabilityRequest) (*models.AvailabilityResponse, error) {
+	query := neturl.Values{}
+	query.Set("from", req.From)
+	query.Set("to", req.To)
+	url := fmt.Sprintf("%s/providers/%s/availability?%s", c.BaseURL, req.ProviderID, query.Encode())
+	return doGet[models.AvailabilityResponse](c.Client, url)
+}
+
+func (c *MarketplaceClient) UpdateService(ctx context.Context, serviceID string, req *models.UpdateServiceRequest) (*models.ServiceResponse, error) {
+	url := fmt.Sprintf("%s/admin/services/%s", c.BaseURL, neturl.PathEscape(serviceID))
+	return doPut[models.UpdateServiceRequest, models.ServiceResponse](c.Client, url, req)
+}
+
+func (c *MarketplaceClient) DeleteService(ctx context.Context, serviceID string) (*map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/admin/services/%s", c.BaseURL, neturl.PathEscape(serviceID))
+	return doDelete[map[string]interface{}](c.Client, url)
+}
+
+func (c *MarketplaceClient) ListAllBookings(ctx context.Context, req *models.AdminListBookingsRequest) (*models.AdminListBookingsResponse, error) {
+	query := neturl.Values{}
+	query.Set("status", req.Status)
+	query.Set("client_id", req.ClientID)
+	query.Set("provider_id", req.ProviderID)
+	query.Set("service_id", req.ServiceID)
+	query.Set("from", req.From)
+	query.Set("to", req.To)
+	query.Set("limit", strconv.Itoa(req.Limit))
+	query.Set("offset", strconv.Itoa(req.Offset))
+	url := fmt.Sprintf("%s/admin/bookings?%s", c.BaseURL, query.Encode())
+	return doGet[models.AdminListBookingsResponse](c.Client, url)
+}
+
 type ChatClient struct {
 	BaseURL string
 	Client  *http.Client
@@ -114,18 +353,128 @@ func NewChatClient(baseURL string, client *http.Client) *ChatClient {
 }
 
 func (c *ChatClient) GetHistory(ctx context.Context, req *models.GetHistoryRequest) (*models.GetHistoryResponse, error) {
-	url := fmt.Sprintf("%s/history?user_id_1=%s&user_id_2=%s&limit=%d&offset=%d",
-		c.BaseURL, req.UserID1, req.UserID2, req.Limit, req.Offset)
+	url := fmt.Sprintf("%s/history?user_id_1=%s&user_id_2=%s&booking_id=%s&limit=%d&offset=%d",
+		c.BaseURL, req.UserID1, neturl.QueryEscape(req.UserID2), neturl.QueryEscape(req.BookingID), req.Limit, req.Offset)
 	return doGet[models.GetHistoryResponse](c.Client, url)
 }
 
+func (c *ChatClient) ListConversations(ctx context.Context, req *models.ListConversationsRequest) (*models.ListConversationsResponse, error) {
+	url := fmt.Sprintf("%s/conversations?user_id=%s&limit=%d&cursor=%s",
+		c.BaseURL, req.UserID, req.Limit, neturl.QueryEscape(req.Cursor))
+	return doGet[models.ListConversationsResponse](c.Client, url)
+}
+
+// UploadAttachment streams a multipart upload through to the chat service
+// unchanged.
+func (c *ChatClient) UploadAttachment(ctx context.Context, userID, contentType string, body io.Reader) (*models.Attachment, error) {
+	url := fmt.Sprintf("%s/attachments?user_id=%s", c.BaseURL, userID)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
+	}
+
+	var result models.Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// DownloadAttachment returns the raw response so the caller can stream the
+// file. The caller must close the body.
+func (c *ChatClient) DownloadAttachment(ctx context.Context, rawQuery string) (*http.Response, error) {
+	url := fmt.Sprintf("%s/attachments/download?%s", c.BaseURL, rawQuery)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
+	}
+	return resp, nil
+}
+
+func (c *ChatClient) GetUnread(ctx context.Context, userID string) (*models.UnreadCountsResponse, error) {
+	url := fmt.Sprintf("%s/unread?user_id=%s", c.BaseURL, userID)
+	return doGet[models.UnreadCountsResponse](c.Client, url)
+}
+
+func (c *ChatClient) MarkRead(ctx context.Context, req *models.MarkReadRequest) (*models.MarkReadResponse, error) {
+	return doPost[models.MarkReadRequest, models.MarkReadResponse](c.Client, c.BaseURL+"/read", req)
+}
+
+func (c *ChatClient) EditMessage(ctx context.Context, messageID string, req *models.EditMessageRequest) (*models.Message, error) {
+	url := fmt.Sprintf("%s/messages/%s", c.BaseURL, neturl.PathEscape(messageID))
+	return doPut[models.EditMessageRequest, models.Message](c.Client, url, req)
+}
+
+func (c *ChatClient) DeleteMessage(ctx context.Context, userID, messageID string) (*models.Message, error) {
+	url := fmt.Sprintf("%s/messages/%s?user_id=%s", c.BaseURL, neturl.PathEscape(messageID), userID)
+	return doDelete[models.Message](c.Client, url)
+}
+
+func (c *ChatClient) ListDeadLetters(ctx context.Context, limit int) (*models.ListDeadLettersResponse, error) {
+	url := fmt.Sprintf("%s/admin/dead-letters?limit=%d", c.BaseURL, limit)
+	return doGet[models.ListDeadLettersResponse](c.Client, url)
+}
+
+func (c *ChatClient) ReplayDeadLetters(ctx context.Context, req *models.ReplayDeadLettersRequest) (*models.ReplayDeadLettersResponse, error) {
+	return doPost[models.ReplayDeadLettersRequest, models.ReplayDeadLettersResponse](c.Client, c.BaseURL+"/admin/dead-letters/replay", req)
+}
+
+// HTTPError carries a downstream service's error response so handlers can
+// relay its status code and body instead of collapsing everything into a 500.
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, string(e.Body))
+}
+
 func doPost[Req, Resp any](client *http.Client, url string, req *Req) (*Resp, error) {
+	return doPostFrom[Req, Resp](client, url, "", req)
+}
+
+// doPostFrom is doPost for endpoints that throttle by the caller's address,
+// which is passed along in models.ClientIPHeader.
+func doPostFrom[Req, Resp any](client *http.Client, url, clientIP string, req *Req) (*Resp, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(body))
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if clientIP != "" {
+		httpReq.Header.Set(models.ClientIPHeader, clientIP)
+	}
+
+	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +482,7 @@ func doPost[Req, Resp any](client *http.Client, url string, req *Req) (*Resp, er
 
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
 	}
 
 	var result Resp
@@ -152,7 +501,7 @@ func doGet[Resp any](client *http.Client, url string) (*Resp, error) {
 
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
 	}
 
 	var result Resp
@@ -182,7 +531,31 @@ func doPut[Req, Resp any](client *http.Client, url string, req *Req) (*Resp, err
 
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
+	}
+
+	var result Resp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func doDelete[Resp any](client *http.Client, url string) (*Resp, error) {
+	httpReq, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: bodyBytes}
 	}
 
 	var result Resp
@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
+	"qasynda/shared/pkg/logger"
 	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -18,16 +21,28 @@ func NewHandler(clients *Clients) *Handler {
 	return &Handler{clients: clients}
 }
 
+func respondError(c *gin.Context, err error, fallback int) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if retryAfter := httpErr.Header.Get("Retry-After"); retryAfter != "" {
+			c.Header("Retry-After", retryAfter)
+		}
+		c.Data(httpErr.StatusCode, "application/json; charset=utf-8", httpErr.Body)
+		return
+	}
+	c.JSON(fallback, gin.H{"error": err.Error()})
+}
+
 func (h *Handler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
 		return
 	}
 
 	res, err := h.clients.User.Register(context.Background(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -36,20 +51,141 @@ func (h *Handler) Register(c *gin.Context) {
 
 func (h *Handler) Login(c *gin.Context) {
 	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
+		return
+	}
+
+	res, err := h.clients.User.Login(context.Background(), c.ClientIP(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusUnauthorized)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	res, err := h.clients.User.Login(context.Background(), &req)
+	res, err := h.clients.User.Refresh(context.Background(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusUnauthorized)
 		return
 	}
 
 	c.JSON(http.StatusOK, res)
 }
 
+func (h *Handler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.User.Logout(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// The refresh tokens are gone; also stop the access token in hand (or
+	// all of them) instead of letting it run out.
+	ctx := c.Request.Context()
+	if req.All {
+		err = h.clients.Revoked.RevokeUser(ctx, req.UserID)
+	} else {
+		err = h.clients.Revoked.RevokeToken(ctx, c.GetString("token_id"), c.GetTime("token_expires_at"))
+	}
+	if err != nil {
+		logger.Error("failed to revoke access token", err)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.User.VerifyEmail(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ResendVerification(c *gin.Context) {
+	res, err := h.clients.User.ResendVerification(context.Background(), &models.ResendVerificationRequest{
+		UserID: c.GetString("user_id"),
+	})
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, res)
+}
+
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
+		return
+	}
+
+	res, err := h.clients.User.ForgotPassword(context.Background(), c.ClientIP(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, res)
+}
+
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
+		return
+	}
+
+	res, err := h.clients.User.ResetPassword(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Whoever knew the old password may still hold an access token.
+	if err := h.clients.Revoked.RevokeUser(c.Request.Context(), res.UserID); err != nil {
+		logger.Error("failed to revoke access tokens", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password updated"})
+}
+
+func (h *Handler) GetJWKS(c *gin.Context) {
+	res, err := h.clients.User.GetJWKS(context.Background())
+	if err != nil {
+		respondError(c, err, http.StatusBadGateway)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, res)
+}
+
 func (h *Handler) GetProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
 	res, err := h.clients.User.GetUser(context.Background(), &models.GetUserRequest{UserID: userID})
@@ -78,6 +214,68 @@ func (h *Handler) GetProviders(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+func (h *Handler) SearchProviders(c *gin.Context) {
+	var req models.SearchProvidersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.User.SearchProviders(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetProvider(c *gin.Context) {
+	res, err := h.clients.User.GetProvider(context.Background(), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdateProviderProfile(c *gin.Context) {
+	var req models.UpdateProviderProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.User.UpdateProviderProfile(context.Background(), c.GetString("user_id"), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) NearbyProviders(c *gin.Context) {
+	if c.Query("lat") == "" || c.Query("lng") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required"})
+		return
+	}
+	var req models.NearbyProvidersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.User.NearbyProviders(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
 func (h *Handler) CreateService(c *gin.Context) {
 	var req models.CreateServiceRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -88,7 +286,7 @@ func (h *Handler) CreateService(c *gin.Context) {
 
 	res, err := h.clients.Marketplace.CreateService(context.Background(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -106,6 +304,44 @@ func (h *Handler) GetServices(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+func (h *Handler) OfferService(c *gin.Context) {
+	var req models.OfferServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ServiceID = c.Param("id")
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Marketplace.OfferService(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) WithdrawService(c *gin.Context) {
+	res, err := h.clients.Marketplace.WithdrawService(context.Background(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetServiceProviders(c *gin.Context) {
+	res, err := h.clients.Marketplace.ListServiceProviders(context.Background(), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
 func (h *Handler) CreateBooking(c *gin.Context) {
 	var req models.CreateBookingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -116,7 +352,7 @@ func (h *Handler) CreateBooking(c *gin.Context) {
 
 	res, err := h.clients.Marketplace.CreateBooking(context.Background(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -151,7 +387,122 @@ func (h *Handler) UpdateBookingStatus(c *gin.Context) {
 
 	res, err := h.clients.Marketplace.UpdateBookingStatus(context.Background(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateReview(c *gin.Context) {
+	var req models.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.BookingID = c.Param("id")
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Marketplace.CreateReview(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetProviderReviews(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "20")
+	offsetStr := c.DefaultQuery("offset", "0")
+	limit, _ := strconv.Atoi(limitStr)
+	offset, _ := strconv.Atoi(offsetStr)
+
+	res, err := h.clients.Marketplace.ListReviews(context.Background(), &models.ListReviewsRequest{
+		ProviderID: c.Param("id"),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) SetWorkingHours(c *gin.Context) {
+	var req models.SetWorkingHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Marketplace.SetWorkingHours(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetWorkingHours(c *gin.Context) {
+	res, err := h.clients.Marketplace.GetWorkingHours(context.Background(), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateBlackout(c *gin.Context) {
+	var req models.CreateBlackoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Marketplace.CreateBlackout(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetBlackouts(c *gin.Context) {
+	res, err := h.clients.Marketplace.ListBlackouts(context.Background(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteBlackout(c *gin.Context) {
+	res, err := h.clients.Marketplace.DeleteBlackout(context.Background(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) GetProviderAvailability(c *gin.Context) {
+	res, err := h.clients.Marketplace.GetAvailability(context.Background(), &models.GetAvailabilityRequest{
+		ProviderID: c.Param("id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+	})
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -166,15 +517,149 @@ func (h *Handler) GetChatHistory(c *gin.Context) {
 	offset, _ := strconv.Atoi(offsetStr)
 
 	req := &models.GetHistoryRequest{
-		UserID1: c.GetString("user_id"),
-		UserID2: otherUserID,
-		Limit:   limit,
-		Offset:  offset,
+		UserID1:   c.GetString("user_id"),
+		UserID2:   otherUserID,
+		BookingID: c.Query("booking_id"),
+		Limit:     limit,
+		Offset:    offset,
 	}
 
 	res, err := h.clients.Chat.GetHistory(context.Background(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListConversations(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	req := &models.ListConversationsRequest{
+		UserID: c.GetString("user_id"),
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	}
+
+	res, err := h.clients.Chat.ListConversations(context.Background(), req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.attachPeers(c.Request.Context(), res.Conversations)
+	c.JSON(http.StatusOK, res)
+}
+
+// attachPeers fills in each conversation partner's public profile. A lookup
+// failure leaves Peer empty rather than failing the whole inbox.
+func (h *Handler) attachPeers(ctx context.Context, conversations []*models.ConversationResponse) {
+	if len(conversations) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(conversations))
+	for _, conv := range conversations {
+		ids = append(ids, conv.PeerID)
+	}
+	res, err := h.clients.User.GetUsers(ctx, ids)
+	if err != nil {
+		logger.Error("failed to load conversation peers", err)
+		return
+	}
+	peers := make(map[string]*models.UserResponse, len(res.Users))
+	for _, user := range res.Users {
+		peers[user.ID] = &models.UserResponse{ID: user.ID, FullName: user.FullName, Role: user.Role}
+	}
+	for _, conv := range conversations {
+		conv.Peer = peers[conv.PeerID]
+	}
+}
+
+// maxUploadBytes caps what the gateway forwards; the chat service applies the
+// exact per-file limit.
+const maxUploadBytes = 11 << 20
+
+func (h *Handler) UploadChatAttachment(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	res, err := h.clients.Chat.UploadAttachment(c.Request.Context(), c.GetString("user_id"), c.GetHeader("Content-Type"), body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+			return
+		}
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *Handler) DownloadChatAttachment(c *gin.Context) {
+	resp, err := h.clients.Chat.DownloadAttachment(c.Request.Context(), c.Request.URL.RawQuery)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	headers := map[string]string{}
+	for _, name := range []string{"Cache-Control", "X-Content-Type-Options"} {
+		if v := resp.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+	c.DataFromReader(resp.StatusCode, resp.ContentLength, resp.Header.Get("Content-Type"), resp.Body, headers)
+}
+
+func (h *Handler) GetChatUnread(c *gin.Context) {
+	res, err := h.clients.Chat.GetUnread(context.Background(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) MarkChatRead(c *gin.Context) {
+	var req models.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Chat.MarkRead(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) EditChatMessage(c *gin.Context) {
+	var req models.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+
+	res, err := h.clients.Chat.EditMessage(context.Background(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteChatMessage(c *gin.Context) {
+	res, err := h.clients.Chat.DeleteMessage(context.Background(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -211,3 +696,205 @@ func (h *Handler) GetProviderStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+func (h *Handler) BlockUser(c *gin.Context) {
+	res, err := h.clients.User.BlockUser(context.Background(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UnblockUser(c *gin.Context) {
+	res, err := h.clients.User.UnblockUser(context.Background(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListBlocks(c *gin.Context) {
+	res, err := h.clients.User.ListBlocks(context.Background(), c.GetString("user_id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) CreateReport(c *gin.Context) {
+	var req models.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ReporterID = c.GetString("user_id")
+
+	res, err := h.clients.User.CreateReport(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, res)
+}
+
+func (h *Handler) ListReports(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	res, err := h.clients.User.ListReports(context.Background(), &models.ListReportsRequest{
+		Status: c.DefaultQuery("status", "open"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ResolveReport(c *gin.Context) {
+	var req models.ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ResolvedBy = c.GetString("user_id")
+
+	res, err := h.clients.User.ResolveReport(context.Background(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListChatDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	res, err := h.clients.Chat.ListDeadLetters(context.Background(), limit)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ReplayChatDeadLetters(c *gin.Context) {
+	var req models.ReplayDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.Chat.ReplayDeadLetters(context.Background(), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	req := &models.ListUsersRequest{
+		Query:  c.Query("q"),
+		Role:   c.Query("role"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if v := c.Query("suspended"); v != "" {
+		suspended, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "suspended must be true or false"})
+			return
+		}
+		req.Suspended = &suspended
+	}
+
+	res, err := h.clients.User.ListUsers(context.Background(), req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) SetUserSuspension(c *gin.Context) {
+	var req models.SetSuspensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.AdminID = c.GetString("user_id")
+
+	res, err := h.clients.User.SetSuspension(context.Background(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.clients.Accounts.Forget(c.Param("id"))
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) UpdateCatalogService(c *gin.Context) {
+	var req models.UpdateServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := h.clients.Marketplace.UpdateService(context.Background(), c.Param("id"), &req)
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeleteCatalogService(c *gin.Context) {
+	res, err := h.clients.Marketplace.DeleteService(context.Background(), c.Param("id"))
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) ListAllBookings(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	res, err := h.clients.Marketplace.ListAllBookings(context.Background(), &models.AdminListBookingsRequest{
+		Status:     c.Query("status"),
+		ClientID:   c.Query("client_id"),
+		ProviderID: c.Query("provider_id"),
+		ServiceID:  c.Query("service_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -14,23 +15,12 @@ import (
 	"qasynda/shared/pkg/auth"
 	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
-func RateLimitMiddleware(rps float64, burst int) gin.HandlerFunc {
-	limiter := rate.NewLimiter(rate.Limit(rps), burst)
-	return func(c *gin.Context) {
-		if !limiter.Allow() {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
-			return
-		}
-		c.Next()
-	}
-}
-
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+func AuthMiddleware(keys auth.Keys, accounts *AccountGuard, revoked *auth.RevocationList) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -39,14 +29,60 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		claims, err := auth.ValidateToken(tokenString, cfg.JWTSecret)
+		claims, err := auth.ParseToken(tokenString, keys)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		isRevoked, err := revoked.IsRevoked(c.Request.Context(), claims)
 		if err != nil {
+			logger.Error("failed to check token revocation", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not verify token"})
+			return
+		}
+		if isRevoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+			return
+		}
+
+		// A suspension must not be missed just because the user service is
+		// down, so an account that cannot be checked is turned away.
+		switch err := accounts.Check(c.Request.Context(), claims.UserID, tokenString); {
+		case errors.Is(err, errAccountSuspended):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, errAccountInvalid):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			return
+		case err != nil:
+			logger.Error("failed to check account status", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "could not verify account"})
+			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("token_id", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("token_expires_at", claims.ExpiresAt.Time)
+		}
+		c.Next()
+	}
+}
+
+// RequireRole lets the request through only if AuthMiddleware found one of
+// the given roles in the token.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+	return func(c *gin.Context) {
+		if !allowed[c.GetString("role")] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
 		c.Next()
 	}
 }
@@ -55,10 +91,24 @@ func main() {
 	logger.Init()
 	cfg := config.Load()
 
-	clients := InitClients(cfg)
+	if err := models.RegisterValidators(); err != nil {
+		logger.Error("failed to register validators", err)
+		os.Exit(1)
+	}
+
+	clients, err := InitClients(cfg)
+	if err != nil {
+		logger.Error("failed to connect to redis", err)
+		os.Exit(1)
+	}
+	keys := auth.NewJWKS(cfg.JWKSUrl())
 	handler := NewHandler(clients)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		logger.Error("invalid TRUSTED_PROXIES", err)
+		os.Exit(1)
+	}
 
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -76,32 +126,81 @@ func main() {
 
 	r.Use(RateLimitMiddleware(10, 20))
 
+	r.GET("/.well-known/jwks.json", handler.GetJWKS)
+
 	api := r.Group("/api")
 	{
 		api.GET("/services", handler.GetServices)
+		api.GET("/services/:id/providers", handler.GetServiceProviders)
 		api.GET("/providers", handler.GetProviders)
+		api.GET("/providers/search", handler.SearchProviders)
+		api.GET("/providers/nearby", handler.NearbyProviders)
+		api.GET("/providers/:id", handler.GetProvider)
+		api.GET("/providers/:id/reviews", handler.GetProviderReviews)
+		api.GET("/providers/:id/availability", handler.GetProviderAvailability)
+		api.GET("/providers/:id/working-hours", handler.GetWorkingHours)
+		api.GET("/chat/attachments/download", handler.DownloadChatAttachment)
 
 		auth := api.Group("/auth")
 		{
 			auth.POST("/register", handler.Register)
 			auth.POST("/login", handler.Login)
+			auth.POST("/refresh", handler.Refresh)
+			auth.POST("/verify-email", handler.VerifyEmail)
+			auth.POST("/forgot-password", handler.ForgotPassword)
+			auth.POST("/reset-password", handler.ResetPassword)
 		}
 	}
 
 	protected := api.Group("/")
-	protected.Use(AuthMiddleware(cfg))
+	protected.Use(AuthMiddleware(keys, clients.Accounts, clients.Revoked))
 	{
 		protected.GET("/auth/me", handler.GetProfile)
+		protected.POST("/auth/logout", handler.Logout)
+		protected.POST("/auth/verify-email/resend", handler.ResendVerification)
 
 		protected.POST("/services", handler.CreateService)
+		protected.POST("/services/:id/providers", handler.OfferService)
+		protected.DELETE("/services/:id/providers/me", handler.WithdrawService)
 		protected.POST("/bookings", handler.CreateBooking)
 		protected.GET("/bookings", handler.GetBookings)
 		protected.PUT("/bookings/:id/status", handler.UpdateBookingStatus)
+		protected.POST("/bookings/:id/review", handler.CreateReview)
 
 		protected.PUT("/providers/status", handler.UpdateProviderStatus)
 		protected.GET("/providers/status", handler.GetProviderStatus)
+		protected.PUT("/providers/me", handler.UpdateProviderProfile)
+		protected.PUT("/providers/me/working-hours", handler.SetWorkingHours)
+		protected.GET("/providers/me/blackouts", handler.GetBlackouts)
+		protected.POST("/providers/me/blackouts", handler.CreateBlackout)
+		protected.DELETE("/providers/me/blackouts/:id", handler.DeleteBlackout)
 
 		protected.GET("/chat/history", handler.GetChatHistory)
+		protected.GET("/chat/conversations", handler.ListConversations)
+		protected.GET("/chat/unread", handler.GetChatUnread)
+		protected.POST("/chat/attachments", handler.UploadChatAttachment)
+		protected.POST("/chat/read", handler.MarkChatRead)
+		protected.PUT("/chat/messages/:id", handler.EditChatMessage)
+		protected.DELETE("/chat/messages/:id", handler.DeleteChatMessage)
+
+		protected.GET("/blocks", handler.ListBlocks)
+		protected.POST("/users/:id/block", handler.BlockUser)
+		protected.DELETE("/users/:id/block", handler.UnblockUser)
+		protected.POST("/reports", handler.CreateReport)
+	}
+
+	admin := api.Group("/admin")
+	admin.Use(AuthMiddleware(keys, clients.Accounts, clients.Revoked), RequireRole("admin"))
+	{
+		admin.GET("/users", handler.ListUsers)
+		admin.PUT("/users/:id/suspension", handler.SetUserSuspension)
+		admin.PUT("/services/:id", handler.UpdateCatalogService)
+		admin.DELETE("/services/:id", handler.DeleteCatalogService)
+		admin.GET("/bookings", handler.ListAllBookings)
+		admin.GET("/reports", handler.ListReports)
+		admin.PUT("/reports/:id", handler.ResolveReport)
+		admin.GET("/chat/dead-letters", handler.ListChatDeadLetters)
+		admin.POST("/chat/dead-letters/replay", handler.ReplayChatDeadLetters)
 	}
 
 	r.GET("/ws", func(c *gin.Context) {
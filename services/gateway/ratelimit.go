@@ -0,0 +1,73 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const maxRateLimitedClients = 10000
+
+type clientLimiter struct {
+	ip      string
+	limiter *rate.Limiter
+}
+
+// clientLimiters keeps the buckets of the most recently seen client
+// addresses. When it is full the address that has been quiet the longest is
+// dropped, so a flood of new addresses cannot reset everyone's bucket.
+type clientLimiters struct {
+	rps   rate.Limit
+	burst int
+	max   int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newClientLimiters(rps float64, burst, max int) *clientLimiters {
+	return &clientLimiters{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (l *clientLimiters) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.entries[ip]; ok {
+		l.order.MoveToFront(el)
+		return el.Value.(*clientLimiter).limiter
+	}
+
+	if l.order.Len() >= l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*clientLimiter).ip)
+	}
+	entry := &clientLimiter{ip: ip, limiter: rate.NewLimiter(l.rps, l.burst)}
+	l.entries[ip] = l.order.PushFront(entry)
+	return entry.limiter
+}
+
+// RateLimitMiddleware gives every client address its own bucket of burst
+// requests refilled at rps.
+func RateLimitMiddleware(rps float64, burst int) gin.HandlerFunc {
+	limiters := newClientLimiters(rps, burst, maxRateLimitedClients)
+
+	return func(c *gin.Context) {
+		if !limiters.get(c.ClientIP()).Allow() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxServiceNameLength = 255
+	maxIconURLLength     = 500
+	defaultAdminPageSize = 20
+	maxAdminPageSize     = 100
+)
+
+var (
+	ErrServiceInUse       = errors.New("service has bookings and cannot be deleted")
+	ErrInvalidServiceEdit = errors.New("invalid service update")
+	ErrInvalidBookingList = errors.New("invalid booking filter")
+)
+
+// iconClassPattern matches a Font Awesome class list such as "fas fa-broom",
+// the form the seeded catalog stores its icons in.
+var iconClassPattern = regexp.MustCompile(`^fa[srlbd]? fa-[a-z0-9-]+(?: fa-[a-z0-9-]+)*$`)
+
+// validIcon reports whether icon is an http(s) url or a Font Awesome class list.
+func validIcon(icon string) bool {
+	if iconClassPattern.MatchString(icon) {
+		return true
+	}
+	u, err := url.Parse(icon)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// ServiceCategories are the catalog sections services can be filed under.
+var ServiceCategories = []string{"cleaning", "repair", "renovation", "outdoor", "moving"}
+
+// normalizeCategory lower-cases category and reports whether it is one of
+// ServiceCategories. An empty category leaves the service uncategorized.
+func normalizeCategory(category string) (string, bool) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	return category, category == "" || slices.Contains(ServiceCategories, category)
+}
+
+type ServiceUpdate struct {
+	Name        *string
+	Description *string
+	IconURL     *string
+	Category    *string
+}
+
+func newServiceUpdate(req *models.UpdateServiceRequest) (*ServiceUpdate, error) {
+	update := &ServiceUpdate{Description: req.Description}
+
+	if req.Title != nil {
+		name := strings.TrimSpace(*req.Title)
+		if name == "" {
+			return nil, fmt.Errorf("%w: title must not be empty", ErrInvalidServiceEdit)
+		}
+		if utf8.RuneCountInString(name) > maxServiceNameLength {
+			return nil, fmt.Errorf("%w: title must be at most %d characters", ErrInvalidServiceEdit, maxServiceNameLength)
+		}
+		update.Name = &name
+	}
+	if req.Category != nil {
+		category, ok := normalizeCategory(*req.Category)
+		if !ok {
+			return nil, fmt.Errorf("%w: category must be one of %s", ErrInvalidServiceEdit, strings.Join(ServiceCategories, ", "))
+		}
+		update.Category = &category
+	}
+	if req.IconURL != nil {
+		icon := strings.TrimSpace(*req.IconURL)
+		if icon != "" {
+			if len(icon) > maxIconURLLength {
+				return nil, fmt.Errorf("%w: icon_url must be at most %d characters", ErrInvalidServiceEdit, maxIconURLLength)
+			}
+			if !validIcon(icon) {
+				return nil, fmt.Errorf("%w: icon_url must be an http(s) url or a Font Awesome class such as \"fas fa-broom\"", ErrInvalidServiceEdit)
+			}
+		}
+		update.IconURL = &icon
+	}
+
+	return update, nil
+}
+
+type BookingFilter struct {
+	Status     string
+	ClientID   *uuid.UUID
+	ProviderID *uuid.UUID
+	ServiceID  *uuid.UUID
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+func newBookingFilter(req *models.AdminListBookingsRequest) (*BookingFilter, error) {
+	f := &BookingFilter{Status: req.Status, Limit: req.Limit, Offset: req.Offset}
+
+	switch f.Status {
+	case "", StatusPending, StatusAccepted, StatusRejected, StatusCompleted, StatusCancelled:
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBookingList, ErrUnknownStatus)
+	}
+
+	ids := []struct {
+		name string
+		raw  string
+		dst  **uuid.UUID
+	}{
+		{"client_id", req.ClientID, &f.ClientID},
+		{"provider_id", req.ProviderID, &f.ProviderID},
+		{"service_id", req.ServiceID, &f.ServiceID},
+	}
+	for _, id := range ids {
+		if id.raw == "" {
+			continue
+		}
+		parsed, err := uuid.Parse(id.raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a uuid", ErrInvalidBookingList, id.name)
+		}
+		*id.dst = &parsed
+	}
+
+	times := []struct {
+		name string
+		raw  string
+		dst  **time.Time
+	}{
+		{"from", req.From, &f.From},
+		{"to", req.To, &f.To},
+	}
+	for _, t := range times {
+		if t.raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be RFC3339", ErrInvalidBookingList, t.name)
+		}
+		wall := wallClock(parsed)
+		*t.dst = &wall
+	}
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidBookingList)
+	}
+
+	if f.Limit <= 0 || f.Limit > maxAdminPageSize {
+		f.Limit = defaultAdminPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f, nil
+}
+
+// build returns the WHERE clause over bookings as b and its arguments.
+func (f *BookingFilter) build() (string, []interface{}) {
+	var args []interface{}
+	add := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conds := []string{"TRUE"}
+	if f.Status != "" {
+		conds = append(conds, "b.status = "+add(f.Status))
+	}
+	if f.ClientID != nil {
+		conds = append(conds, "b.client_id = "+add(*f.ClientID))
+	}
+	if f.ProviderID != nil {
+		conds = append(conds, "b.provider_id = "+add(*f.ProviderID))
+	}
+	if f.ServiceID != nil {
+		conds = append(conds, "b.service_id = "+add(*f.ServiceID))
+	}
+	if f.From != nil {
+		conds = append(conds, "b.scheduled_date >= "+add(*f.From))
+	}
+	if f.To != nil {
+		conds = append(conds, "b.scheduled_date < "+add(*f.To))
+	}
+	return strings.Join(conds, " AND "), args
+}
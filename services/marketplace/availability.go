@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const maxAvailabilityRange = 31 * 24 * time.Hour
+
+var (
+	ErrBookingOverlap    = errors.New("provider already has a booking at that time")
+	ErrOutsideWorkHours  = errors.New("provider is not available at that time")
+	ErrInvalidClock      = errors.New("time of day must be in HH:MM format")
+	ErrNotProvider       = errors.New("user is not a provider")
+	ErrBlackoutNotFound  = errors.New("blackout not found")
+	ErrInvalidTimeWindow = errors.New("end must be after start")
+)
+
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// alwaysOpen is used for providers that have not published working hours yet,
+// so existing profiles stay bookable until they opt into a schedule.
+var alwaysOpen = func() []*WorkingHours {
+	hours := make([]*WorkingHours, 0, 7)
+	for day := 0; day < 7; day++ {
+		hours = append(hours, &WorkingHours{Weekday: day, StartTime: "00:00", EndTime: "24:00"})
+	}
+	return hours
+}()
+
+// wallClock drops the location of t while keeping its clock reading.
+// bookings.scheduled_date is a TIMESTAMP without time zone, so Postgres stores
+// the client's wall-clock time and hands it back tagged as UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return 0, ErrInvalidClock
+	}
+	if h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, ErrInvalidClock
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+func validateWorkingHours(hours []*WorkingHours) error {
+	for _, wh := range hours {
+		if wh.Weekday < 0 || wh.Weekday > 6 {
+			return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		start, err := parseClock(wh.StartTime)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(wh.EndTime)
+		if err != nil {
+			return err
+		}
+		if end <= start {
+			return ErrInvalidTimeWindow
+		}
+	}
+	return nil
+}
+
+// workingWindows expands the weekly schedule into concrete windows that
+// intersect [from, to).
+func workingWindows(hours []*WorkingHours, from, to time.Time) []timeRange {
+	var windows []timeRange
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, wh := range hours {
+			if time.Weekday(wh.Weekday) != day.Weekday() {
+				continue
+			}
+			start, err := parseClock(wh.StartTime)
+			if err != nil {
+				continue
+			}
+			end, err := parseClock(wh.EndTime)
+			if err != nil {
+				continue
+			}
+			w := timeRange{Start: day.Add(start), End: day.Add(end)}
+			if w.Start.Before(from) {
+				w.Start = from
+			}
+			if w.End.After(to) {
+				w.End = to
+			}
+			if w.Start.Before(w.End) {
+				windows = append(windows, w)
+			}
+		}
+	}
+	return mergeRanges(windows)
+}
+
+func mergeRanges(ranges []timeRange) []timeRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Before(ranges[j].Start) })
+
+	merged := []timeRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if !r.Start.After(last.End) {
+			if r.End.After(last.End) {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+func subtractRanges(windows, busy []timeRange) []timeRange {
+	busy = mergeRanges(busy)
+	var free []timeRange
+	for _, w := range windows {
+		cursor := w.Start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(w.End) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				free = append(free, timeRange{Start: cursor, End: b.Start})
+			}
+			cursor = b.End
+		}
+		if cursor.Before(w.End) {
+			free = append(free, timeRange{Start: cursor, End: w.End})
+		}
+	}
+	return free
+}
+
+func busyRanges(blackouts []*Blackout, bookings []*Booking) []timeRange {
+	busy := make([]timeRange, 0, len(blackouts)+len(bookings))
+	for _, b := range blackouts {
+		busy = append(busy, timeRange{Start: b.StartsAt, End: b.EndsAt})
+	}
+	for _, b := range bookings {
+		busy = append(busy, timeRange{Start: b.ScheduledDate, End: b.EndTime()})
+	}
+	return busy
+}
+
+func freeSlots(hours []*WorkingHours, blackouts []*Blackout, bookings []*Booking, from, to time.Time) []timeRange {
+	if len(hours) == 0 {
+		hours = alwaysOpen
+	}
+	return subtractRanges(workingWindows(hours, from, to), busyRanges(blackouts, bookings))
+}
+
+// fitsSchedule reports whether the slot lies entirely inside published working
+// hours and clear of every blackout.
+func fitsSchedule(hours []*WorkingHours, blackouts []*Blackout, slot timeRange) bool {
+	if len(hours) == 0 {
+		hours = alwaysOpen
+	}
+	// Look a day either side so a slot that starts late in the evening can be
+	// matched against windows that continue past midnight.
+	from := slot.Start.AddDate(0, 0, -1)
+	to := slot.End.AddDate(0, 0, 1)
+	for _, free := range subtractRanges(workingWindows(hours, from, to), busyRanges(blackouts, nil)) {
+		if !free.Start.After(slot.Start) && !free.End.Before(slot.End) {
+			return true
+		}
+	}
+	return false
+}
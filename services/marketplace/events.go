@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrPublishNotConfirmed = errors.New("broker did not confirm publish")
+	ErrInvalidOutboxEvent  = errors.New("outbox event cannot be published")
+)
+
+const (
+	outboxPollInterval = time.Second
+	outboxMaxBackoff   = time.Minute
+	outboxBatchSize    = 50
+)
+
+// EventPublisher announces booking changes to other services.
+type EventPublisher interface {
+	PublishBookingStatusChanged(ctx context.Context, event *models.BookingStatusChangedEvent) error
+}
+
+type RabbitMQPublisher struct {
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+func NewRabbitMQPublisher(url string) (*RabbitMQPublisher, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.ExchangeDeclare(models.MarketplaceEventsExchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &RabbitMQPublisher{conn: conn, ch: ch}, nil
+}
+
+func (p *RabbitMQPublisher) PublishBookingStatusChanged(ctx context.Context, event *models.BookingStatusChangedEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	confirm, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, models.MarketplaceEventsExchange, models.BookingStatusChangedKey, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+	if err != nil {
+		return err
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPublishNotConfirmed
+	}
+	return nil
+}
+
+func (p *RabbitMQPublisher) Close() {
+	p.ch.Close()
+	p.conn.Close()
+}
+
+// StartOutboxRelay publishes the events UpdateBookingStatus leaves in the
+// outbox until ctx is cancelled. Delivery is at least once: consumers must
+// tolerate seeing an event twice. While the broker is unreachable the relay
+// backs off and the events wait in the outbox.
+func StartOutboxRelay(ctx context.Context, store IStore, events EventPublisher) {
+	wait := outboxPollInterval
+	for {
+		if _, err := relayOutbox(ctx, store, events); err != nil {
+			logger.Error("failed to relay outbox events, retrying in "+wait.String(), err)
+			wait = min(wait*2, outboxMaxBackoff)
+		} else {
+			wait = outboxPollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func relayOutbox(ctx context.Context, store IStore, events EventPublisher) (int, error) {
+	return store.PublishOutbox(ctx, outboxBatchSize, func(e *OutboxEvent) error {
+		switch e.RoutingKey {
+		case models.BookingStatusChangedKey:
+			var event models.BookingStatusChangedEvent
+			if err := json.Unmarshal(e.Payload, &event); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidOutboxEvent, err)
+			}
+			return events.PublishBookingStatusChanged(ctx, &event)
+		}
+		return fmt.Errorf("%w: unknown routing key %q", ErrInvalidOutboxEvent, e.RoutingKey)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/db"
 	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +20,11 @@ func main() {
 	logger.Init()
 	cfg := config.Load()
 
+	if err := models.RegisterValidators(); err != nil {
+		logger.Error("failed to register validators", err)
+		os.Exit(1)
+	}
+
 	database, err := db.Connect(cfg.DBUrl)
 	if err != nil {
 		logger.Error("failed to connect to db", err)
@@ -27,15 +33,42 @@ func main() {
 	defer database.Close()
 
 	store := NewStore(database)
+
+	events, err := NewRabbitMQPublisher(cfg.RabbitMQUrl)
+	if err != nil {
+		logger.Error("failed to connect to rabbitmq", err)
+		os.Exit(1)
+	}
+	defer events.Close()
+
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go StartOutboxRelay(relayCtx, store, events)
+
 	server := NewServer(store)
 
 	r := gin.Default()
 
 	r.GET("/services", server.GetServices)
 	r.POST("/services", server.CreateService)
+	r.GET("/services/:id/providers", server.ListServiceProviders)
+	r.POST("/services/:id/providers", server.OfferService)
+	r.DELETE("/services/:id/providers", server.WithdrawService)
 	r.GET("/bookings", server.ListBookings)
 	r.POST("/bookings", server.CreateBooking)
+	r.GET("/bookings/:id", server.GetBooking)
 	r.PUT("/bookings/:id/status", server.UpdateBookingStatus)
+	r.POST("/bookings/:id/review", server.CreateReview)
+	r.GET("/providers/:id/reviews", server.ListReviews)
+	r.GET("/providers/:id/availability", server.GetAvailability)
+	r.GET("/providers/:id/working-hours", server.GetWorkingHours)
+	r.PUT("/availability/working-hours", server.SetWorkingHours)
+	r.GET("/availability/blackouts", server.ListBlackouts)
+	r.POST("/availability/blackouts", server.CreateBlackout)
+	r.DELETE("/availability/blackouts/:id", server.DeleteBlackout)
+	r.PUT("/admin/services/:id", server.UpdateService)
+	r.DELETE("/admin/services/:id", server.DeleteService)
+	r.GET("/admin/bookings", server.AdminListBookings)
 
 	port := config.GetMarketplacePort()
 	srv := &http.Server{
@@ -55,6 +88,8 @@ func main() {
 	<-quit
 	logger.Info("Shutting down Marketplace Service...")
 
+	stopRelay()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
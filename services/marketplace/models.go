@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,10 +13,34 @@ type Service struct {
 	Name        string    `db:"name"`
 	Description string    `db:"description"`
 	IconURL     string    `db:"icon_url"`
+	Category    string    `db:"category"`
 	CreatedAt   time.Time `db:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
 }
 
+type ProviderService struct {
+	ProviderID  uuid.UUID       `db:"provider_id"`
+	ServiceID   uuid.UUID       `db:"service_id"`
+	Title       string          `db:"title"`
+	Description string          `db:"description"`
+	Price       sql.NullFloat64 `db:"price"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+type ServiceOffering struct {
+	ProviderID   uuid.UUID `db:"provider_id"`
+	UserID       uuid.UUID `db:"user_id"`
+	FullName     string    `db:"full_name"`
+	Title        string    `db:"title"`
+	Description  string    `db:"description"`
+	Price        float64   `db:"price"`
+	Location     string    `db:"location"`
+	Rating       float64   `db:"rating"`
+	TotalReviews int       `db:"total_reviews"`
+	IsAvailable  bool      `db:"is_available"`
+}
+
 type Booking struct {
 	ID            uuid.UUID `db:"id"`
 	ClientID      uuid.UUID `db:"client_id"`
@@ -24,7 +50,75 @@ type Booking struct {
 	DurationHours float64   `db:"duration_hours"`
 	Status        string    `db:"status"`
 	TotalPrice    float64   `db:"total_price"`
+	HourlyRate    float64   `db:"hourly_rate"`
+	Currency      string    `db:"currency"`
 	Notes         string    `db:"notes"`
 	CreatedAt     time.Time `db:"created_at"`
 	UpdatedAt     time.Time `db:"updated_at"`
+
+	ProviderUserID uuid.UUID `db:"provider_user_id"`
+}
+
+type Review struct {
+	ID         uuid.UUID `db:"id"`
+	BookingID  uuid.UUID `db:"booking_id"`
+	ClientID   uuid.UUID `db:"client_id"`
+	ProviderID uuid.UUID `db:"provider_id"`
+	Rating     int       `db:"rating"`
+	Comment    string    `db:"comment"`
+	CreatedAt  time.Time `db:"created_at"`
+
+	ClientName string `db:"client_name"`
+}
+
+func (b *Booking) EndTime() time.Time {
+	return b.ScheduledDate.Add(time.Duration(b.DurationHours * float64(time.Hour)))
+}
+
+type ProviderRate struct {
+	HourlyRate    float64         `db:"hourly_rate"`
+	ServiceRate   sql.NullFloat64 `db:"service_rate"`
+	OffersService bool            `db:"offers_service"`
+}
+
+// Rate is the hourly price the provider charges for the service, preferring
+// their per-service override from provider_services when one is set.
+func (r *ProviderRate) Rate() float64 {
+	if r.ServiceRate.Valid {
+		return r.ServiceRate.Float64
+	}
+	return r.HourlyRate
+}
+
+func bookingPrice(hourlyRate, hours float64) float64 {
+	return math.Round(hourlyRate*hours*100) / 100
+}
+
+type WorkingHours struct {
+	ID         uuid.UUID `db:"id"`
+	ProviderID uuid.UUID `db:"provider_id"`
+	Weekday    int       `db:"weekday"`
+	StartTime  string    `db:"start_time"`
+	EndTime    string    `db:"end_time"`
+}
+
+type Blackout struct {
+	ID         uuid.UUID `db:"id"`
+	ProviderID uuid.UUID `db:"provider_id"`
+	StartsAt   time.Time `db:"starts_at"`
+	EndsAt     time.Time `db:"ends_at"`
+	Reason     string    `db:"reason"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// OutboxEvent is an event written in the same transaction as the change it
+// announces and published to RabbitMQ afterwards.
+type OutboxEvent struct {
+	ID          uuid.UUID  `db:"id"`
+	RoutingKey  string     `db:"routing_key"`
+	Payload     []byte     `db:"payload"`
+	LastError   *string    `db:"last_error"`
+	CreatedAt   time.Time  `db:"created_at"`
+	PublishedAt *time.Time `db:"published_at"`
+	FailedAt    *time.Time `db:"failed_at"`
 }
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"qasynda/shared/pkg/logger"
@@ -11,6 +15,11 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	maxBookingHours = 24
+	defaultCurrency = "KZT"
+)
+
 type Server struct {
 	store IStore
 }
@@ -26,16 +35,45 @@ func (s *Server) CreateService(c *gin.Context) {
 		return
 	}
 
-	id := uuid.New()
+	if strings.TrimSpace(req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	if req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must not be negative"})
+		return
+	}
+	category, ok := normalizeCategory(req.Category)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category must be one of " + strings.Join(ServiceCategories, ", ")})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
 	service := &Service{
-		ID:          id,
+		ID:          uuid.New(),
 		Name:        req.Title,
 		Description: req.Description,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		Category:    category,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	offering := &ProviderService{
+		ProviderID:  providerID,
+		ServiceID:   service.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		Price:       sql.NullFloat64{Float64: req.Price, Valid: req.Price > 0},
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	if err := s.store.CreateService(c.Request.Context(), service); err != nil {
+	if err := s.store.CreateService(c.Request.Context(), service, offering); err != nil {
 		logger.Error("failed to create service", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
@@ -45,12 +83,16 @@ func (s *Server) CreateService(c *gin.Context) {
 		ID:          service.ID.String(),
 		Title:       service.Name,
 		Description: service.Description,
+		Category:    service.Category,
+		Price:       req.Price,
+		ProviderID:  providerID.String(),
 	})
 }
 
 func (s *Server) GetServices(c *gin.Context) {
+	category := strings.ToLower(strings.TrimSpace(c.Query("category")))
 
-	services, err := s.store.ListServices(c.Request.Context())
+	services, err := s.store.ListServices(c.Request.Context(), category)
 	if err != nil {
 		logger.Error("failed to list services", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
@@ -63,6 +105,8 @@ func (s *Server) GetServices(c *gin.Context) {
 			ID:          svc.ID.String(),
 			Title:       svc.Name,
 			Description: svc.Description,
+			Category:    svc.Category,
+			IconURL:     svc.IconURL,
 		})
 	}
 
@@ -71,6 +115,119 @@ func (s *Server) GetServices(c *gin.Context) {
 	})
 }
 
+func (s *Server) OfferService(c *gin.Context) {
+	serviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service id"})
+		return
+	}
+
+	var req models.OfferServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Price != nil && *req.Price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "price must not be negative"})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	offering := &ProviderService{
+		ProviderID:  providerID,
+		ServiceID:   serviceID,
+		Title:       req.Title,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.Price != nil {
+		offering.Price = sql.NullFloat64{Float64: *req.Price, Valid: true}
+	}
+
+	if err := s.store.UpsertProviderService(c.Request.Context(), offering); err != nil {
+		if errors.Is(err, ErrServiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to save provider service", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &models.ServiceOfferingResponse{
+		ServiceID:   serviceID.String(),
+		ProviderID:  providerID.String(),
+		Title:       offering.Title,
+		Description: offering.Description,
+		Price:       offering.Price.Float64,
+	})
+}
+
+func (s *Server) WithdrawService(c *gin.Context) {
+	serviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service id"})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteProviderService(c.Request.Context(), providerID, serviceID); err != nil {
+		if errors.Is(err, ErrServiceNotOffered) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to delete provider service", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+func (s *Server) ListServiceProviders(c *gin.Context) {
+	serviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service id"})
+		return
+	}
+
+	offerings, err := s.store.ListServiceProviders(c.Request.Context(), serviceID)
+	if err != nil {
+		logger.Error("failed to list service providers", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respProviders := make([]*models.ServiceOfferingResponse, 0, len(offerings))
+	for _, o := range offerings {
+		respProviders = append(respProviders, &models.ServiceOfferingResponse{
+			ServiceID:    serviceID.String(),
+			ProviderID:   o.ProviderID.String(),
+			UserID:       o.UserID.String(),
+			FullName:     o.FullName,
+			Title:        o.Title,
+			Description:  o.Description,
+			Price:        o.Price,
+			Location:     o.Location,
+			Rating:       o.Rating,
+			TotalReviews: o.TotalReviews,
+			IsAvailable:  o.IsAvailable,
+		})
+	}
+
+	c.JSON(http.StatusOK, &models.ListServiceProvidersResponse{Providers: respProviders})
+}
+
 func (s *Server) CreateBooking(c *gin.Context) {
 	var req models.CreateBookingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -101,28 +258,92 @@ func (s *Server) CreateBooking(c *gin.Context) {
 		return
 	}
 
+	duration := req.DurationHours
+	if duration == 0 {
+		duration = 1.0
+	}
+	if duration < 0 || duration > maxBookingHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration_hours must be between 0 and 24"})
+		return
+	}
+
+	rate, err := s.store.GetProviderRate(c.Request.Context(), providerID, serviceID)
+	if err != nil {
+		if errors.Is(err, ErrProviderNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to get provider rate", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if !rate.OffersService {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrServiceNotOffered.Error()})
+		return
+	}
+
+	blocked, err := s.store.IsBlocked(c.Request.Context(), clientID, providerID)
+	if err != nil {
+		logger.Error("failed to check blocks", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrUserBlocked.Error()})
+		return
+	}
+
+	start := wallClock(scheduledTime)
+	slot := timeRange{Start: start, End: start.Add(time.Duration(duration * float64(time.Hour)))}
+
+	hours, err := s.store.ListWorkingHours(c.Request.Context(), providerID)
+	if err != nil {
+		logger.Error("failed to list working hours", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	blackouts, err := s.store.ListBlackouts(c.Request.Context(), providerID, slot.Start, slot.End)
+	if err != nil {
+		logger.Error("failed to list blackouts", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if !fitsSchedule(hours, blackouts, slot) {
+		c.JSON(http.StatusConflict, gin.H{"error": ErrOutsideWorkHours.Error()})
+		return
+	}
+
 	id := uuid.New()
 	booking := &Booking{
 		ID:            id,
 		ClientID:      clientID,
 		ProviderID:    providerID,
 		ServiceID:     serviceID,
-		ScheduledDate: scheduledTime,
-		Status:        "pending",
-		DurationHours: 1.0,
+		ScheduledDate: start,
+		Status:        StatusPending,
+		DurationHours: duration,
+		HourlyRate:    rate.Rate(),
+		TotalPrice:    bookingPrice(rate.Rate(), duration),
+		Currency:      defaultCurrency,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
 	if err := s.store.CreateBooking(c.Request.Context(), booking); err != nil {
+		if errors.Is(err, ErrBookingOverlap) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Error("failed to create booking", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
 	c.JSON(http.StatusOK, &models.BookingResponse{
-		ID:     booking.ID.String(),
-		Status: booking.Status,
+		ID:         booking.ID.String(),
+		Status:     booking.Status,
+		TotalPrice: booking.TotalPrice,
+		Currency:   booking.Currency,
 	})
 }
 
@@ -153,6 +374,9 @@ func (s *Server) ListBookings(c *gin.Context) {
 			ScheduledTime:  b.ScheduledDate.Format(time.RFC3339),
 			ServiceTitle:   "Service " + b.ServiceID.String(),
 			OtherPartyName: "User " + b.ClientID.String(),
+			DurationHours:  b.DurationHours,
+			TotalPrice:     b.TotalPrice,
+			Currency:       b.Currency,
 		})
 	}
 
@@ -162,23 +386,608 @@ func (s *Server) ListBookings(c *gin.Context) {
 }
 
 func (s *Server) UpdateBookingStatus(c *gin.Context) {
+	bookingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking id"})
+		return
+	}
 
-	bookingID := c.Param("id")
 	var req models.UpdateBookingStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	err := s.store.UpdateBookingStatus(c.Request.Context(), bookingID, req.Status)
+	userID, err := uuid.Parse(req.UserID)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	booking, err := s.store.GetBooking(c.Request.Context(), bookingID.String())
+	if err != nil {
+		logger.Error("failed to get booking", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+
+	if err := checkTransition(booking, req.Status, userID); err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+
+	event := &models.BookingStatusChangedEvent{
+		BookingID:      bookingID.String(),
+		ClientID:       booking.ClientID.String(),
+		ProviderUserID: booking.ProviderUserID.String(),
+		From:           booking.Status,
+		To:             req.Status,
+		ChangedBy:      userID.String(),
+		ChangedAt:      time.Now().UTC().Format(time.RFC3339),
+	}
+	err = s.store.UpdateBookingStatus(c.Request.Context(), bookingID, booking.Status, req.Status, userID, event)
+	if err != nil {
+		if errors.Is(err, ErrStatusChanged) {
+			respondTransitionError(c, err)
+			return
+		}
 		logger.Error("failed to update booking status", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
 	c.JSON(http.StatusOK, &models.BookingResponse{
-		ID:     bookingID,
+		ID:     bookingID.String(),
 		Status: req.Status,
 	})
 }
+
+func (s *Server) GetBooking(c *gin.Context) {
+	bookingID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking id"})
+		return
+	}
+
+	booking, err := s.store.GetBooking(c.Request.Context(), bookingID.String())
+	if err != nil {
+		logger.Error("failed to get booking", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toBookingDetails(booking))
+}
+
+func toBookingDetails(b *Booking) *models.BookingDetails {
+	return &models.BookingDetails{
+		ID:             b.ID.String(),
+		ServiceID:      b.ServiceID.String(),
+		ClientID:       b.ClientID.String(),
+		ProviderID:     b.ProviderID.String(),
+		ProviderUserID: b.ProviderUserID.String(),
+		Status:         b.Status,
+		ScheduledTime:  b.ScheduledDate.Format(time.RFC3339),
+		DurationHours:  b.DurationHours,
+		TotalPrice:     b.TotalPrice,
+		Currency:       b.Currency,
+	}
+}
+
+func respondTransitionError(c *gin.Context, err error) {
+	var transitionErr *TransitionError
+	switch {
+	case errors.As(err, &transitionErr):
+		status := http.StatusConflict
+		if transitionErr.AllowedActor != "" {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, &models.TransitionErrorResponse{
+			Error: transitionErr.Error(),
+			Code:  "invalid_transition",
+			From:  transitionErr.From,
+			To:    transitionErr.To,
+		})
+	case errors.Is(err, ErrStatusChanged):
+		c.JSON(http.StatusConflict, &models.TransitionErrorResponse{
+			Error: err.Error(),
+			Code:  "status_changed",
+		})
+	case errors.Is(err, ErrNotParty):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrUnknownStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		logger.Error("unexpected transition error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
+
+func (s *Server) CreateReview(c *gin.Context) {
+	bookingID := c.Param("id")
+	var req models.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Rating < 1 || req.Rating > 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be between 1 and 5"})
+		return
+	}
+	clientID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	if _, err := uuid.Parse(bookingID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid booking id"})
+		return
+	}
+
+	booking, err := s.store.GetBooking(c.Request.Context(), bookingID)
+	if err != nil {
+		logger.Error("failed to get booking", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "booking not found"})
+		return
+	}
+	if booking.ClientID != clientID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the client of a booking can review it"})
+		return
+	}
+	if booking.Status != StatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "only completed bookings can be reviewed"})
+		return
+	}
+
+	review := &Review{
+		ID:         uuid.New(),
+		BookingID:  booking.ID,
+		ClientID:   clientID,
+		ProviderID: booking.ProviderID,
+		Rating:     req.Rating,
+		Comment:    req.Comment,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := s.store.CreateReview(c.Request.Context(), review); err != nil {
+		if errors.Is(err, ErrReviewExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to create review", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toReviewResponse(review))
+}
+
+func (s *Server) ListReviews(c *gin.Context) {
+	providerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	reviews, total, err := s.store.ListReviews(c.Request.Context(), providerID, limit, offset)
+	if err != nil {
+		logger.Error("failed to list reviews", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respReviews := make([]*models.ReviewResponse, 0, len(reviews))
+	for _, r := range reviews {
+		respReviews = append(respReviews, toReviewResponse(r))
+	}
+
+	c.JSON(http.StatusOK, &models.ListReviewsResponse{
+		Reviews: respReviews,
+		Total:   total,
+	})
+}
+
+func toReviewResponse(r *Review) *models.ReviewResponse {
+	return &models.ReviewResponse{
+		ID:         r.ID.String(),
+		BookingID:  r.BookingID.String(),
+		ProviderID: r.ProviderID.String(),
+		ClientID:   r.ClientID.String(),
+		ClientName: r.ClientName,
+		Rating:     r.Rating,
+		Comment:    r.Comment,
+		CreatedAt:  r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func (s *Server) SetWorkingHours(c *gin.Context) {
+	var req models.SetWorkingHoursRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	hours := make([]*WorkingHours, 0, len(req.Hours))
+	for _, wh := range req.Hours {
+		hours = append(hours, &WorkingHours{
+			ID:         uuid.New(),
+			ProviderID: providerID,
+			Weekday:    wh.Weekday,
+			StartTime:  wh.StartTime,
+			EndTime:    wh.EndTime,
+		})
+	}
+	if err := validateWorkingHours(hours); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.ReplaceWorkingHours(c.Request.Context(), providerID, hours); err != nil {
+		logger.Error("failed to save working hours", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &models.WorkingHoursResponse{
+		ProviderID: providerID.String(),
+		Hours:      req.Hours,
+	})
+}
+
+func (s *Server) GetWorkingHours(c *gin.Context) {
+	providerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider id"})
+		return
+	}
+
+	hours, err := s.store.ListWorkingHours(c.Request.Context(), providerID)
+	if err != nil {
+		logger.Error("failed to list working hours", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respHours := make([]*models.WorkingHours, 0, len(hours))
+	for _, wh := range hours {
+		respHours = append(respHours, &models.WorkingHours{
+			Weekday:   wh.Weekday,
+			StartTime: wh.StartTime,
+			EndTime:   wh.EndTime,
+		})
+	}
+
+	c.JSON(http.StatusOK, &models.WorkingHoursResponse{
+		ProviderID: providerID.String(),
+		Hours:      respHours,
+	})
+}
+
+func (s *Server) CreateBlackout(c *gin.Context) {
+	var req models.CreateBlackoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid starts_at format (use ISO8601/RFC3339)"})
+		return
+	}
+	endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ends_at format (use ISO8601/RFC3339)"})
+		return
+	}
+	if !endsAt.After(startsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidTimeWindow.Error()})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	blackout := &Blackout{
+		ID:         uuid.New(),
+		ProviderID: providerID,
+		StartsAt:   wallClock(startsAt),
+		EndsAt:     wallClock(endsAt),
+		Reason:     req.Reason,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := s.store.CreateBlackout(c.Request.Context(), blackout); err != nil {
+		logger.Error("failed to create blackout", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toBlackoutResponse(blackout))
+}
+
+func (s *Server) ListBlackouts(c *gin.Context) {
+	providerID, ok := s.resolveProvider(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	from := time.Now().UTC()
+	blackouts, err := s.store.ListBlackouts(c.Request.Context(), providerID, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		logger.Error("failed to list blackouts", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respBlackouts := make([]*models.BlackoutResponse, 0, len(blackouts))
+	for _, b := range blackouts {
+		respBlackouts = append(respBlackouts, toBlackoutResponse(b))
+	}
+
+	c.JSON(http.StatusOK, &models.ListBlackoutsResponse{Blackouts: respBlackouts})
+}
+
+func (s *Server) DeleteBlackout(c *gin.Context) {
+	blackoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid blackout id"})
+		return
+	}
+
+	providerID, ok := s.resolveProvider(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	if err := s.store.DeleteBlackout(c.Request.Context(), providerID, blackoutID); err != nil {
+		if errors.Is(err, ErrBlackoutNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to delete blackout", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+func (s *Server) GetAvailability(c *gin.Context) {
+	providerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider id"})
+		return
+	}
+
+	from, err := parseDateOrTime(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from (use YYYY-MM-DD or RFC3339)"})
+		return
+	}
+	to, err := parseDateOrTime(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to (use YYYY-MM-DD or RFC3339)"})
+		return
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidTimeWindow.Error()})
+		return
+	}
+	if to.Sub(from) > maxAvailabilityRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range must not exceed 31 days"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	hours, err := s.store.ListWorkingHours(ctx, providerID)
+	if err != nil {
+		logger.Error("failed to list working hours", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	blackouts, err := s.store.ListBlackouts(ctx, providerID, from, to)
+	if err != nil {
+		logger.Error("failed to list blackouts", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	bookings, err := s.store.ListActiveBookings(ctx, providerID, from, to)
+	if err != nil {
+		logger.Error("failed to list bookings", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	slots := make([]*models.TimeSlot, 0)
+	for _, slot := range freeSlots(hours, blackouts, bookings, from, to) {
+		slots = append(slots, &models.TimeSlot{
+			Start: slot.Start.Format(time.RFC3339),
+			End:   slot.End.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, &models.AvailabilityResponse{
+		ProviderID: providerID.String(),
+		From:       from.Format(time.RFC3339),
+		To:         to.Format(time.RFC3339),
+		Slots:      slots,
+	})
+}
+
+// resolveProvider maps the calling user to their service_providers row and
+// writes the error response itself when that is not possible.
+func (s *Server) resolveProvider(c *gin.Context, userIDStr string) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return uuid.Nil, false
+	}
+
+	providerID, err := s.store.GetProviderIDByUserID(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, ErrNotProvider) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return uuid.Nil, false
+		}
+		logger.Error("failed to get provider", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return uuid.Nil, false
+	}
+
+	return providerID, true
+}
+
+func parseDateOrTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return wallClock(t), nil
+}
+
+func toBlackoutResponse(b *Blackout) *models.BlackoutResponse {
+	return &models.BlackoutResponse{
+		ID:         b.ID.String(),
+		ProviderID: b.ProviderID.String(),
+		StartsAt:   b.StartsAt.Format(time.RFC3339),
+		EndsAt:     b.EndsAt.Format(time.RFC3339),
+		Reason:     b.Reason,
+	}
+}
+
+func (s *Server) UpdateService(c *gin.Context) {
+	var req models.UpdateServiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	serviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service id"})
+		return
+	}
+
+	update, err := newServiceUpdate(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.store.UpdateService(c.Request.Context(), serviceID, update); err != nil {
+		if errors.Is(err, ErrServiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to update service", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	svc, err := s.store.GetService(c.Request.Context(), serviceID)
+	if err != nil {
+		logger.Error("failed to reload service", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &models.ServiceResponse{
+		ID:          svc.ID.String(),
+		Title:       svc.Name,
+		Description: svc.Description,
+		Category:    svc.Category,
+		IconURL:     svc.IconURL,
+	})
+}
+
+func (s *Server) DeleteService(c *gin.Context) {
+	serviceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid service id"})
+		return
+	}
+
+	if err := s.store.DeleteService(c.Request.Context(), serviceID); err != nil {
+		switch {
+		case errors.Is(err, ErrServiceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrServiceInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.Error("failed to delete service", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": true})
+}
+
+func (s *Server) AdminListBookings(c *gin.Context) {
+	req := &models.AdminListBookingsRequest{
+		Status:     c.Query("status"),
+		ClientID:   c.Query("client_id"),
+		ProviderID: c.Query("provider_id"),
+		ServiceID:  c.Query("service_id"),
+		From:       c.Query("from"),
+		To:         c.Query("to"),
+	}
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAdminPageSize)))
+	req.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	filter, err := newBookingFilter(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookings, total, err := s.store.ListAllBookings(c.Request.Context(), filter)
+	if err != nil {
+		logger.Error("failed to list bookings", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.AdminListBookingsResponse{
+		Bookings: make([]*models.BookingDetails, 0, len(bookings)),
+		Total:    total,
+	}
+	for _, b := range bookings {
+		resp.Bookings = append(resp.Bookings, toBookingDetails(b))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
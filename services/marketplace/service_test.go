@@ -3,10 +3,13 @@ package main
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"qasynda/shared/pkg/models"
 
@@ -20,16 +23,31 @@ type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) CreateService(ctx context.Context, service *Service) error {
-	args := m.Called(ctx, service)
+func (m *MockStore) CreateService(ctx context.Context, service *Service, offering *ProviderService) error {
+	args := m.Called(ctx, service, offering)
 	return args.Error(0)
 }
 
-func (m *MockStore) ListServices(ctx context.Context) ([]*Service, error) {
-	args := m.Called(ctx)
+func (m *MockStore) ListServices(ctx context.Context, category string) ([]*Service, error) {
+	args := m.Called(ctx, category)
 	return args.Get(0).([]*Service), args.Error(1)
 }
 
+func (m *MockStore) UpsertProviderService(ctx context.Context, offering *ProviderService) error {
+	args := m.Called(ctx, offering)
+	return args.Error(0)
+}
+
+func (m *MockStore) DeleteProviderService(ctx context.Context, providerID, serviceID uuid.UUID) error {
+	args := m.Called(ctx, providerID, serviceID)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListServiceProviders(ctx context.Context, serviceID uuid.UUID) ([]*ServiceOffering, error) {
+	args := m.Called(ctx, serviceID)
+	return args.Get(0).([]*ServiceOffering), args.Error(1)
+}
+
 func (m *MockStore) CreateBooking(ctx context.Context, booking *Booking) error {
 	args := m.Called(ctx, booking)
 	return args.Error(0)
@@ -40,11 +58,29 @@ func (m *MockStore) ListBookings(ctx context.Context, userID string, role string
 	return args.Get(0).([]*Booking), args.Error(1)
 }
 
-func (m *MockStore) UpdateBookingStatus(ctx context.Context, bookingID string, status string) error {
-	args := m.Called(ctx, bookingID, status)
+func (m *MockStore) UpdateBookingStatus(ctx context.Context, bookingID uuid.UUID, from, to string, changedBy uuid.UUID, event *models.BookingStatusChangedEvent) error {
+	args := m.Called(ctx, bookingID, from, to, changedBy, event)
 	return args.Error(0)
 }
 
+// PublishOutbox hands each of the events the test queued with On to publish
+// and reports how many went through, like the real store.
+func (m *MockStore) PublishOutbox(ctx context.Context, limit int, publish func(*OutboxEvent) error) (int, error) {
+	args := m.Called(ctx, limit)
+	published := 0
+	for _, event := range args.Get(0).([]*OutboxEvent) {
+		err := publish(event)
+		if errors.Is(err, ErrInvalidOutboxEvent) {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, args.Error(1)
+}
+
 func (m *MockStore) GetBooking(ctx context.Context, bookingID string) (*Booking, error) {
 	args := m.Called(ctx, bookingID)
 	if args.Get(0) == nil {
@@ -53,6 +89,96 @@ func (m *MockStore) GetBooking(ctx context.Context, bookingID string) (*Booking,
 	return args.Get(0).(*Booking), args.Error(1)
 }
 
+func (m *MockStore) CreateReview(ctx context.Context, review *Review) error {
+	args := m.Called(ctx, review)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListReviews(ctx context.Context, providerID uuid.UUID, limit, offset int) ([]*Review, int, error) {
+	args := m.Called(ctx, providerID, limit, offset)
+	return args.Get(0).([]*Review), args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) GetProviderIDByUserID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockStore) ReplaceWorkingHours(ctx context.Context, providerID uuid.UUID, hours []*WorkingHours) error {
+	args := m.Called(ctx, providerID, hours)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListWorkingHours(ctx context.Context, providerID uuid.UUID) ([]*WorkingHours, error) {
+	args := m.Called(ctx, providerID)
+	return args.Get(0).([]*WorkingHours), args.Error(1)
+}
+
+func (m *MockStore) CreateBlackout(ctx context.Context, blackout *Blackout) error {
+	args := m.Called(ctx, blackout)
+	return args.Error(0)
+}
+
+func (m *MockStore) DeleteBlackout(ctx context.Context, providerID, blackoutID uuid.UUID) error {
+	args := m.Called(ctx, providerID, blackoutID)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListBlackouts(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Blackout, error) {
+	args := m.Called(ctx, providerID, from, to)
+	return args.Get(0).([]*Blackout), args.Error(1)
+}
+
+func (m *MockStore) ListActiveBookings(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Booking, error) {
+	args := m.Called(ctx, providerID, from, to)
+	return args.Get(0).([]*Booking), args.Error(1)
+}
+
+func (m *MockStore) GetProviderRate(ctx context.Context, providerID, serviceID uuid.UUID) (*ProviderRate, error) {
+	args := m.Called(ctx, providerID, serviceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ProviderRate), args.Error(1)
+}
+
+func (m *MockStore) IsBlocked(ctx context.Context, clientID, providerID uuid.UUID) (bool, error) {
+	args := m.Called(ctx, clientID, providerID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) GetService(ctx context.Context, serviceID uuid.UUID) (*Service, error) {
+	args := m.Called(ctx, serviceID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Service), args.Error(1)
+}
+
+func (m *MockStore) UpdateService(ctx context.Context, serviceID uuid.UUID, update *ServiceUpdate) error {
+	args := m.Called(ctx, serviceID, update)
+	return args.Error(0)
+}
+
+func (m *MockStore) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
+	args := m.Called(ctx, serviceID)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListAllBookings(ctx context.Context, filter *BookingFilter) ([]*Booking, int, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*Booking), args.Int(1), args.Error(2)
+}
+
+type MockEvents struct {
+	mock.Mock
+}
+
+func (m *MockEvents) PublishBookingStatusChanged(ctx context.Context, event *models.BookingStatusChangedEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
 func TestCreateBooking(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockStore := new(MockStore)
@@ -68,7 +194,13 @@ func TestCreateBooking(t *testing.T) {
 		ScheduledTime: "2023-12-25T10:00:00Z",
 	}
 
-	mockStore.On("CreateBooking", mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("GetProviderRate", mock.Anything, mock.Anything, mock.Anything).Return(&ProviderRate{HourlyRate: 5000, OffersService: true}, nil)
+	mockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	mockStore.On("ListWorkingHours", mock.Anything, mock.Anything).Return([]*WorkingHours{}, nil)
+	mockStore.On("ListBlackouts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*Blackout{}, nil)
+	mockStore.On("CreateBooking", mock.Anything, mock.MatchedBy(func(b *Booking) bool {
+		return b.DurationHours == 1.0 && b.TotalPrice == 5000
+	})).Return(nil)
 
 	body, _ := json.Marshal(req)
 	w := httptest.NewRecorder()
@@ -83,6 +215,306 @@ func TestCreateBooking(t *testing.T) {
 	assert.Equal(t, "pending", resp.Status)
 }
 
+func TestCreateBookingSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 2023-12-25 is a Monday.
+	mondayHours := []*WorkingHours{{Weekday: 1, StartTime: "09:00", EndTime: "18:00"}}
+
+	tests := []struct {
+		name      string
+		scheduled string
+		duration  float64
+		hours     []*WorkingHours
+		blackouts []*Blackout
+		storeErr  error
+		wantCode  int
+	}{
+		{"inside working hours", "2023-12-25T10:00:00Z", 2, mondayHours, nil, nil, http.StatusOK},
+		{"runs past closing", "2023-12-25T17:00:00Z", 2, mondayHours, nil, nil, http.StatusConflict},
+		{"day off", "2023-12-26T10:00:00Z", 1, mondayHours, nil, nil, http.StatusConflict},
+		{"during blackout", "2023-12-25T10:00:00Z", 1, mondayHours, []*Blackout{{
+			StartsAt: time.Date(2023, 12, 25, 9, 30, 0, 0, time.UTC),
+			EndsAt:   time.Date(2023, 12, 25, 12, 0, 0, 0, time.UTC),
+		}}, nil, http.StatusConflict},
+		{"overlapping booking", "2023-12-25T10:00:00Z", 1, mondayHours, nil, ErrBookingOverlap, http.StatusConflict},
+		{"negative duration", "2023-12-25T10:00:00Z", -1, mondayHours, nil, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			server := NewServer(mockStore)
+
+			r := gin.Default()
+			r.POST("/bookings", server.CreateBooking)
+
+			mockStore.On("GetProviderRate", mock.Anything, mock.Anything, mock.Anything).Return(&ProviderRate{HourlyRate: 5000, OffersService: true}, nil)
+			mockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+			mockStore.On("ListWorkingHours", mock.Anything, mock.Anything).Return(tt.hours, nil)
+			mockStore.On("ListBlackouts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.blackouts, nil)
+			mockStore.On("CreateBooking", mock.Anything, mock.Anything).Return(tt.storeErr)
+
+			body, _ := json.Marshal(models.CreateBookingRequest{
+				ServiceID:     uuid.New().String(),
+				UserID:        uuid.New().String(),
+				ProviderID:    uuid.New().String(),
+				ScheduledTime: tt.scheduled,
+				DurationHours: tt.duration,
+			})
+			w := httptest.NewRecorder()
+			httpReq, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(body))
+			r.ServeHTTP(w, httpReq)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestCreateBookingPrice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		rate      *ProviderRate
+		duration  float64
+		wantPrice float64
+	}{
+		{"provider hourly rate", &ProviderRate{HourlyRate: 4000, OffersService: true}, 2.5, 10000},
+		{"service override", &ProviderRate{HourlyRate: 4000, ServiceRate: sql.NullFloat64{Float64: 6500.5, Valid: true}, OffersService: true}, 1.5, 9750.75},
+		{"free override", &ProviderRate{HourlyRate: 4000, ServiceRate: sql.NullFloat64{Float64: 0, Valid: true}, OffersService: true}, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			server := NewServer(mockStore)
+
+			r := gin.Default()
+			r.POST("/bookings", server.CreateBooking)
+
+			mockStore.On("GetProviderRate", mock.Anything, mock.Anything, mock.Anything).Return(tt.rate, nil)
+			mockStore.On("IsBlocked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+			mockStore.On("ListWorkingHours", mock.Anything, mock.Anything).Return([]*WorkingHours{}, nil)
+			mockStore.On("ListBlackouts", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]*Blackout{}, nil)
+			mockStore.On("CreateBooking", mock.Anything, mock.MatchedBy(func(b *Booking) bool {
+				return b.TotalPrice == tt.wantPrice && b.HourlyRate == tt.rate.Rate() && b.Currency == "KZT"
+			})).Return(nil)
+
+			body, _ := json.Marshal(models.CreateBookingRequest{
+				ServiceID:     uuid.New().String(),
+				UserID:        uuid.New().String(),
+				ProviderID:    uuid.New().String(),
+				ScheduledTime: "2023-12-25T10:00:00Z",
+				DurationHours: tt.duration,
+			})
+			w := httptest.NewRecorder()
+			httpReq, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(body))
+			r.ServeHTTP(w, httpReq)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var resp models.BookingResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.wantPrice, resp.TotalPrice)
+		})
+	}
+}
+
+func TestCreateBookingServiceNotOffered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.POST("/bookings", server.CreateBooking)
+
+	mockStore.On("GetProviderRate", mock.Anything, mock.Anything, mock.Anything).Return(&ProviderRate{HourlyRate: 5000}, nil)
+
+	body, _ := json.Marshal(models.CreateBookingRequest{
+		ServiceID:     uuid.New().String(),
+		UserID:        uuid.New().String(),
+		ProviderID:    uuid.New().String(),
+		ScheduledTime: "2023-12-25T10:00:00Z",
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStore.AssertNotCalled(t, "CreateBooking", mock.Anything, mock.Anything)
+}
+
+func TestCreateBookingBlocked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.POST("/bookings", server.CreateBooking)
+
+	clientID := uuid.New()
+	providerID := uuid.New()
+	mockStore.On("GetProviderRate", mock.Anything, providerID, mock.Anything).Return(&ProviderRate{HourlyRate: 5000, OffersService: true}, nil)
+	mockStore.On("IsBlocked", mock.Anything, clientID, providerID).Return(true, nil)
+
+	body, _ := json.Marshal(models.CreateBookingRequest{
+		ServiceID:     uuid.New().String(),
+		UserID:        clientID.String(),
+		ProviderID:    providerID.String(),
+		ScheduledTime: "2023-12-25T10:00:00Z",
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/bookings", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStore.AssertNotCalled(t, "CreateBooking", mock.Anything, mock.Anything)
+}
+
+func TestCreateService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.POST("/services", server.CreateService)
+
+	userID := uuid.New()
+	providerID := uuid.New()
+	mockStore.On("GetProviderIDByUserID", mock.Anything, userID).Return(providerID, nil)
+	mockStore.On("CreateService", mock.Anything, mock.MatchedBy(func(svc *Service) bool {
+		return svc.Category == "repair"
+	}), mock.MatchedBy(func(ps *ProviderService) bool {
+		return ps.ProviderID == providerID && ps.Price.Valid && ps.Price.Float64 == 7000
+	})).Return(nil)
+
+	body, _ := json.Marshal(models.CreateServiceRequest{
+		UserID:   userID.String(),
+		Title:    "Boiler repair",
+		Price:    7000,
+		Category: "Repair",
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/services", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ServiceResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, providerID.String(), resp.ProviderID)
+	assert.Equal(t, 7000.0, resp.Price)
+}
+
+func TestCreateServiceRequiresProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.POST("/services", server.CreateService)
+
+	userID := uuid.New()
+	mockStore.On("GetProviderIDByUserID", mock.Anything, userID).Return(uuid.Nil, ErrNotProvider)
+
+	body, _ := json.Marshal(models.CreateServiceRequest{UserID: userID.String(), Title: "Boiler repair"})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/services", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCreateServiceRejectsUnknownCategory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.POST("/services", server.CreateService)
+
+	body, _ := json.Marshal(models.CreateServiceRequest{UserID: uuid.New().String(), Title: "Boiler repair", Category: "misc"})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/services", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStore.AssertNotCalled(t, "CreateService", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNewServiceUpdateCategory(t *testing.T) {
+	category := " Cleaning "
+	update, err := newServiceUpdate(&models.UpdateServiceRequest{Category: &category})
+	assert.NoError(t, err)
+	assert.Equal(t, "cleaning", *update.Category)
+
+	empty := ""
+	update, err = newServiceUpdate(&models.UpdateServiceRequest{Category: &empty})
+	assert.NoError(t, err)
+	assert.Equal(t, "", *update.Category)
+
+	unknown := "groceries"
+	_, err = newServiceUpdate(&models.UpdateServiceRequest{Category: &unknown})
+	assert.ErrorIs(t, err, ErrInvalidServiceEdit)
+}
+
+func TestNewServiceUpdateIcon(t *testing.T) {
+	for _, icon := range []string{"fas fa-broom", "fa fa-truck-moving", "https://cdn.example.com/plumbing.svg"} {
+		update, err := newServiceUpdate(&models.UpdateServiceRequest{IconURL: &icon})
+		assert.NoError(t, err, icon)
+		assert.Equal(t, icon, *update.IconURL)
+	}
+
+	for _, icon := range []string{"javascript:alert(1)", "broom", "fas fa-broom\" onload=\"x", "ftp://cdn.example.com/a.svg"} {
+		_, err := newServiceUpdate(&models.UpdateServiceRequest{IconURL: &icon})
+		assert.ErrorIs(t, err, ErrInvalidServiceEdit, icon)
+	}
+}
+
+func TestGetServicesByCategory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.GET("/services", server.GetServices)
+
+	mockStore.On("ListServices", mock.Anything, "cleaning").Return([]*Service{
+		{ID: uuid.New(), Name: "Home Cleaning", Category: "cleaning"},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/services?category=Cleaning", nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.GetServicesResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Services, 1)
+	assert.Equal(t, "cleaning", resp.Services[0].Category)
+}
+
+func TestFreeSlots(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2023, 12, 25, h, m, 0, 0, time.UTC) }
+
+	hours := []*WorkingHours{
+		{Weekday: 1, StartTime: "09:00", EndTime: "13:00"},
+		{Weekday: 1, StartTime: "14:00", EndTime: "18:00"},
+	}
+	blackouts := []*Blackout{{StartsAt: day(16, 0), EndsAt: day(20, 0)}}
+	bookings := []*Booking{{ScheduledDate: day(10, 0), DurationHours: 1.5}}
+
+	slots := freeSlots(hours, blackouts, bookings, day(0, 0), day(0, 0).AddDate(0, 0, 1))
+
+	assert.Equal(t, []timeRange{
+		{Start: day(9, 0), End: day(10, 0)},
+		{Start: day(11, 30), End: day(13, 0)},
+		{Start: day(14, 0), End: day(16, 0)},
+	}, slots)
+}
+
 func TestUpdateBookingStatus(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockStore := new(MockStore)
@@ -92,18 +524,29 @@ func TestUpdateBookingStatus(t *testing.T) {
 	r.PUT("/bookings/:id/status", server.UpdateBookingStatus)
 
 	bookingID := uuid.New().String()
+	clientID := uuid.New()
+	providerUserID := uuid.New()
 	req := models.UpdateBookingStatusRequest{
 		BookingID: bookingID,
 		Status:    "accepted",
-		UserID:    "provider-user-1",
+		UserID:    providerUserID.String(),
 	}
 
 	mockStore.On("GetBooking", mock.Anything, bookingID).Return(&Booking{
-		ID:         uuid.MustParse(bookingID),
-		ProviderID: uuid.New(),
+		ID:             uuid.MustParse(bookingID),
+		ClientID:       clientID,
+		ProviderID:     uuid.New(),
+		ProviderUserID: providerUserID,
+		Status:         "pending",
 	}, nil)
 
-	mockStore.On("UpdateBookingStatus", mock.Anything, bookingID, "accepted").Return(nil)
+	mockStore.On("UpdateBookingStatus", mock.Anything, uuid.MustParse(bookingID), "pending", "accepted", providerUserID, mock.MatchedBy(func(e *models.BookingStatusChangedEvent) bool {
+		return e.BookingID == bookingID &&
+			e.ClientID == clientID.String() &&
+			e.ProviderUserID == providerUserID.String() &&
+			e.From == "pending" && e.To == "accepted" &&
+			e.ChangedBy == providerUserID.String()
+	})).Return(nil)
 
 	body, _ := json.Marshal(req)
 	w := httptest.NewRecorder()
@@ -111,4 +554,295 @@ func TestUpdateBookingStatus(t *testing.T) {
 	r.ServeHTTP(w, httpReq)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	mockStore.AssertExpectations(t)
+}
+
+func TestRelayOutbox(t *testing.T) {
+	mockStore := new(MockStore)
+	mockEvents := new(MockEvents)
+
+	first := &models.BookingStatusChangedEvent{BookingID: uuid.NewString(), From: "pending", To: "accepted"}
+	second := &models.BookingStatusChangedEvent{BookingID: first.BookingID, From: "accepted", To: "completed"}
+	payload := func(e *models.BookingStatusChangedEvent) []byte {
+		body, _ := json.Marshal(e)
+		return body
+	}
+
+	mockStore.On("PublishOutbox", mock.Anything, outboxBatchSize).Return([]*OutboxEvent{
+		{ID: uuid.New(), RoutingKey: models.BookingStatusChangedKey, Payload: payload(first)},
+		{ID: uuid.New(), RoutingKey: "unknown.key", Payload: []byte(`{}`)},
+		{ID: uuid.New(), RoutingKey: models.BookingStatusChangedKey, Payload: []byte(`not json`)},
+		{ID: uuid.New(), RoutingKey: models.BookingStatusChangedKey, Payload: payload(second)},
+	}, nil).Once()
+	mockEvents.On("PublishBookingStatusChanged", mock.Anything, first).Return(nil).Once()
+	mockEvents.On("PublishBookingStatusChanged", mock.Anything, second).Return(nil).Once()
+
+	published, err := relayOutbox(context.Background(), mockStore, mockEvents)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	mockEvents.AssertExpectations(t)
+
+	// A broker failure stops the batch so later events wait their turn.
+	mockStore.On("PublishOutbox", mock.Anything, outboxBatchSize).Return([]*OutboxEvent{
+		{ID: uuid.New(), RoutingKey: models.BookingStatusChangedKey, Payload: payload(first)},
+		{ID: uuid.New(), RoutingKey: models.BookingStatusChangedKey, Payload: payload(second)},
+	}, nil).Once()
+	mockEvents.On("PublishBookingStatusChanged", mock.Anything, first).Return(ErrPublishNotConfirmed).Once()
+
+	published, err = relayOutbox(context.Background(), mockStore, mockEvents)
+	assert.ErrorIs(t, err, ErrPublishNotConfirmed)
+	assert.Equal(t, 0, published)
+	mockEvents.AssertNumberOfCalls(t, "PublishBookingStatusChanged", 3)
+}
+
+func TestUpdateBookingStatusRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	clientID := uuid.New()
+	providerUserID := uuid.New()
+
+	tests := []struct {
+		name     string
+		current  string
+		next     string
+		userID   uuid.UUID
+		wantCode int
+	}{
+		{"provider cannot cancel", "pending", "cancelled", providerUserID, http.StatusForbidden},
+		{"client cannot accept", "pending", "accepted", clientID, http.StatusForbidden},
+		{"stranger cannot touch booking", "pending", "accepted", uuid.New(), http.StatusForbidden},
+		{"completed is final", "completed", "cancelled", clientID, http.StatusConflict},
+		{"pending cannot complete", "pending", "completed", providerUserID, http.StatusConflict},
+		{"rejected cannot be accepted", "rejected", "accepted", providerUserID, http.StatusConflict},
+		{"unknown status", "pending", "archived", providerUserID, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			server := NewServer(mockStore)
+
+			r := gin.Default()
+			r.PUT("/bookings/:id/status", server.UpdateBookingStatus)
+
+			bookingID := uuid.New()
+			mockStore.On("GetBooking", mock.Anything, bookingID.String()).Return(&Booking{
+				ID:             bookingID,
+				ClientID:       clientID,
+				ProviderUserID: providerUserID,
+				Status:         tt.current,
+			}, nil)
+
+			body, _ := json.Marshal(models.UpdateBookingStatusRequest{
+				Status: tt.next,
+				UserID: tt.userID.String(),
+			})
+			w := httptest.NewRecorder()
+			httpReq, _ := http.NewRequest("PUT", "/bookings/"+bookingID.String()+"/status", bytes.NewBuffer(body))
+			r.ServeHTTP(w, httpReq)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			mockStore.AssertNotCalled(t, "UpdateBookingStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUpdateBookingStatusConcurrentChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.PUT("/bookings/:id/status", server.UpdateBookingStatus)
+
+	bookingID := uuid.New()
+	clientID := uuid.New()
+	mockStore.On("GetBooking", mock.Anything, bookingID.String()).Return(&Booking{
+		ID:             bookingID,
+		ClientID:       clientID,
+		ProviderUserID: uuid.New(),
+		Status:         "pending",
+	}, nil)
+	mockStore.On("UpdateBookingStatus", mock.Anything, bookingID, "pending", "cancelled", clientID, mock.Anything).Return(ErrStatusChanged)
+
+	body, _ := json.Marshal(models.UpdateBookingStatusRequest{
+		Status: "cancelled",
+		UserID: clientID.String(),
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("PUT", "/bookings/"+bookingID.String()+"/status", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var resp models.TransitionErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "status_changed", resp.Code)
+}
+
+func TestCreateReview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	clientID := uuid.New()
+	providerID := uuid.New()
+
+	tests := []struct {
+		name     string
+		status   string
+		userID   uuid.UUID
+		rating   int
+		storeErr error
+		wantCode int
+	}{
+		{"completed booking", "completed", clientID, 5, nil, http.StatusOK},
+		{"already reviewed", "completed", clientID, 4, ErrReviewExists, http.StatusConflict},
+		{"booking not completed", "accepted", clientID, 5, nil, http.StatusConflict},
+		{"not the client", "completed", uuid.New(), 5, nil, http.StatusForbidden},
+		{"rating out of range", "completed", clientID, 6, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := new(MockStore)
+			server := NewServer(mockStore)
+
+			r := gin.Default()
+			r.POST("/bookings/:id/review", server.CreateReview)
+
+			bookingID := uuid.New()
+			mockStore.On("GetBooking", mock.Anything, bookingID.String()).Return(&Booking{
+				ID:         bookingID,
+				ClientID:   clientID,
+				ProviderID: providerID,
+				Status:     tt.status,
+			}, nil)
+			mockStore.On("CreateReview", mock.Anything, mock.MatchedBy(func(r *Review) bool {
+				return r.ProviderID == providerID && r.BookingID == bookingID
+			})).Return(tt.storeErr)
+
+			body, _ := json.Marshal(models.CreateReviewRequest{
+				UserID:  tt.userID.String(),
+				Rating:  tt.rating,
+				Comment: "Fixed the sink quickly",
+			})
+			w := httptest.NewRecorder()
+			httpReq, _ := http.NewRequest("POST", "/bookings/"+bookingID.String()+"/review", bytes.NewBuffer(body))
+			r.ServeHTTP(w, httpReq)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestListReviews(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.GET("/providers/:id/reviews", server.ListReviews)
+
+	providerID := uuid.New()
+	mockStore.On("ListReviews", mock.Anything, providerID, 2, 4).Return([]*Review{
+		{ID: uuid.New(), ProviderID: providerID, Rating: 5, ClientName: "Aruzhan"},
+	}, 5, nil)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/providers/"+providerID.String()+"/reviews?limit=2&offset=4", nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ListReviewsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 5, resp.Total)
+	assert.Len(t, resp.Reviews, 1)
+	assert.Equal(t, "Aruzhan", resp.Reviews[0].ClientName)
+}
+
+func TestUpdateService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.PUT("/admin/services/:id", server.UpdateService)
+
+	serviceID := uuid.New()
+	mockStore.On("UpdateService", mock.Anything, serviceID, mock.MatchedBy(func(u *ServiceUpdate) bool {
+		return u.Name != nil && *u.Name == "Plumbing" &&
+			u.IconURL != nil && *u.IconURL == "https://cdn.example.com/plumbing.svg" &&
+			u.Description == nil && u.Category == nil
+	})).Return(nil)
+	mockStore.On("GetService", mock.Anything, serviceID).Return(&Service{
+		ID: serviceID, Name: "Plumbing", IconURL: "https://cdn.example.com/plumbing.svg",
+	}, nil)
+
+	body, _ := json.Marshal(map[string]string{"title": " Plumbing ", "icon_url": "https://cdn.example.com/plumbing.svg"})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("PUT", "/admin/services/"+serviceID.String(), bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ServiceResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "https://cdn.example.com/plumbing.svg", resp.IconURL)
+
+	for _, bad := range []map[string]string{{"title": "  "}, {"icon_url": "javascript:alert(1)"}} {
+		body, _ := json.Marshal(bad)
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("PUT", "/admin/services/"+serviceID.String(), bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, http.StatusBadRequest, w.Code, string(body))
+	}
+	mockStore.AssertNumberOfCalls(t, "UpdateService", 1)
+}
+
+func TestDeleteService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore)
+
+	r := gin.Default()
+	r.DELETE("/admin/services/:id", server.DeleteService)
+
+	unusedID := uuid.New()
+	bookedID := uuid.New()
+	mockStore.On("DeleteService", mock.Anything, unusedID).Return(nil)
+	mockStore.On("DeleteService", mock.Anything, bookedID).Return(ErrServiceInUse)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("DELETE", "/admin/services/"+unusedID.String(), nil)
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("DELETE", "/admin/services/"+bookedID.String(), nil)
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestBookingFilterBuild(t *testing.T) {
+	clientID := uuid.New()
+	f, err := newBookingFilter(&models.AdminListBookingsRequest{
+		Status:   StatusPending,
+		ClientID: clientID.String(),
+		From:     "2024-05-01T00:00:00+05:00",
+		Limit:    500,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAdminPageSize, f.Limit)
+
+	where, args := f.build()
+	assert.Equal(t, "TRUE AND b.status = $1 AND b.client_id = $2 AND b.scheduled_date >= $3", where)
+	assert.Equal(t, []interface{}{StatusPending, clientID, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}, args)
+
+	for _, bad := range []*models.AdminListBookingsRequest{
+		{Status: "archived"},
+		{ProviderID: "nope"},
+		{From: "2024-05-02T00:00:00Z", To: "2024-05-01T00:00:00Z"},
+	} {
+		_, err := newBookingFilter(bad)
+		assert.ErrorIs(t, err, ErrInvalidBookingList)
+	}
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusRejected  = "rejected"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	actorClient   = "client"
+	actorProvider = "provider"
+)
+
+var (
+	ErrUnknownStatus = errors.New("unknown booking status")
+	ErrNotParty      = errors.New("user is not a party to this booking")
+	ErrStatusChanged = errors.New("booking status was changed concurrently")
+)
+
+// bookingTransitions maps a current status to the statuses it may move to,
+// along with the only party allowed to perform that move.
+var bookingTransitions = map[string]map[string]string{
+	StatusPending: {
+		StatusAccepted:  actorProvider,
+		StatusRejected:  actorProvider,
+		StatusCancelled: actorClient,
+	},
+	StatusAccepted: {
+		StatusCompleted: actorProvider,
+		StatusCancelled: actorClient,
+	},
+}
+
+// TransitionError is returned for a status change the state machine does not
+// permit. AllowedActor is set when the move itself is legal but the caller is
+// the wrong party to perform it.
+type TransitionError struct {
+	From         string
+	To           string
+	AllowedActor string
+}
+
+func (e *TransitionError) Error() string {
+	if e.AllowedActor != "" {
+		return fmt.Sprintf("only the %s can change booking status from %s to %s", e.AllowedActor, e.From, e.To)
+	}
+	return fmt.Sprintf("cannot change booking status from %s to %s", e.From, e.To)
+}
+
+func isValidStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusAccepted, StatusRejected, StatusCompleted, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+func bookingActor(b *Booking, userID uuid.UUID) (string, error) {
+	switch userID {
+	case b.ClientID:
+		return actorClient, nil
+	case b.ProviderUserID:
+		return actorProvider, nil
+	}
+	return "", ErrNotParty
+}
+
+func checkTransition(b *Booking, to string, userID uuid.UUID) error {
+	if !isValidStatus(to) {
+		return ErrUnknownStatus
+	}
+
+	actor, err := bookingActor(b, userID)
+	if err != nil {
+		return err
+	}
+
+	allowed, ok := bookingTransitions[b.Status][to]
+	if !ok {
+		return &TransitionError{From: b.Status, To: to}
+	}
+	if allowed != actor {
+		return &TransitionError{From: b.Status, To: to, AllowedActor: allowed}
+	}
+
+	return nil
+}
@@ -2,17 +2,68 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReviewExists      = errors.New("booking has already been reviewed")
+	ErrProviderNotFound  = errors.New("provider not found")
+	ErrServiceNotFound   = errors.New("service not found")
+	ErrServiceNotOffered = errors.New("provider does not offer this service")
+	ErrUserBlocked       = errors.New("you cannot book this provider")
 )
 
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func isExclusionViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23P01"
+}
+
 type IStore interface {
-	CreateService(ctx context.Context, service *Service) error
-	ListServices(ctx context.Context) ([]*Service, error)
+	CreateService(ctx context.Context, service *Service, offering *ProviderService) error
+	ListServices(ctx context.Context, category string) ([]*Service, error)
+	UpsertProviderService(ctx context.Context, offering *ProviderService) error
+	DeleteProviderService(ctx context.Context, providerID, serviceID uuid.UUID) error
+	ListServiceProviders(ctx context.Context, serviceID uuid.UUID) ([]*ServiceOffering, error)
 	CreateBooking(ctx context.Context, booking *Booking) error
 	ListBookings(ctx context.Context, userID string, role string) ([]*Booking, error)
-	UpdateBookingStatus(ctx context.Context, bookingID string, status string) error
+	UpdateBookingStatus(ctx context.Context, bookingID uuid.UUID, from, to string, changedBy uuid.UUID, event *models.BookingStatusChangedEvent) error
+	PublishOutbox(ctx context.Context, limit int, publish func(*OutboxEvent) error) (int, error)
 	GetBooking(ctx context.Context, bookingID string) (*Booking, error)
+	CreateReview(ctx context.Context, review *Review) error
+	ListReviews(ctx context.Context, providerID uuid.UUID, limit, offset int) ([]*Review, int, error)
+	GetProviderIDByUserID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error)
+	ReplaceWorkingHours(ctx context.Context, providerID uuid.UUID, hours []*WorkingHours) error
+	ListWorkingHours(ctx context.Context, providerID uuid.UUID) ([]*WorkingHours, error)
+	CreateBlackout(ctx context.Context, blackout *Blackout) error
+	DeleteBlackout(ctx context.Context, providerID, blackoutID uuid.UUID) error
+	ListBlackouts(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Blackout, error)
+	ListActiveBookings(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Booking, error)
+	GetProviderRate(ctx context.Context, providerID, serviceID uuid.UUID) (*ProviderRate, error)
+	IsBlocked(ctx context.Context, clientID, providerID uuid.UUID) (bool, error)
+	GetService(ctx context.Context, serviceID uuid.UUID) (*Service, error)
+	UpdateService(ctx context.Context, serviceID uuid.UUID, update *ServiceUpdate) error
+	DeleteService(ctx context.Context, serviceID uuid.UUID) error
+	ListAllBookings(ctx context.Context, filter *BookingFilter) ([]*Booking, int, error)
 }
 
 type Store struct {
@@ -23,28 +74,166 @@ func NewStore(db *sqlx.DB) *Store {
 	return &Store{db: db}
 }
 
-func (s *Store) CreateService(ctx context.Context, service *Service) error {
+func (s *Store) CreateService(ctx context.Context, service *Service, offering *ProviderService) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO services (id, name, description, icon_url, created_at, updated_at)
-		VALUES (:id, :name, :description, :icon_url, :created_at, :updated_at)
+		INSERT INTO services (id, name, description, icon_url, category, created_at, updated_at)
+		VALUES (:id, :name, :description, :icon_url, :category, :created_at, :updated_at)
 	`
-	_, err := s.db.NamedExecContext(ctx, query, service)
-	return err
+	_, err = tx.NamedExecContext(ctx, query, service)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.NamedExecContext(ctx, insertProviderServiceQuery, offering)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *Store) ListServices(ctx context.Context) ([]*Service, error) {
+func (s *Store) ListServices(ctx context.Context, category string) ([]*Service, error) {
 	var services []*Service
-	query := `SELECT * FROM services ORDER BY name`
-	err := s.db.SelectContext(ctx, &services, query)
+	query := `SELECT * FROM services WHERE ($1 = '' OR category = $1) ORDER BY name`
+	err := s.db.SelectContext(ctx, &services, query, category)
 	return services, err
 }
 
+func (s *Store) GetService(ctx context.Context, serviceID uuid.UUID) (*Service, error) {
+	var service Service
+	query := `
+		SELECT id, name, COALESCE(description, '') AS description, COALESCE(icon_url, '') AS icon_url,
+		       category, created_at, updated_at
+		FROM services WHERE id = $1
+	`
+	err := s.db.GetContext(ctx, &service, query, serviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrServiceNotFound
+		}
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (s *Store) UpdateService(ctx context.Context, serviceID uuid.UUID, update *ServiceUpdate) error {
+	query := `
+		UPDATE services
+		SET name = COALESCE($2, name),
+		    description = COALESCE($3, description),
+		    icon_url = COALESCE($4, icon_url),
+		    category = COALESCE($5, category)
+		WHERE id = $1
+	`
+	res, err := s.db.ExecContext(ctx, query, serviceID, update.Name, update.Description, update.IconURL, update.Category)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrServiceNotFound
+	}
+	return nil
+}
+
+// DeleteService removes a catalog entry nobody has booked. Bookings cascade
+// from services, so a booked service is refused rather than taking its
+// booking history with it.
+func (s *Store) DeleteService(ctx context.Context, serviceID uuid.UUID) error {
+	query := `
+		DELETE FROM services
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM bookings WHERE service_id = $1)
+	`
+	res, err := s.db.ExecContext(ctx, query, serviceID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := s.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM services WHERE id = $1)`, serviceID); err != nil {
+		return err
+	}
+	if exists {
+		return ErrServiceInUse
+	}
+	return ErrServiceNotFound
+}
+
+const insertProviderServiceQuery = `
+	INSERT INTO provider_services (provider_id, service_id, title, description, price, created_at, updated_at)
+	VALUES (:provider_id, :service_id, :title, :description, :price, :created_at, :updated_at)
+	ON CONFLICT (provider_id, service_id) DO UPDATE
+	SET title = EXCLUDED.title, description = EXCLUDED.description, price = EXCLUDED.price
+`
+
+func (s *Store) UpsertProviderService(ctx context.Context, offering *ProviderService) error {
+	_, err := s.db.NamedExecContext(ctx, insertProviderServiceQuery, offering)
+	if isForeignKeyViolation(err) {
+		return ErrServiceNotFound
+	}
+	return err
+}
+
+func (s *Store) DeleteProviderService(ctx context.Context, providerID, serviceID uuid.UUID) error {
+	query := `DELETE FROM provider_services WHERE provider_id = $1 AND service_id = $2`
+	res, err := s.db.ExecContext(ctx, query, providerID, serviceID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrServiceNotOffered
+	}
+	return nil
+}
+
+func (s *Store) ListServiceProviders(ctx context.Context, serviceID uuid.UUID) ([]*ServiceOffering, error) {
+	var offerings []*ServiceOffering
+	query := `
+		SELECT sp.id AS provider_id, u.id AS user_id, u.full_name,
+		       ps.title, ps.description,
+		       COALESCE(ps.price, sp.hourly_rate) AS price,
+		       COALESCE(sp.location, '') AS location,
+		       sp.rating, sp.total_reviews, sp.is_available
+		FROM provider_services ps
+		INNER JOIN service_providers sp ON ps.provider_id = sp.id
+		INNER JOIN users u ON sp.user_id = u.id
+		WHERE ps.service_id = $1
+		ORDER BY sp.rating DESC, sp.total_reviews DESC, u.full_name
+	`
+	err := s.db.SelectContext(ctx, &offerings, query, serviceID)
+	return offerings, err
+}
+
 func (s *Store) CreateBooking(ctx context.Context, booking *Booking) error {
 	query := `
-		INSERT INTO bookings (id, client_id, provider_id, service_id, scheduled_date, duration_hours, status, total_price, notes, created_at, updated_at)
-		VALUES (:id, :client_id, :provider_id, :service_id, :scheduled_date, :duration_hours, :status, :total_price, :notes, :created_at, :updated_at)
+		INSERT INTO bookings (id, client_id, provider_id, service_id, scheduled_date, duration_hours, status, total_price, hourly_rate, currency, notes, created_at, updated_at)
+		VALUES (:id, :client_id, :provider_id, :service_id, :scheduled_date, :duration_hours, :status, :total_price, :hourly_rate, :currency, :notes, :created_at, :updated_at)
 	`
 	_, err := s.db.NamedExecContext(ctx, query, booking)
+	if isExclusionViolation(err) {
+		return ErrBookingOverlap
+	}
 	return err
 }
 
@@ -67,18 +256,345 @@ func (s *Store) ListBookings(ctx context.Context, userID string, role string) ([
 	return bookings, err
 }
 
-func (s *Store) UpdateBookingStatus(ctx context.Context, bookingID string, status string) error {
-	query := `UPDATE bookings SET status = $1, updated_at = NOW() WHERE id = $2`
-	_, err := s.db.ExecContext(ctx, query, status, bookingID)
+// UpdateBookingStatus moves the booking from one status to another, records
+// the change and queues event in the outbox, all in one transaction.
+func (s *Store) UpdateBookingStatus(ctx context.Context, bookingID uuid.UUID, from, to string, changedBy uuid.UUID, event *models.BookingStatusChangedEvent) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE bookings SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`
+	res, err := tx.ExecContext(ctx, query, to, bookingID, from)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return ErrStatusChanged
+	}
+
+	historyQuery := `
+		INSERT INTO booking_status_history (booking_id, from_status, to_status, changed_by)
+		VALUES ($1, $2, $3, $4)
+	`
+	_, err = tx.ExecContext(ctx, historyQuery, bookingID, from, to, changedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := insertOutboxEvent(ctx, tx, models.BookingStatusChangedKey, event); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sqlx.Tx, routingKey string, event any) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO event_outbox (id, routing_key, payload) VALUES ($1, $2, $3)`
+	_, err = tx.ExecContext(ctx, query, uuid.New(), routingKey, payload)
 	return err
 }
 
+// PublishOutbox hands up to limit pending events to publish, oldest first,
+// and marks the ones it accepted as published. It stops at the first event
+// publish fails on, so later events never overtake it, unless the event
+// itself is unusable (ErrInvalidOutboxEvent), which is set aside instead.
+// Rows stay locked while publishing, so concurrent relays take turns.
+func (s *Store) PublishOutbox(ctx context.Context, limit int, publish func(*OutboxEvent) error) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var events []*OutboxEvent
+	query := `
+		SELECT * FROM event_outbox
+		WHERE published_at IS NULL AND failed_at IS NULL
+		ORDER BY created_at, id
+		LIMIT $1
+		FOR UPDATE
+	`
+	if err := tx.SelectContext(ctx, &events, query, limit); err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		err := publish(event)
+		switch {
+		case err == nil:
+			if _, err := tx.ExecContext(ctx, `UPDATE event_outbox SET published_at = NOW(), last_error = NULL WHERE id = $1`, event.ID); err != nil {
+				return 0, err
+			}
+			published++
+		case errors.Is(err, ErrInvalidOutboxEvent):
+			if _, err := tx.ExecContext(ctx, `UPDATE event_outbox SET failed_at = NOW(), last_error = $2 WHERE id = $1`, event.ID, err.Error()); err != nil {
+				return 0, err
+			}
+		default:
+			if _, err := tx.ExecContext(ctx, `UPDATE event_outbox SET last_error = $2 WHERE id = $1`, event.ID, err.Error()); err != nil {
+				return 0, err
+			}
+			if err := tx.Commit(); err != nil {
+				return 0, err
+			}
+			return published, err
+		}
+	}
+	return published, tx.Commit()
+}
+
 func (s *Store) GetBooking(ctx context.Context, bookingID string) (*Booking, error) {
 	var booking Booking
-	query := `SELECT * FROM bookings WHERE id = $1`
+	query := `
+		SELECT b.*, sp.user_id AS provider_user_id
+		FROM bookings b
+		INNER JOIN service_providers sp ON b.provider_id = sp.id
+		WHERE b.id = $1
+	`
 	err := s.db.GetContext(ctx, &booking, query, bookingID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &booking, nil
 }
+
+func (s *Store) CreateReview(ctx context.Context, review *Review) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO reviews (id, booking_id, client_id, provider_id, rating, comment, created_at)
+		VALUES (:id, :booking_id, :client_id, :provider_id, :rating, :comment, :created_at)
+	`
+	_, err = tx.NamedExecContext(ctx, query, review)
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err) {
+			return ErrReviewExists
+		}
+		return err
+	}
+
+	// Lock the provider first so the aggregate below, which runs with a
+	// fresh snapshot, also sees reviews committed by concurrent writers.
+	lockQuery := `SELECT id FROM service_providers WHERE id = $1 FOR UPDATE`
+	if _, err := tx.ExecContext(ctx, lockQuery, review.ProviderID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	ratingQuery := `
+		UPDATE service_providers sp
+		SET rating = r.average, total_reviews = r.total
+		FROM (
+			SELECT COALESCE(ROUND(AVG(rating), 2), 0) AS average, COUNT(*) AS total
+			FROM reviews
+			WHERE provider_id = $1
+		) r
+		WHERE sp.id = $1
+	`
+	_, err = tx.ExecContext(ctx, ratingQuery, review.ProviderID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ListReviews(ctx context.Context, providerID uuid.UUID, limit, offset int) ([]*Review, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM reviews WHERE provider_id = $1`
+	if err := s.db.GetContext(ctx, &total, countQuery, providerID); err != nil {
+		return nil, 0, err
+	}
+
+	var reviews []*Review
+	query := `
+		SELECT r.*, u.full_name AS client_name
+		FROM reviews r
+		INNER JOIN users u ON r.client_id = u.id
+		WHERE r.provider_id = $1
+		ORDER BY r.created_at DESC, r.id
+		LIMIT $2 OFFSET $3
+	`
+	err := s.db.SelectContext(ctx, &reviews, query, providerID, limit, offset)
+	return reviews, total, err
+}
+
+func (s *Store) GetProviderIDByUserID(ctx context.Context, userID uuid.UUID) (uuid.UUID, error) {
+	var providerID uuid.UUID
+	query := `SELECT id FROM service_providers WHERE user_id = $1`
+	err := s.db.GetContext(ctx, &providerID, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrNotProvider
+	}
+	return providerID, err
+}
+
+func (s *Store) ReplaceWorkingHours(ctx context.Context, providerID uuid.UUID, hours []*WorkingHours) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM provider_working_hours WHERE provider_id = $1`, providerID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := `
+		INSERT INTO provider_working_hours (id, provider_id, weekday, start_time, end_time)
+		VALUES (:id, :provider_id, :weekday, :start_time, :end_time)
+	`
+	for _, wh := range hours {
+		if _, err := tx.NamedExecContext(ctx, query, wh); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ListWorkingHours(ctx context.Context, providerID uuid.UUID) ([]*WorkingHours, error) {
+	var hours []*WorkingHours
+	query := `
+		SELECT id, provider_id, weekday,
+		       to_char(start_time, 'HH24:MI') AS start_time,
+		       to_char(end_time, 'HH24:MI') AS end_time
+		FROM provider_working_hours
+		WHERE provider_id = $1
+		ORDER BY weekday, start_time
+	`
+	err := s.db.SelectContext(ctx, &hours, query, providerID)
+	return hours, err
+}
+
+func (s *Store) CreateBlackout(ctx context.Context, blackout *Blackout) error {
+	query := `
+		INSERT INTO provider_blackouts (id, provider_id, starts_at, ends_at, reason, created_at)
+		VALUES (:id, :provider_id, :starts_at, :ends_at, :reason, :created_at)
+	`
+	_, err := s.db.NamedExecContext(ctx, query, blackout)
+	return err
+}
+
+func (s *Store) DeleteBlackout(ctx context.Context, providerID, blackoutID uuid.UUID) error {
+	query := `DELETE FROM provider_blackouts WHERE id = $1 AND provider_id = $2`
+	res, err := s.db.ExecContext(ctx, query, blackoutID, providerID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrBlackoutNotFound
+	}
+	return nil
+}
+
+func (s *Store) ListBlackouts(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Blackout, error) {
+	var blackouts []*Blackout
+	query := `
+		SELECT * FROM provider_blackouts
+		WHERE provider_id = $1 AND starts_at < $3 AND ends_at > $2
+		ORDER BY starts_at
+	`
+	err := s.db.SelectContext(ctx, &blackouts, query, providerID, from, to)
+	return blackouts, err
+}
+
+func (s *Store) ListActiveBookings(ctx context.Context, providerID uuid.UUID, from, to time.Time) ([]*Booking, error) {
+	var bookings []*Booking
+	query := `
+		SELECT * FROM bookings
+		WHERE provider_id = $1
+		  AND status IN ('pending', 'accepted')
+		  AND scheduled_date < $3
+		  AND scheduled_date + duration_hours * INTERVAL '1 hour' > $2
+		ORDER BY scheduled_date
+	`
+	err := s.db.SelectContext(ctx, &bookings, query, providerID, from, to)
+	return bookings, err
+}
+
+func (s *Store) GetProviderRate(ctx context.Context, providerID, serviceID uuid.UUID) (*ProviderRate, error) {
+	var rate ProviderRate
+	query := `
+		SELECT sp.hourly_rate, ps.price AS service_rate,
+		       ps.provider_id IS NOT NULL AS offers_service
+		FROM service_providers sp
+		LEFT JOIN provider_services ps ON ps.provider_id = sp.id AND ps.service_id = $2
+		WHERE sp.id = $1
+	`
+	err := s.db.GetContext(ctx, &rate, query, providerID, serviceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProviderNotFound
+		}
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// IsBlocked reports whether the client and the provider's user have blocked
+// each other in either direction.
+func (s *Store) IsBlocked(ctx context.Context, clientID, providerID uuid.UUID) (bool, error) {
+	var blocked bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks b
+			INNER JOIN service_providers sp ON sp.id = $2
+			WHERE (b.blocker_id = $1 AND b.blocked_id = sp.user_id)
+			   OR (b.blocker_id = sp.user_id AND b.blocked_id = $1)
+		)
+	`
+	err := s.db.GetContext(ctx, &blocked, query, clientID, providerID)
+	return blocked, err
+}
+
+func (s *Store) ListAllBookings(ctx context.Context, filter *BookingFilter) ([]*Booking, int, error) {
+	where, args := filter.build()
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM bookings b WHERE ` + where
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	var bookings []*Booking
+	query := fmt.Sprintf(`
+		SELECT b.*, sp.user_id AS provider_user_id
+		FROM bookings b
+		INNER JOIN service_providers sp ON b.provider_id = sp.id
+		WHERE %s
+		ORDER BY b.scheduled_date DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	err := s.db.SelectContext(ctx, &bookings, query, append(args, filter.Limit, filter.Offset)...)
+	return bookings, total, err
+}
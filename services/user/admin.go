@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"qasynda/shared/pkg/models"
+)
+
+const (
+	defaultUserPageSize    = 20
+	maxUserPageSize        = 100
+	maxSuspensionReasonLen = 500
+)
+
+var ErrAccountSuspended = errors.New("account suspended")
+
+type UserFilter struct {
+	Query     string
+	Role      string
+	Suspended *bool
+	Limit     int
+	Offset    int
+}
+
+func newUserFilter(req *models.ListUsersRequest) (*UserFilter, error) {
+	f := &UserFilter{
+		Query:     strings.TrimSpace(req.Query),
+		Role:      req.Role,
+		Suspended: req.Suspended,
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+	}
+
+	switch f.Role {
+	case "", "client", "provider", "admin":
+	default:
+		return nil, fmt.Errorf("%w: role must be one of client, provider, admin", ErrInvalidFilter)
+	}
+	if f.Limit <= 0 || f.Limit > maxUserPageSize {
+		f.Limit = defaultUserPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return f, nil
+}
+
+// build returns the WHERE clause for the filter against the users table as u.
+func (f *UserFilter) build() (string, queryArgs) {
+	var args queryArgs
+	conds := []string{"TRUE"}
+
+	if f.Query != "" {
+		pattern := args.add("%" + escapeLike(f.Query) + "%")
+		conds = append(conds, "(u.email ILIKE "+pattern+" OR u.full_name ILIKE "+pattern+")")
+	}
+	if f.Role != "" {
+		conds = append(conds, "u.role = "+args.add(f.Role))
+	}
+	if f.Suspended != nil {
+		if *f.Suspended {
+			conds = append(conds, "u.suspended_at IS NOT NULL")
+		} else {
+			conds = append(conds, "u.suspended_at IS NULL")
+		}
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+const (
+	earthRadiusKm    = 6371.0088
+	defaultRadiusKm  = 10.0
+	maxRadiusKm      = 200.0
+	defaultNearLimit = 20
+)
+
+var ErrInvalidCoordinates = errors.New("latitude must be within [-90, 90] and longitude within [-180, 180]")
+
+type boundingBox struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+func validCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// nearbyBox returns a lat/lng rectangle that contains every point within
+// radiusKm of the origin. It only pre-filters rows so the exact haversine
+// distance has to be computed for fewer of them.
+func nearbyBox(lat, lng, radiusKm float64) boundingBox {
+	latDelta := radiusKm / earthRadiusKm * 180 / math.Pi
+	box := boundingBox{
+		MinLat: math.Max(lat-latDelta, -90),
+		MaxLat: math.Min(lat+latDelta, 90),
+		MinLng: -180,
+		MaxLng: 180,
+	}
+
+	ratio := math.Sin(radiusKm/earthRadiusKm) / math.Cos(lat*math.Pi/180)
+	if box.MaxLat < 90 && box.MinLat > -90 && ratio < 1 {
+		lngDelta := math.Asin(ratio) * 180 / math.Pi
+		if lng-lngDelta > -180 && lng+lngDelta < 180 {
+			box.MinLng = lng - lngDelta
+			box.MaxLng = lng + lngDelta
+		}
+	}
+	return box
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"qasynda/shared/pkg/cache"
+	"qasynda/shared/pkg/logger"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	LoginSuccess            = "success"
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginThrottled          = "throttled"
+	LoginSuspended          = "suspended"
+
+	loginFailureWindow = 15 * time.Minute
+	loginBaseDelay     = time.Second
+)
+
+// loginLimit describes how failures in one scope slow down further attempts:
+// the first free failures cost nothing, then every failure doubles the wait,
+// and reaching lockAfter blocks the scope for lockFor.
+type loginLimit struct {
+	scope     string
+	free      int64
+	lockAfter int64
+	lockFor   time.Duration
+}
+
+var (
+	accountLoginLimit = loginLimit{scope: "account", free: 3, lockAfter: 10, lockFor: 15 * time.Minute}
+	// Many people can share an address, so it gets more room than an account.
+	ipLoginLimit = loginLimit{scope: "ip", free: 10, lockAfter: 50, lockFor: 15 * time.Minute}
+)
+
+func (l loginLimit) delay(failures int64) time.Duration {
+	switch {
+	case failures >= l.lockAfter:
+		return l.lockFor
+	case failures <= l.free:
+		return 0
+	}
+	shift := failures - l.free - 1
+	if shift >= 30 {
+		return l.lockFor
+	}
+	return min(loginBaseDelay<<shift, l.lockFor)
+}
+
+// LoginGuard counts failed logins and requests for account emails per account
+// and per client address in a cache.Store, so the counts are shared between
+// instances when Redis is configured.
+type LoginGuard struct {
+	store cache.Store
+	now   func() time.Time
+}
+
+func NewLoginGuard(store cache.Store) *LoginGuard {
+	return &LoginGuard{store: store, now: time.Now}
+}
+
+type loginKey struct {
+	limit loginLimit
+	id    string
+}
+
+func loginKeys(email, ip string) []loginKey {
+	keys := []loginKey{{limit: accountLoginLimit, id: strings.ToLower(strings.TrimSpace(email))}}
+	if ip != "" {
+		keys = append(keys, loginKey{limit: ipLoginLimit, id: ip})
+	}
+	return keys
+}
+
+func (k loginKey) failures() string { return "login:failures:" + k.limit.scope + ":" + k.id }
+func (k loginKey) blocked() string  { return "login:blocked:" + k.limit.scope + ":" + k.id }
+
+// loginReservation is a login that Reserve has already counted as failed,
+// with the failure count it took in each scope.
+type loginReservation struct {
+	keys     []loginKey
+	failures []int64
+}
+
+// Wait returns how long a login for email from ip has to be held off, or
+// zero if it may go ahead.
+func (g *LoginGuard) Wait(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range loginKeys(email, ip) {
+		value, ok, err := g.store.Get(ctx, key.blocked())
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		until, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		wait = max(wait, time.UnixMilli(until).Sub(g.now()))
+	}
+	return wait, nil
+}
+
+// Reserve counts a login for email from ip as failed before its password is
+// checked, so logins sent at the same time cannot all get past Wait: however
+// many arrive at once, no scope lets more than lockAfter through per window.
+// It returns how long the login has to be held off, or a reservation to settle
+// with Failed or Succeeded once the password has been checked. A reservation
+// that is never settled stays counted as a failure.
+func (g *LoginGuard) Reserve(ctx context.Context, email, ip string) (*loginReservation, time.Duration, error) {
+	wait, err := g.Wait(ctx, email, ip)
+	if err != nil || wait > 0 {
+		return nil, wait, err
+	}
+
+	r := &loginReservation{}
+	for _, key := range loginKeys(email, ip) {
+		failures, err := g.store.Incr(ctx, key.failures(), max(loginFailureWindow, key.limit.lockFor))
+		if err != nil {
+			g.release(ctx, r.keys)
+			return nil, 0, err
+		}
+		r.keys = append(r.keys, key)
+		r.failures = append(r.failures, failures)
+		if failures > key.limit.lockAfter {
+			// Another login got the last attempt and has not blocked the
+			// scope yet.
+			g.release(ctx, r.keys)
+			return nil, key.limit.lockFor, nil
+		}
+	}
+	return r, 0, nil
+}
+
+// release takes back the attempts a reservation counted.
+func (g *LoginGuard) release(ctx context.Context, keys []loginKey) {
+	for _, key := range keys {
+		if _, err := g.store.Decr(ctx, key.failures()); err != nil {
+			logger.Error("failed to release login attempt", err)
+		}
+	}
+}
+
+// Failed settles a reservation whose password was wrong by blocking further
+// attempts for as long as its failure counts call for.
+func (g *LoginGuard) Failed(ctx context.Context, r *loginReservation) error {
+	for i, key := range r.keys {
+		failures := r.failures[i]
+		delay := key.limit.delay(failures)
+		if delay == 0 {
+			continue
+		}
+		if failures == key.limit.lockAfter {
+			logger.Info("login locked after repeated failures", "scope", key.limit.scope, "id", key.id)
+		}
+		until := g.now().Add(delay).UnixMilli()
+		if err := g.store.Set(ctx, key.blocked(), strconv.FormatInt(until, 10), delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeeded settles a reservation whose password was right by clearing the
+// account's failures. The address only gets this attempt back and keeps its
+// other failures, or one working account would let it keep guessing at others.
+func (g *LoginGuard) Succeeded(ctx context.Context, r *loginReservation) error {
+	for _, key := range r.keys {
+		if key.limit.scope == accountLoginLimit.scope {
+			if err := g.store.Delete(ctx, key.failures(), key.blocked()); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := g.store.Decr(ctx, key.failures()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	passwordResetWindow = time.Hour
+	// An address can ask for more resets than an email, since many people
+	// can share it.
+	maxPasswordResetsPerEmail = 3
+	maxPasswordResetsPerIP    = 20
+)
+
+// AllowPasswordReset counts a password reset request for email from ip and
+// reports whether it is within the hourly limits of both.
+func (g *LoginGuard) AllowPasswordReset(ctx context.Context, email, ip string) (bool, error) {
+	limits := map[string]int64{"reset:email:" + strings.ToLower(strings.TrimSpace(email)): maxPasswordResetsPerEmail}
+	if ip != "" {
+		limits["reset:ip:"+ip] = maxPasswordResetsPerIP
+	}
+	allowed := true
+	for key, limit := range limits {
+		n, err := g.store.Incr(ctx, key, passwordResetWindow)
+		if err != nil {
+			return false, err
+		}
+		if n > limit {
+			allowed = false
+		}
+	}
+	return allowed, nil
+}
+
+const (
+	verificationResendWindow = time.Hour
+	maxVerificationResends   = 3
+)
+
+// AllowVerificationResend counts a request to resend userID's verification
+// email and reports whether it is within the hourly limit.
+func (g *LoginGuard) AllowVerificationResend(ctx context.Context, userID uuid.UUID) (bool, error) {
+	n, err := g.store.Incr(ctx, "verify:resend:"+userID.String(), verificationResendWindow)
+	if err != nil {
+		return false, err
+	}
+	return n <= maxVerificationResends, nil
+}
+
+// retryAfterSeconds rounds wait up to the whole seconds of a Retry-After
+// header.
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so that
+// answer takes as long as a wrong password.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+func (s *Server) recordLogin(ctx context.Context, email, ip string, user *User, outcome string) {
+	attempt := &LoginAttempt{
+		ID:        uuid.New(),
+		Email:     email,
+		IPAddress: ip,
+		Outcome:   outcome,
+		CreatedAt: time.Now(),
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+	if err := s.store.RecordLoginAttempt(ctx, attempt); err != nil {
+		logger.Error("failed to record login attempt", err)
+	}
+}
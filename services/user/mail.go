@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"qasynda/shared/pkg/config"
+	"qasynda/shared/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg *Mail) error
+}
+
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg, from), nil
+	case "log", "":
+		return NewLogMailer(cfg.Dir, from), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// render builds an RFC 5322 plain text message. The recipient is parsed
+// rather than pasted in so an address cannot smuggle in extra headers.
+func render(from *mail.Address, msg *Mail) (string, []byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", uuid.NewString(), domainOf(from.Address))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return to.Address, buf.Bytes(), nil
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
+
+// smtpTimeout bounds a whole SMTP exchange when ctx has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+type SMTPMailer struct {
+	host string
+	addr string
+	from *mail.Address
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg config.MailConfig, from *mail.Address) *SMTPMailer {
+	m := &SMTPMailer{host: cfg.SMTPHost, addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), from: from}
+	if cfg.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Mail) error {
+	to, data, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	// smtp.SendMail has no way to give up on a slow server, so the
+	// connection is dialed here and every read and write shares one deadline.
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// LogMailer is for local development: messages are written as .eml files to
+// dir, or to the log when no dir is set, instead of being sent.
+type LogMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+func NewLogMailer(dir string, from *mail.Address) *LogMailer {
+	return &LogMailer{dir: dir, from: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Mail) error {
+	to, data, err := render(m.from, msg)
+	if err != nil {
+		return err
+	}
+	if m.dir == "" {
+		logger.Info("mail", "to", to, "subject", msg.Subject, "body", msg.Body)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString())
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o600)
+}
@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/cache"
 	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/db"
 	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +23,11 @@ func main() {
 	logger.Init()
 	cfg := config.Load()
 
+	if err := models.RegisterValidators(); err != nil {
+		logger.Error("failed to register validators", err)
+		os.Exit(1)
+	}
+
 	database, err := db.Connect(cfg.DBUrl)
 	if err != nil {
 		logger.Error("failed to connect to db", err)
@@ -26,18 +35,63 @@ func main() {
 	}
 	defer database.Close()
 
+	keys, err := loadSigningKeys(config.GetJWTKeyConfig())
+	if err != nil {
+		logger.Error("failed to load signing keys", err)
+		os.Exit(1)
+	}
+	logger.Info("signing tokens with key " + keys.ActiveKeyID())
+
+	mailCfg := config.GetMailConfig()
+	mailer, err := NewMailer(mailCfg)
+	if err != nil {
+		logger.Error("failed to set up mailer", err)
+		os.Exit(1)
+	}
+
+	counters, err := cache.New(cfg.RedisUrl)
+	if err != nil {
+		logger.Error("failed to connect to redis", err)
+		os.Exit(1)
+	}
+
 	store := NewUserStore(database)
-	server := NewServer(store, cfg.JWTSecret)
+	logins := NewLoginGuard(counters)
+	server := NewServer(store, keys, NewAccountMailer(mailer, mailCfg.AppURL), logins)
 
 	r := gin.Default()
+	if err := TrustGateway(r, config.GetGatewayAddresses()); err != nil {
+		logger.Error("invalid GATEWAY_ADDRESSES", err)
+		os.Exit(1)
+	}
 
+	r.GET("/.well-known/jwks.json", server.JWKS)
 	r.POST("/register", server.Register)
 	r.POST("/login", server.Login)
+	r.POST("/refresh", server.Refresh)
+	r.POST("/logout", server.Logout)
+	r.POST("/verify-email", server.VerifyEmail)
+	r.POST("/verify-email/resend", server.ResendVerification)
+	r.POST("/forgot-password", server.ForgotPassword)
+	r.POST("/reset-password", server.ResetPassword)
 	r.POST("/validate", server.ValidateToken)
+	r.GET("/users", server.GetUsers)
 	r.GET("/users/:id", server.GetUser)
 	r.GET("/providers", server.ListProviders)
+	r.GET("/providers/search", server.SearchProviders)
+	r.GET("/providers/nearby", server.NearbyProviders)
+	r.PUT("/providers/:id/profile", server.UpdateProviderProfile)
+	r.GET("/providers/:id", server.GetProvider)
 	r.PUT("/providers/:id/status", server.UpdateProviderStatus)
 	r.GET("/providers/:id/status", server.GetProviderStatus)
+	r.GET("/blocks", server.ListBlocks)
+	r.POST("/users/:id/block", server.BlockUser)
+	r.DELETE("/users/:id/block", server.UnblockUser)
+	r.POST("/reports", server.CreateReport)
+	r.GET("/reports", server.ListReports)
+	r.PUT("/reports/:id", server.ResolveReport)
+	r.GET("/admin/users", server.ListUsers)
+	r.PUT("/admin/users/:id/suspension", server.SetUserSuspension)
 
 	port := config.GetUserPort()
 	srv := &http.Server{
@@ -66,3 +120,20 @@ func main() {
 
 	logger.Info("User Service exiting")
 }
+
+var errNoSigningKeys = errors.New("JWT_KEYS_DIR is not set; set JWT_TEMPORARY_KEY=true to use a temporary key in local development")
+
+// loadSigningKeys reads the keys from JWT_KEYS_DIR. Without one the service
+// refuses to start unless a temporary key was asked for, since tokens signed
+// with it stop working whenever the service restarts and every instance
+// would sign with a different key.
+func loadSigningKeys(cfg config.JWTKeyConfig) (*auth.KeySet, error) {
+	if cfg.Dir == "" {
+		if !cfg.TemporaryKey {
+			return nil, errNoSigningKeys
+		}
+		logger.Info("JWT_KEYS_DIR is not set, using a temporary signing key")
+		return auth.GenerateKeySet()
+	}
+	return auth.LoadKeySet(cfg.Dir, cfg.ActiveKeyID)
+}
@@ -1,20 +1,24 @@
 package main
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID           uuid.UUID `db:"id"`
-	Email        string    `db:"email"`
-	PasswordHash string    `db:"password_hash"`
-	Role         string    `db:"role"`
-	FullName     string    `db:"full_name"`
-	Phone        string    `db:"phone"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID               uuid.UUID  `db:"id"`
+	Email            string     `db:"email"`
+	PasswordHash     string     `db:"password_hash"`
+	Role             string     `db:"role"`
+	FullName         string     `db:"full_name"`
+	Phone            string     `db:"phone"`
+	SuspendedAt      *time.Time `db:"suspended_at"`
+	SuspensionReason string     `db:"suspension_reason"`
+	EmailVerifiedAt  *time.Time `db:"email_verified_at"`
+	CreatedAt        time.Time  `db:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at"`
 }
 
 type DetailedProvider struct {
@@ -30,4 +34,83 @@ type DetailedProvider struct {
 	Bio               string    `db:"bio"`
 	IsAvailable       bool      `db:"is_available"`
 	Rating            float64   `db:"rating"`
+	TotalReviews      int       `db:"total_reviews"`
+
+	Latitude   sql.NullFloat64 `db:"latitude"`
+	Longitude  sql.NullFloat64 `db:"longitude"`
+	DistanceKm sql.NullFloat64 `db:"distance_km"`
+}
+
+type FacetCount struct {
+	Value string `db:"value"`
+	Label string `db:"label"`
+	Count int    `db:"count"`
+}
+
+type ProviderFacets struct {
+	Locations []*FacetCount
+	Services  []*FacetCount
+	Ratings   []*FacetCount
+	MinRate   float64 `db:"min_rate"`
+	MaxRate   float64 `db:"max_rate"`
+	Available int     `db:"available"`
+}
+
+type ProviderProfileUpdate struct {
+	Bio             *string
+	HourlyRate      *float64
+	ExperienceYears *int
+	Location        *string
+	Latitude        *float64
+	Longitude       *float64
+}
+
+type BlockedUser struct {
+	UserID    uuid.UUID `db:"blocked_id"`
+	FullName  string    `db:"full_name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Report struct {
+	ID             uuid.UUID  `db:"id"`
+	ReporterID     uuid.UUID  `db:"reporter_id"`
+	TargetType     string     `db:"target_type"`
+	TargetID       uuid.UUID  `db:"target_id"`
+	ReportedUserID *uuid.UUID `db:"reported_user_id"`
+	Reason         string     `db:"reason"`
+	Details        string     `db:"details"`
+	Status         string     `db:"status"`
+	ResolutionNote string     `db:"resolution_note"`
+	ResolvedBy     *uuid.UUID `db:"resolved_by"`
+	ResolvedAt     *time.Time `db:"resolved_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+type RefreshToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	FamilyID  uuid.UUID  `db:"family_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+type AccountToken struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	Purpose   string     `db:"purpose"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+type LoginAttempt struct {
+	ID        uuid.UUID  `db:"id"`
+	UserID    *uuid.UUID `db:"user_id"`
+	Email     string     `db:"email"`
+	IPAddress string     `db:"ip_address"`
+	Outcome   string     `db:"outcome"`
+	CreatedAt time.Time  `db:"created_at"`
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+const (
+	maxReportDetailsLength = 2000
+	maxResolutionLength    = 2000
+	defaultReportPageSize  = 20
+	maxReportPageSize      = 100
+)
+
+var reportTargets = map[string]bool{"user": true, "message": true, "review": true}
+
+var reportReasons = map[string]bool{
+	"spam":          true,
+	"harassment":    true,
+	"fraud":         true,
+	"inappropriate": true,
+	"off_platform":  true,
+	"other":         true,
+}
+
+var (
+	ErrReportExists       = errors.New("you have already reported this")
+	ErrReportTargetAbsent = errors.New("report target not found")
+	ErrInvalidReport      = errors.New("invalid report")
+)
+
+// newReport validates a report request. The reported user is filled in later
+// from the target.
+func newReport(req *models.CreateReportRequest) (*Report, error) {
+	reporterID, err := uuid.Parse(req.ReporterID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: reporter_id must be a uuid", ErrInvalidReport)
+	}
+	if !reportTargets[req.TargetType] {
+		return nil, fmt.Errorf("%w: target_type must be one of user, message, review", ErrInvalidReport)
+	}
+	targetID, err := uuid.Parse(req.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: target_id must be a uuid", ErrInvalidReport)
+	}
+	if !reportReasons[req.Reason] {
+		return nil, fmt.Errorf("%w: reason must be one of spam, harassment, fraud, inappropriate, off_platform, other", ErrInvalidReport)
+	}
+	details := strings.TrimSpace(req.Details)
+	if utf8.RuneCountInString(details) > maxReportDetailsLength {
+		return nil, fmt.Errorf("%w: details must be at most %d characters", ErrInvalidReport, maxReportDetailsLength)
+	}
+	if req.TargetType == "user" && targetID == reporterID {
+		return nil, fmt.Errorf("%w: you cannot report yourself", ErrInvalidReport)
+	}
+
+	return &Report{
+		ID:         uuid.New(),
+		ReporterID: reporterID,
+		TargetType: req.TargetType,
+		TargetID:   targetID,
+		Reason:     req.Reason,
+		Details:    details,
+		Status:     ReportOpen,
+		CreatedAt:  time.Now(),
+	}, nil
+}
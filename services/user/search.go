@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"qasynda/shared/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SortRelevance  = "relevance"
+	SortRating     = "rating"
+	SortPriceAsc   = "price_asc"
+	SortPriceDesc  = "price_desc"
+	SortExperience = "experience"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var (
+	ErrInvalidSort   = errors.New("sort must be one of relevance, rating, price_asc, price_desc, experience")
+	ErrInvalidFilter = errors.New("invalid search filter")
+)
+
+type ProviderFilter struct {
+	Query         string
+	Location      string
+	MinRating     *float64
+	MinRate       *float64
+	MaxRate       *float64
+	Available     *bool
+	MinExperience *int
+	ServiceID     *uuid.UUID
+	Sort          string
+	Limit         int
+	Offset        int
+}
+
+func newProviderFilter(req *models.SearchProvidersRequest) (*ProviderFilter, error) {
+	f := &ProviderFilter{
+		Query:         strings.TrimSpace(req.Query),
+		Location:      strings.TrimSpace(req.Location),
+		MinRating:     req.MinRating,
+		MinRate:       req.MinRate,
+		MaxRate:       req.MaxRate,
+		Available:     req.Available,
+		MinExperience: req.MinExperience,
+		Sort:          req.Sort,
+		Limit:         req.Limit,
+		Offset:        req.Offset,
+	}
+
+	if req.ServiceID != "" {
+		id, err := uuid.Parse(req.ServiceID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: service_id must be a uuid", ErrInvalidFilter)
+		}
+		f.ServiceID = &id
+	}
+	if f.MinRate != nil && f.MaxRate != nil && *f.MinRate > *f.MaxRate {
+		return nil, fmt.Errorf("%w: min_rate must not exceed max_rate", ErrInvalidFilter)
+	}
+
+	switch f.Sort {
+	case "":
+		f.Sort = SortRating
+		if f.Query != "" {
+			f.Sort = SortRelevance
+		}
+	case SortRelevance, SortRating, SortPriceAsc, SortPriceDesc, SortExperience:
+	default:
+		return nil, ErrInvalidSort
+	}
+
+	if f.Limit <= 0 || f.Limit > maxSearchLimit {
+		f.Limit = defaultSearchLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	return f, nil
+}
+
+type queryArgs []interface{}
+
+func (a *queryArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// build returns the WHERE and ORDER BY clauses for the filter. Both refer to
+// the users table as u and service_providers as sp.
+func (f *ProviderFilter) build() (string, string, queryArgs) {
+	var args queryArgs
+	conds := []string{"u.role = 'provider'"}
+
+	tsQuery := ""
+	if f.Query != "" {
+		tsQuery = "plainto_tsquery('simple', " + args.add(f.Query) + ")"
+		conds = append(conds, "sp.search_vector @@ "+tsQuery)
+	}
+	if f.Location != "" {
+		conds = append(conds, "LOWER(sp.location) = LOWER("+args.add(f.Location)+")")
+	}
+	if f.MinRating != nil {
+		conds = append(conds, "sp.rating >= "+args.add(*f.MinRating))
+	}
+	if f.MinRate != nil {
+		conds = append(conds, "sp.hourly_rate >= "+args.add(*f.MinRate))
+	}
+	if f.MaxRate != nil {
+		conds = append(conds, "sp.hourly_rate <= "+args.add(*f.MaxRate))
+	}
+	if f.Available != nil {
+		conds = append(conds, "sp.is_available = "+args.add(*f.Available))
+	}
+	if f.MinExperience != nil {
+		conds = append(conds, "sp.experience_years >= "+args.add(*f.MinExperience))
+	}
+	if f.ServiceID != nil {
+		conds = append(conds, "EXISTS (SELECT 1 FROM provider_services ps WHERE ps.provider_id = sp.id AND ps.service_id = "+args.add(*f.ServiceID)+")")
+	}
+
+	var order string
+	switch f.Sort {
+	case SortRelevance:
+		if tsQuery != "" {
+			order = "ts_rank(sp.search_vector, " + tsQuery + ") DESC, sp.rating DESC"
+		} else {
+			order = "sp.rating DESC, sp.total_reviews DESC"
+		}
+	case SortPriceAsc:
+		order = "sp.hourly_rate ASC"
+	case SortPriceDesc:
+		order = "sp.hourly_rate DESC"
+	case SortExperience:
+		order = "sp.experience_years DESC"
+	default:
+		order = "sp.rating DESC, sp.total_reviews DESC"
+	}
+
+	return strings.Join(conds, " AND "), order + ", sp.id", args
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"qasynda/shared/pkg/auth"
 	"qasynda/shared/pkg/logger"
@@ -14,22 +20,33 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	maxBioLength       = 2000
+	maxLocationLength  = 255
+	maxHourlyRate      = 1000000
+	maxExperienceYears = 80
+)
+
 type Server struct {
-	store     IStore
-	jwtSecret string
+	store  IStore
+	keys   auth.Signer
+	mail   *AccountMailer
+	logins *LoginGuard
 }
 
-func NewServer(store IStore, jwtSecret string) *Server {
+func NewServer(store IStore, keys auth.Signer, mail *AccountMailer, logins *LoginGuard) *Server {
 	return &Server{
-		store:     store,
-		jwtSecret: jwtSecret,
+		store:  store,
+		keys:   keys,
+		mail:   mail,
+		logins: logins,
 	}
 }
 
 func (s *Server) Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
 		return
 	}
 
@@ -68,78 +85,99 @@ func (s *Server) Register(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
+	// The account works right away, so the email is sent after responding;
+	// a lost one can be sent again.
+	err = s.mail.enqueue("verification email", func(ctx context.Context) error {
+		return s.sendVerification(ctx, user)
+	})
+	if err != nil {
+		logger.Error("failed to queue verification email", err)
+	}
 
-	token, err := auth.GenerateToken(
-		user.ID.String(),
-		user.Email,
-		user.Role,
-		s.jwtSecret,
-		24*time.Hour,
-	)
+	res, err := s.issueTokens(c.Request.Context(), user, nil)
 	if err != nil {
-		logger.Error("failed to generate token", err)
+		logger.Error("failed to issue tokens", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, &models.AuthResponse{
-		Token: token,
-		User: &models.UserResponse{
-			ID:       user.ID.String(),
-			Email:    user.Email,
-			FullName: user.FullName,
-			Role:     user.Role,
-			Phone:    user.Phone,
-		},
-	})
+	c.JSON(http.StatusOK, res)
+}
+
+// TrustGateway makes c.ClientIP() return the address the gateway names in
+// models.ClientIPHeader. Behind the gateway the connection comes from the
+// gateway itself, and the header is ignored on connections from anywhere
+// else so callers cannot pick the address their attempts are counted under.
+func TrustGateway(r *gin.Engine, gateways []string) error {
+	r.ForwardedByClientIP = true
+	r.RemoteIPHeaders = []string{models.ClientIPHeader}
+	return r.SetTrustedProxies(gateways)
 }
 
 func (s *Server) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
 		return
 	}
 
-	user, err := s.store.GetByEmail(c.Request.Context(), req.Email)
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	// Throttling is keyed on the email as typed, so unknown addresses are
+	// held off exactly like real ones. If the counts cannot be read, logins
+	// are refused rather than let through unthrottled.
+	reservation, wait, err := s.logins.Reserve(ctx, req.Email, ip)
+	if err != nil {
+		logger.Error("failed to check login throttling", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again later"})
+		return
+	}
+	if wait > 0 {
+		s.recordLogin(ctx, req.Email, ip, nil, LoginThrottled)
+		retryAfter := retryAfterSeconds(wait)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts", "retry_after": retryAfter})
+		return
+	}
+
+	user, err := s.store.GetByEmail(ctx, req.Email)
 	if err != nil {
 		logger.Error("failed to get user", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
-	if user == nil {
+
+	passwordHash := dummyPasswordHash()
+	if user != nil {
+		passwordHash = []byte(user.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(passwordHash, []byte(req.Password)); err != nil || user == nil {
+		if err := s.logins.Failed(ctx, reservation); err != nil {
+			logger.Error("failed to record login failure", err)
+		}
+		s.recordLogin(ctx, req.Email, ip, user, LoginInvalidCredentials)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
-
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	if err := s.logins.Succeeded(ctx, reservation); err != nil {
+		logger.Error("failed to reset login failures", err)
+	}
+	if user.SuspendedAt != nil {
+		s.recordLogin(ctx, req.Email, ip, user, LoginSuspended)
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrAccountSuspended.Error()})
 		return
 	}
+	s.recordLogin(ctx, req.Email, ip, user, LoginSuccess)
 
-	token, err := auth.GenerateToken(
-		user.ID.String(),
-		user.Email,
-		user.Role,
-		s.jwtSecret,
-		24*time.Hour,
-	)
+	res, err := s.issueTokens(ctx, user, nil)
 	if err != nil {
-		logger.Error("failed to generate token", err)
+		logger.Error("failed to issue tokens", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, &models.AuthResponse{
-		Token: token,
-		User: &models.UserResponse{
-			ID:       user.ID.String(),
-			Email:    user.Email,
-			FullName: user.FullName,
-			Role:     user.Role,
-			Phone:    user.Phone,
-		},
-	})
+	c.JSON(http.StatusOK, res)
 }
 
 func (s *Server) ValidateToken(c *gin.Context) {
@@ -149,7 +187,7 @@ func (s *Server) ValidateToken(c *gin.Context) {
 		return
 	}
 
-	claims, err := auth.ValidateToken(req.Token, s.jwtSecret)
+	claims, err := auth.ParseToken(req.Token, s.keys)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
@@ -170,14 +208,12 @@ func (s *Server) ValidateToken(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrAccountSuspended.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, &models.UserResponse{
-		ID:       user.ID.String(),
-		Email:    user.Email,
-		FullName: user.FullName,
-		Role:     user.Role,
-		Phone:    user.Phone,
-	})
+	c.JSON(http.StatusOK, toUserResponse(user))
 }
 
 func (s *Server) GetUser(c *gin.Context) {
@@ -202,13 +238,65 @@ func (s *Server) GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, &models.UserResponse{
-		ID:       user.ID.String(),
-		Email:    user.Email,
-		FullName: user.FullName,
-		Role:     user.Role,
-		Phone:    user.Phone,
-	})
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+// maxUserLookup caps how many ids one GetUsers call may ask for; it matches
+// the largest conversation page the gateway fills in.
+const maxUserLookup = 100
+
+// GetUsers looks up several users at once from a comma-separated ids query.
+// Ids that match no user are left out of the answer.
+func (s *Server) GetUsers(c *gin.Context) {
+	var ids []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, raw := range strings.Split(c.Query("ids"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids is required"})
+		return
+	}
+	if len(ids) > maxUserLookup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d ids per request", maxUserLookup)})
+		return
+	}
+
+	users, err := s.store.GetByIDs(c.Request.Context(), ids)
+	if err != nil {
+		logger.Error("failed to look up users", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	res := &models.UsersResponse{Users: make([]*models.UserResponse, 0, len(users))}
+	for _, user := range users {
+		res.Users = append(res.Users, toUserResponse(user))
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func toUserResponse(u *User) *models.UserResponse {
+	return &models.UserResponse{
+		ID:            u.ID.String(),
+		Email:         u.Email,
+		FullName:      u.FullName,
+		Role:          u.Role,
+		Phone:         u.Phone,
+		EmailVerified: u.EmailVerifiedAt != nil,
+	}
 }
 
 func (s *Server) ListProviders(c *gin.Context) {
@@ -226,27 +314,138 @@ func (s *Server) ListProviders(c *gin.Context) {
 
 	var respProviders []*models.ProviderResponse
 	for _, p := range providers {
-		respProviders = append(respProviders, &models.ProviderResponse{
-			User: &models.UserResponse{
-				ID:       p.ID.String(),
-				Email:    p.Email,
-				FullName: p.FullName,
-				Role:     p.Role,
-				Phone:    p.Phone,
-			},
-			Location:        p.Location,
-			HourlyRate:      p.HourlyRate,
-			ExperienceYears: int(p.ExperienceYears),
-			Bio:             p.Bio,
-			IsAvailable:     p.IsAvailable,
-			Rating:          p.Rating,
-			ProviderID:      p.ServiceProviderID.String(),
-		})
+		respProviders = append(respProviders, toProviderResponse(p))
+	}
+
+	c.JSON(http.StatusOK, &models.ListProvidersResponse{Providers: respProviders})
+}
+
+func (s *Server) SearchProviders(c *gin.Context) {
+	var req models.SearchProvidersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter, err := newProviderFilter(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	providers, total, err := s.store.SearchProviders(c.Request.Context(), filter)
+	if err != nil {
+		logger.Error("failed to search providers", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	facets, err := s.store.ProviderFacets(c.Request.Context(), filter)
+	if err != nil {
+		logger.Error("failed to compute provider facets", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respProviders := make([]*models.ProviderResponse, 0, len(providers))
+	for _, p := range providers {
+		respProviders = append(respProviders, toProviderResponse(p))
+	}
+
+	c.JSON(http.StatusOK, &models.SearchProvidersResponse{
+		Providers: respProviders,
+		Total:     total,
+		Facets: &models.ProviderFacets{
+			Locations: toFacetCounts(facets.Locations),
+			Services:  toFacetCounts(facets.Services),
+			Ratings:   toFacetCounts(facets.Ratings),
+			Price:     &models.PriceFacet{Min: facets.MinRate, Max: facets.MaxRate},
+			Available: facets.Available,
+		},
+	})
+}
+
+func (s *Server) NearbyProviders(c *gin.Context) {
+	var req models.NearbyProvidersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("lat") == "" || c.Query("lng") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng are required"})
+		return
+	}
+	if !validCoordinates(req.Latitude, req.Longitude) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidCoordinates.Error()})
+		return
+	}
+
+	radius := req.RadiusKm
+	if radius == 0 {
+		radius = defaultRadiusKm
+	}
+	if radius < 0 || radius > maxRadiusKm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be between 0 and 200"})
+		return
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultNearLimit
+	}
+
+	providers, err := s.store.NearbyProviders(c.Request.Context(), req.Latitude, req.Longitude, radius, limit)
+	if err != nil {
+		logger.Error("failed to list nearby providers", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	respProviders := make([]*models.ProviderResponse, 0, len(providers))
+	for _, p := range providers {
+		respProviders = append(respProviders, toProviderResponse(p))
 	}
 
 	c.JSON(http.StatusOK, &models.ListProvidersResponse{Providers: respProviders})
 }
 
+func toProviderResponse(p *DetailedProvider) *models.ProviderResponse {
+	return &models.ProviderResponse{
+		User: &models.UserResponse{
+			ID:       p.ID.String(),
+			Email:    p.Email,
+			FullName: p.FullName,
+			Role:     p.Role,
+			Phone:    p.Phone,
+		},
+		Location:        p.Location,
+		HourlyRate:      p.HourlyRate,
+		ExperienceYears: int(p.ExperienceYears),
+		Bio:             p.Bio,
+		IsAvailable:     p.IsAvailable,
+		Rating:          p.Rating,
+		TotalReviews:    p.TotalReviews,
+		ProviderID:      p.ServiceProviderID.String(),
+		Latitude:        nullFloat(p.Latitude),
+		Longitude:       nullFloat(p.Longitude),
+		DistanceKm:      nullFloat(p.DistanceKm),
+	}
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+func toFacetCounts(counts []*FacetCount) []*models.FacetCount {
+	resp := make([]*models.FacetCount, 0, len(counts))
+	for _, fc := range counts {
+		resp = append(resp, &models.FacetCount{Value: fc.Value, Label: fc.Label, Count: fc.Count})
+	}
+	return resp
+}
+
 func (s *Server) UpdateProviderStatus(c *gin.Context) {
 	var req struct {
 		IsAvailable bool `json:"is_available"`
@@ -272,6 +471,116 @@ func (s *Server) UpdateProviderStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"is_available": req.IsAvailable})
 }
 
+func (s *Server) GetProvider(c *gin.Context) {
+	providerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid provider id"})
+		return
+	}
+
+	provider, err := s.store.GetProvider(c.Request.Context(), providerID)
+	if err != nil {
+		logger.Error("failed to get provider", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "provider not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toProviderResponse(provider))
+}
+
+func (s *Server) UpdateProviderProfile(c *gin.Context) {
+	var req models.UpdateProviderProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	update, err := newProfileUpdate(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := s.store.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("failed to get user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.Role != "provider" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only providers have a profile"})
+		return
+	}
+
+	if err := s.store.UpdateProviderProfile(c.Request.Context(), userID, update); err != nil {
+		logger.Error("failed to update provider profile", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	provider, err := s.store.GetProviderByUserID(c.Request.Context(), userID)
+	if err != nil || provider == nil {
+		logger.Error("failed to reload provider profile", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toProviderResponse(provider))
+}
+
+func newProfileUpdate(req *models.UpdateProviderProfileRequest) (*ProviderProfileUpdate, error) {
+	update := &ProviderProfileUpdate{
+		HourlyRate:      req.HourlyRate,
+		ExperienceYears: req.ExperienceYears,
+	}
+
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			return nil, fmt.Errorf("bio must be at most %d characters", maxBioLength)
+		}
+		update.Bio = &bio
+	}
+	if req.Location != nil {
+		location := strings.TrimSpace(*req.Location)
+		if utf8.RuneCountInString(location) > maxLocationLength {
+			return nil, fmt.Errorf("location must be at most %d characters", maxLocationLength)
+		}
+		update.Location = &location
+	}
+	if req.HourlyRate != nil && (*req.HourlyRate < 0 || *req.HourlyRate > maxHourlyRate) {
+		return nil, fmt.Errorf("hourly_rate must be between 0 and %d", maxHourlyRate)
+	}
+	if req.ExperienceYears != nil && (*req.ExperienceYears < 0 || *req.ExperienceYears > maxExperienceYears) {
+		return nil, fmt.Errorf("experience_years must be between 0 and %d", maxExperienceYears)
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return nil, errors.New("latitude and longitude must be set together")
+	}
+	if req.Latitude != nil {
+		if !validCoordinates(*req.Latitude, *req.Longitude) {
+			return nil, ErrInvalidCoordinates
+		}
+		update.Latitude, update.Longitude = req.Latitude, req.Longitude
+	}
+
+	return update, nil
+}
+
 func (s *Server) GetProviderStatus(c *gin.Context) {
 	userIDStr := c.Param("id")
 	userID, err := uuid.Parse(userIDStr)
@@ -289,3 +598,360 @@ func (s *Server) GetProviderStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"is_available": isAvailable})
 }
+
+// blockPair parses the caller from the body or query and the user being
+// blocked from the path.
+func blockPair(c *gin.Context, blockerStr string) (uuid.UUID, uuid.UUID, bool) {
+	blockerID, err := uuid.Parse(blockerStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	blockedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if blockerID == blockedID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot block yourself"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return blockerID, blockedID, true
+}
+
+func (s *Server) BlockUser(c *gin.Context) {
+	var req models.BlockUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	blockerID, blockedID, ok := blockPair(c, req.UserID)
+	if !ok {
+		return
+	}
+
+	user, err := s.store.GetByID(c.Request.Context(), blockedID)
+	if err != nil {
+		logger.Error("failed to get user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := s.store.BlockUser(c.Request.Context(), blockerID, blockedID); err != nil {
+		logger.Error("failed to block user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked": true})
+}
+
+func (s *Server) UnblockUser(c *gin.Context) {
+	blockerID, blockedID, ok := blockPair(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	if err := s.store.UnblockUser(c.Request.Context(), blockerID, blockedID); err != nil {
+		logger.Error("failed to unblock user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked": false})
+}
+
+func (s *Server) ListBlocks(c *gin.Context) {
+	userID, err := uuid.Parse(c.Query("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	blocks, err := s.store.ListBlocks(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("failed to list blocks", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.ListBlocksResponse{Blocks: make([]*models.BlockedUser, 0, len(blocks))}
+	for _, b := range blocks {
+		resp.Blocks = append(resp.Blocks, &models.BlockedUser{
+			UserID:    b.UserID.String(),
+			FullName:  b.FullName,
+			BlockedAt: b.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) CreateReport(c *gin.Context) {
+	var req models.CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := newReport(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	owner, err := s.store.ReportTargetOwner(c.Request.Context(), report.ReporterID, report.TargetType, report.TargetID)
+	if err != nil {
+		logger.Error("failed to look up report target", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if owner == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": ErrReportTargetAbsent.Error()})
+		return
+	}
+	report.ReportedUserID = owner
+
+	if err := s.store.CreateReport(c.Request.Context(), report); err != nil {
+		if errors.Is(err, ErrReportExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("failed to create report", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, toReportResponse(report))
+}
+
+func (s *Server) ListReports(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != ReportOpen && status != ReportResolved && status != ReportDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of open, resolved, dismissed"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultReportPageSize)))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > maxReportPageSize {
+		limit = defaultReportPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	reports, total, err := s.store.ListReports(c.Request.Context(), status, limit, offset)
+	if err != nil {
+		logger.Error("failed to list reports", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.ListReportsResponse{
+		Reports: make([]*models.ReportResponse, 0, len(reports)),
+		Total:   total,
+	}
+	for _, r := range reports {
+		resp.Reports = append(resp.Reports, toReportResponse(r))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) ResolveReport(c *gin.Context) {
+	var req models.ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reportID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid report id"})
+		return
+	}
+	resolvedBy, err := uuid.Parse(req.ResolvedBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resolved_by"})
+		return
+	}
+	if req.Status != ReportResolved && req.Status != ReportDismissed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be resolved or dismissed"})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(note) > maxResolutionLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("note must be at most %d characters", maxResolutionLength)})
+		return
+	}
+
+	ok, err := s.store.ResolveReport(c.Request.Context(), reportID, req.Status, resolvedBy, note)
+	if err != nil {
+		logger.Error("failed to resolve report", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	report, err := s.store.GetReport(c.Request.Context(), reportID)
+	if err != nil {
+		logger.Error("failed to get report", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "report not found"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "report has already been closed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toReportResponse(report))
+}
+
+func toReportResponse(r *Report) *models.ReportResponse {
+	resp := &models.ReportResponse{
+		ID:             r.ID.String(),
+		ReporterID:     r.ReporterID.String(),
+		TargetType:     r.TargetType,
+		TargetID:       r.TargetID.String(),
+		Reason:         r.Reason,
+		Details:        r.Details,
+		Status:         r.Status,
+		ResolutionNote: r.ResolutionNote,
+		CreatedAt:      r.CreatedAt.Format(time.RFC3339),
+	}
+	if r.ReportedUserID != nil {
+		resp.ReportedUserID = r.ReportedUserID.String()
+	}
+	if r.ResolvedBy != nil {
+		resp.ResolvedBy = r.ResolvedBy.String()
+	}
+	if r.ResolvedAt != nil {
+		resp.ResolvedAt = r.ResolvedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (s *Server) ListUsers(c *gin.Context) {
+	req := &models.ListUsersRequest{
+		Query: c.Query("q"),
+		Role:  c.Query("role"),
+	}
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultUserPageSize)))
+	req.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if v := c.Query("suspended"); v != "" {
+		suspended, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "suspended must be true or false"})
+			return
+		}
+		req.Suspended = &suspended
+	}
+
+	filter, err := newUserFilter(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	users, total, err := s.store.SearchUsers(c.Request.Context(), filter)
+	if err != nil {
+		logger.Error("failed to search users", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	resp := &models.ListUsersResponse{
+		Users: make([]*models.AdminUserResponse, 0, len(users)),
+		Total: total,
+	}
+	for _, u := range users {
+		resp.Users = append(resp.Users, toAdminUserResponse(u))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) SetUserSuspension(c *gin.Context) {
+	var req models.SetSuspensionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	adminID, err := uuid.Parse(req.AdminID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid admin_id"})
+		return
+	}
+	if userID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot suspend yourself"})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if !req.Suspended {
+		reason = ""
+	}
+	if utf8.RuneCountInString(reason) > maxSuspensionReasonLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reason must be at most %d characters", maxSuspensionReasonLen)})
+		return
+	}
+
+	user, err := s.store.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		logger.Error("failed to get user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.Role == "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin accounts cannot be suspended"})
+		return
+	}
+
+	if err := s.store.SetSuspension(c.Request.Context(), userID, req.Suspended, reason); err != nil {
+		logger.Error("failed to set suspension", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	user, err = s.store.GetByID(c.Request.Context(), userID)
+	if err != nil || user == nil {
+		logger.Error("failed to reload user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toAdminUserResponse(user))
+}
+
+func toAdminUserResponse(u *User) *models.AdminUserResponse {
+	resp := &models.AdminUserResponse{
+		ID:               u.ID.String(),
+		Email:            u.Email,
+		FullName:         u.FullName,
+		Role:             u.Role,
+		Phone:            u.Phone,
+		SuspensionReason: u.SuspensionReason,
+		CreatedAt:        u.CreatedAt.Format(time.RFC3339),
+	}
+	if u.SuspendedAt != nil {
+		resp.SuspendedAt = u.SuspendedAt.Format(time.RFC3339)
+	}
+	return resp
+}
@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/cache"
+	"qasynda/shared/pkg/config"
 	"qasynda/shared/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +32,13 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+func TestMain(m *testing.M) {
+	if err := models.RegisterValidators(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 type MockStore struct {
 	mock.Mock
 }
@@ -44,6 +64,14 @@ func (m *MockStore) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	return args.Get(0).(*User), args.Error(1)
 }
 
+func (m *MockStore) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*User, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*User), args.Error(1)
+}
+
 func (m *MockStore) ListProviders(limit, offset int) ([]*DetailedProvider, error) {
 	args := m.Called(limit, offset)
 	return args.Get(0).([]*DetailedProvider), args.Error(1)
@@ -59,10 +87,185 @@ func (m *MockStore) GetProviderStatus(ctx context.Context, userID uuid.UUID) (bo
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockStore) SearchProviders(ctx context.Context, filter *ProviderFilter) ([]*DetailedProvider, int, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*DetailedProvider), args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) ProviderFacets(ctx context.Context, filter *ProviderFilter) (*ProviderFacets, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*ProviderFacets), args.Error(1)
+}
+
+func (m *MockStore) NearbyProviders(ctx context.Context, lat, lng, radiusKm float64, limit int) ([]*DetailedProvider, error) {
+	args := m.Called(ctx, lat, lng, radiusKm, limit)
+	return args.Get(0).([]*DetailedProvider), args.Error(1)
+}
+
+func (m *MockStore) GetProvider(ctx context.Context, providerID uuid.UUID) (*DetailedProvider, error) {
+	args := m.Called(ctx, providerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DetailedProvider), args.Error(1)
+}
+
+func (m *MockStore) GetProviderByUserID(ctx context.Context, userID uuid.UUID) (*DetailedProvider, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*DetailedProvider), args.Error(1)
+}
+
+func (m *MockStore) UpdateProviderProfile(ctx context.Context, userID uuid.UUID, update *ProviderProfileUpdate) error {
+	args := m.Called(ctx, userID, update)
+	return args.Error(0)
+}
+
+func (m *MockStore) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	args := m.Called(ctx, blockerID, blockedID)
+	return args.Error(0)
+}
+
+func (m *MockStore) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	args := m.Called(ctx, blockerID, blockedID)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]*BlockedUser, error) {
+	args := m.Called(ctx, blockerID)
+	return args.Get(0).([]*BlockedUser), args.Error(1)
+}
+
+func (m *MockStore) ReportTargetOwner(ctx context.Context, reporterID uuid.UUID, targetType string, targetID uuid.UUID) (*uuid.UUID, error) {
+	args := m.Called(ctx, reporterID, targetType, targetID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*uuid.UUID), args.Error(1)
+}
+
+func (m *MockStore) CreateReport(ctx context.Context, report *Report) error {
+	args := m.Called(ctx, report)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListReports(ctx context.Context, status string, limit, offset int) ([]*Report, int, error) {
+	args := m.Called(ctx, status, limit, offset)
+	return args.Get(0).([]*Report), args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) GetReport(ctx context.Context, id uuid.UUID) (*Report, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Report), args.Error(1)
+}
+
+func (m *MockStore) ResolveReport(ctx context.Context, id uuid.UUID, status string, resolvedBy uuid.UUID, note string) (bool, error) {
+	args := m.Called(ctx, id, status, resolvedBy, note)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) SearchUsers(ctx context.Context, filter *UserFilter) ([]*User, int, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*User), args.Int(1), args.Error(2)
+}
+
+func (m *MockStore) SetSuspension(ctx context.Context, userID uuid.UUID, suspended bool, reason string) error {
+	args := m.Called(ctx, userID, suspended, reason)
+	return args.Error(0)
+}
+
+func (m *MockStore) RecordLoginAttempt(ctx context.Context, attempt *LoginAttempt) error {
+	args := m.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+func (m *MockStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*RefreshToken), args.Error(1)
+}
+
+func (m *MockStore) RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *RefreshToken) (bool, error) {
+	args := m.Called(ctx, oldID, next)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStore) RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+func (m *MockStore) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockStore) CreateAccountToken(ctx context.Context, token *AccountToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockStore) VerifyEmail(ctx context.Context, tokenHash string) (*uuid.UUID, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*uuid.UUID), args.Error(1)
+}
+
+func (m *MockStore) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (*uuid.UUID, error) {
+	args := m.Called(ctx, tokenHash, passwordHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*uuid.UUID), args.Error(1)
+}
+
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []*Mail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg *Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) Sent() []*Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Mail(nil), m.sent...)
+}
+
+func newTestMail() *AccountMailer {
+	return NewAccountMailer(new(recordingMailer), "http://app.test")
+}
+
+func newTestLogins() *LoginGuard {
+	return NewLoginGuard(cache.NewMemory())
+}
+
 func TestRegister(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockStore := new(MockStore)
-	server := NewServer(mockStore, "secret")
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
 
 	r := gin.Default()
 	r.POST("/register", server.Register)
@@ -76,6 +279,10 @@ func TestRegister(t *testing.T) {
 
 	mockStore.On("GetByEmail", mock.Anything, req.Email).Return(nil, nil)
 	mockStore.On("Create", mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("CreateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockStore.On("CreateAccountToken", mock.Anything, mock.MatchedBy(func(token *AccountToken) bool {
+		return token.Purpose == AccountTokenVerifyEmail
+	})).Return(nil)
 
 	body, _ := json.Marshal(req)
 	w := httptest.NewRecorder()
@@ -91,10 +298,72 @@ func TestRegister(t *testing.T) {
 	assert.Equal(t, req.Email, resp.User.Email)
 }
 
+func TestRegisterValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/register", server.Register)
+
+	register := func(req models.RegisterRequest) (int, *models.ValidationErrorResponse) {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/register", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+
+		var resp models.ValidationErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, &resp
+	}
+	rules := func(resp *models.ValidationErrorResponse) map[string]string {
+		out := make(map[string]string, len(resp.Fields))
+		for _, f := range resp.Fields {
+			out[f.Field] = f.Rule
+		}
+		return out
+	}
+
+	code, resp := register(models.RegisterRequest{
+		Email:    "not-an-email",
+		Password: "x",
+		Role:     "admin",
+		Phone:    "8 701 123 45 67",
+	})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "validation failed", resp.Error)
+	assert.Equal(t, map[string]string{
+		"email":     "email",
+		"password":  "password",
+		"full_name": "required",
+		"role":      "signup_role",
+		"phone":     "e164",
+	}, rules(resp))
+
+	for _, password := range []string{"password", "12345678", "short1", strings.Repeat("a1", 37)} {
+		code, resp = register(models.RegisterRequest{
+			Email:    "test@example.com",
+			Password: password,
+			FullName: "Test User",
+			Role:     "client",
+		})
+		assert.Equal(t, http.StatusBadRequest, code, password)
+		assert.Equal(t, map[string]string{"password": "password"}, rules(resp), password)
+	}
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/register", strings.NewReader("{"))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockStore.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+	mockStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
 func TestLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockStore := new(MockStore)
-	server := NewServer(mockStore, "secret")
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
 
 	r := gin.Default()
 	r.POST("/login", server.Login)
@@ -110,6 +379,12 @@ func TestLogin(t *testing.T) {
 	}
 
 	mockStore.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockStore.On("CreateRefreshToken", mock.Anything, mock.MatchedBy(func(token *RefreshToken) bool {
+		return token.UserID == uid && token.TokenHash != ""
+	})).Return(nil)
+	mockStore.On("RecordLoginAttempt", mock.Anything, mock.MatchedBy(func(attempt *LoginAttempt) bool {
+		return attempt.Outcome == LoginSuccess && attempt.UserID != nil && *attempt.UserID == uid
+	})).Return(nil)
 
 	req := models.LoginRequest{
 		Email:    user.Email,
@@ -127,13 +402,15 @@ func TestLogin(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.Equal(t, int(auth.AccessTokenTTL.Seconds()), resp.ExpiresIn)
 	assert.Equal(t, user.Email, resp.User.Email)
 }
 
 func TestValidateToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	mockStore := new(MockStore)
-	server := NewServer(mockStore, "secret")
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
 
 	r := gin.Default()
 	r.POST("/validate", server.ValidateToken)
@@ -166,3 +443,1114 @@ func TestValidateToken(t *testing.T) {
 	assert.Equal(t, user.Email, resp.Email)
 	assert.Equal(t, uid.String(), resp.ID)
 }
+
+func TestSearchProviders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/providers/search", server.SearchProviders)
+
+	serviceID := uuid.New()
+	matchFilter := mock.MatchedBy(func(f *ProviderFilter) bool {
+		return f.Query == "plumber" &&
+			f.Location == "Almaty" &&
+			f.MinRating != nil && *f.MinRating == 4.5 &&
+			f.MaxRate != nil && *f.MaxRate == 8000 &&
+			f.Available != nil && *f.Available &&
+			f.ServiceID != nil && *f.ServiceID == serviceID &&
+			f.Sort == SortPriceAsc &&
+			f.Limit == 20
+	})
+
+	mockStore.On("SearchProviders", mock.Anything, matchFilter).Return([]*DetailedProvider{
+		{ID: uuid.New(), ServiceProviderID: uuid.New(), FullName: "Dias", Location: "Almaty", Rating: 4.8},
+	}, 1, nil)
+	mockStore.On("ProviderFacets", mock.Anything, matchFilter).Return(&ProviderFacets{
+		Locations: []*FacetCount{{Value: "Almaty", Count: 1}},
+		Ratings:   []*FacetCount{{Value: "4", Label: "4+", Count: 1}},
+		MinRate:   6000,
+		MaxRate:   6000,
+		Available: 1,
+	}, nil)
+
+	url := "/providers/search?q=plumber&location=Almaty&min_rating=4.5&max_rate=8000&available=true&sort=price_asc&service_id=" + serviceID.String()
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", url, nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.SearchProvidersResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Total)
+	assert.Len(t, resp.Providers, 1)
+	assert.Equal(t, "Almaty", resp.Facets.Locations[0].Value)
+	assert.Equal(t, 6000.0, resp.Facets.Price.Min)
+}
+
+func TestSearchProvidersRejectsBadSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/providers/search", server.SearchProviders)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/providers/search?sort=name", nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStore.AssertNotCalled(t, "SearchProviders", mock.Anything, mock.Anything)
+}
+
+func TestProviderFilterBuild(t *testing.T) {
+	minExp := 3
+	filter, err := newProviderFilter(&models.SearchProvidersRequest{
+		Query:         "electrician",
+		MinExperience: &minExp,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, SortRelevance, filter.Sort)
+
+	where, order, args := filter.build()
+	assert.Equal(t, "u.role = 'provider' AND sp.search_vector @@ plainto_tsquery('simple', $1) AND sp.experience_years >= $2", where)
+	assert.Equal(t, "ts_rank(sp.search_vector, plainto_tsquery('simple', $1)) DESC, sp.rating DESC, sp.id", order)
+	assert.Equal(t, queryArgs{"electrician", 3}, args)
+}
+
+func TestNearbyProviders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/providers/nearby", server.NearbyProviders)
+
+	mockStore.On("NearbyProviders", mock.Anything, 43.2389, 76.8897, 5.0, 20).Return([]*DetailedProvider{
+		{
+			ID:                uuid.New(),
+			ServiceProviderID: uuid.New(),
+			Latitude:          sql.NullFloat64{Float64: 43.25, Valid: true},
+			Longitude:         sql.NullFloat64{Float64: 76.9, Valid: true},
+			DistanceKm:        sql.NullFloat64{Float64: 1.52, Valid: true},
+		},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/providers/nearby?lat=43.2389&lng=76.8897&radius_km=5", nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ListProvidersResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Providers, 1)
+	assert.Equal(t, 1.52, *resp.Providers[0].DistanceKm)
+}
+
+func TestNearbyProvidersValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := NewServer(new(MockStore), auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/providers/nearby", server.NearbyProviders)
+
+	for _, url := range []string{
+		"/providers/nearby?lng=76.8",
+		"/providers/nearby?lat=91&lng=76.8",
+		"/providers/nearby?lat=43.2&lng=76.8&radius_km=500",
+	} {
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("GET", url, nil)
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestNearbyBox(t *testing.T) {
+	box := nearbyBox(43.2389, 76.8897, 10)
+
+	// 10 km is roughly 0.09 degrees of latitude and, at Almaty's latitude,
+	// about 0.12 degrees of longitude.
+	assert.InDelta(t, 43.149, box.MinLat, 0.001)
+	assert.InDelta(t, 43.329, box.MaxLat, 0.001)
+	assert.InDelta(t, 76.766, box.MinLng, 0.001)
+	assert.InDelta(t, 77.013, box.MaxLng, 0.001)
+
+	polar := nearbyBox(89.95, 10, 20)
+	assert.Equal(t, -180.0, polar.MinLng)
+	assert.Equal(t, 180.0, polar.MaxLng)
+}
+
+func TestUpdateProviderProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.PUT("/providers/:id/profile", server.UpdateProviderProfile)
+
+	userID := uuid.New()
+	mockStore.On("GetByID", mock.Anything, userID).Return(&User{ID: userID, Role: "provider"}, nil)
+	mockStore.On("UpdateProviderProfile", mock.Anything, userID, mock.MatchedBy(func(u *ProviderProfileUpdate) bool {
+		return u.Bio != nil && *u.Bio == "Licensed electrician" &&
+			u.HourlyRate != nil && *u.HourlyRate == 7500 &&
+			u.ExperienceYears == nil && u.Location == nil &&
+			u.Latitude != nil && *u.Latitude == 43.2389 && u.Longitude != nil && *u.Longitude == 76.8897
+	})).Return(nil)
+	mockStore.On("GetProviderByUserID", mock.Anything, userID).Return(&DetailedProvider{
+		ID:                userID,
+		ServiceProviderID: uuid.New(),
+		Bio:               "Licensed electrician",
+		HourlyRate:        7500,
+	}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"bio": "  Licensed electrician ", "hourly_rate": 7500, "latitude": 43.2389, "longitude": 76.8897,
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("PUT", "/providers/"+userID.String()+"/profile", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ProviderResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Licensed electrician", resp.Bio)
+	assert.Equal(t, 7500.0, resp.HourlyRate)
+	mockStore.AssertExpectations(t)
+}
+
+func TestUpdateProviderProfileValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.PUT("/providers/:id/profile", server.UpdateProviderProfile)
+
+	clientID := uuid.New()
+	mockStore.On("GetByID", mock.Anything, clientID).Return(&User{ID: clientID, Role: "client"}, nil)
+
+	cases := []struct {
+		body map[string]interface{}
+		want int
+	}{
+		{map[string]interface{}{"hourly_rate": -1}, http.StatusBadRequest},
+		{map[string]interface{}{"experience_years": 120}, http.StatusBadRequest},
+		{map[string]interface{}{"bio": string(bytes.Repeat([]byte("a"), maxBioLength+1))}, http.StatusBadRequest},
+		{map[string]interface{}{"latitude": 43.2}, http.StatusBadRequest},
+		{map[string]interface{}{"latitude": 91, "longitude": 76.9}, http.StatusBadRequest},
+		{map[string]interface{}{"bio": "hello"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(tc.body)
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("PUT", "/providers/"+clientID.String()+"/profile", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, tc.want, w.Code, string(body))
+	}
+	mockStore.AssertNotCalled(t, "UpdateProviderProfile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/providers/:id", server.GetProvider)
+
+	providerID := uuid.New()
+	missingID := uuid.New()
+	mockStore.On("GetProvider", mock.Anything, providerID).Return(&DetailedProvider{
+		ID:                uuid.New(),
+		ServiceProviderID: providerID,
+		FullName:          "Aruzhan",
+	}, nil)
+	mockStore.On("GetProvider", mock.Anything, missingID).Return(nil, nil)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/providers/"+providerID.String(), nil)
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ProviderResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "Aruzhan", resp.User.FullName)
+	assert.Equal(t, providerID.String(), resp.ProviderID)
+
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("GET", "/providers/"+missingID.String(), nil)
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/users", server.GetUsers)
+
+	aliceID := uuid.New()
+	missingID := uuid.New()
+	mockStore.On("GetByIDs", mock.Anything, []uuid.UUID{aliceID, missingID}).Return([]*User{
+		{ID: aliceID, FullName: "Alice", Role: "client"},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	query := aliceID.String() + "," + missingID.String() + "," + aliceID.String()
+	httpReq, _ := http.NewRequest("GET", "/users?ids="+query, nil)
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.UsersResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if assert.Len(t, resp.Users, 1) {
+		assert.Equal(t, "Alice", resp.Users[0].FullName)
+	}
+
+	tooMany := make([]string, maxUserLookup+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.NewString()
+	}
+	for _, ids := range []string{"", "not-a-uuid", strings.Join(tooMany, ",")} {
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("GET", "/users?ids="+ids, nil)
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, http.StatusBadRequest, w.Code, ids)
+	}
+	mockStore.AssertNumberOfCalls(t, "GetByIDs", 1)
+}
+
+func TestBlockUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/users/:id/block", server.BlockUser)
+
+	blockerID := uuid.New()
+	blockedID := uuid.New()
+	missingID := uuid.New()
+	mockStore.On("GetByID", mock.Anything, blockedID).Return(&User{ID: blockedID}, nil)
+	mockStore.On("GetByID", mock.Anything, missingID).Return(nil, nil)
+	mockStore.On("BlockUser", mock.Anything, blockerID, blockedID).Return(nil)
+
+	cases := []struct {
+		target string
+		want   int
+	}{
+		{blockedID.String(), http.StatusOK},
+		{blockerID.String(), http.StatusBadRequest},
+		{missingID.String(), http.StatusNotFound},
+		{"not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(models.BlockUserRequest{UserID: blockerID.String()})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/users/"+tc.target+"/block", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, tc.want, w.Code, tc.target)
+	}
+	mockStore.AssertNumberOfCalls(t, "BlockUser", 1)
+}
+
+func TestCreateReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/reports", server.CreateReport)
+
+	reporterID := uuid.New()
+	messageID := uuid.New()
+	senderID := uuid.New()
+	mockStore.On("ReportTargetOwner", mock.Anything, reporterID, "message", messageID).Return(&senderID, nil)
+	mockStore.On("CreateReport", mock.Anything, mock.MatchedBy(func(rep *Report) bool {
+		return rep.ReporterID == reporterID && rep.TargetID == messageID &&
+			rep.ReportedUserID != nil && *rep.ReportedUserID == senderID &&
+			rep.Status == ReportOpen && rep.Details == "asked me to pay outside the app"
+	})).Return(nil)
+
+	body, _ := json.Marshal(models.CreateReportRequest{
+		ReporterID: reporterID.String(),
+		TargetType: "message",
+		TargetID:   messageID.String(),
+		Reason:     "off_platform",
+		Details:    "  asked me to pay outside the app ",
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/reports", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var resp models.ReportResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, senderID.String(), resp.ReportedUserID)
+	assert.Equal(t, ReportOpen, resp.Status)
+	mockStore.AssertExpectations(t)
+}
+
+func TestCreateReportValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/reports", server.CreateReport)
+
+	reporterID := uuid.New()
+	otherID := uuid.New()
+	mockStore.On("ReportTargetOwner", mock.Anything, reporterID, "review", otherID).Return(nil, nil)
+	mockStore.On("ReportTargetOwner", mock.Anything, reporterID, "user", otherID).Return(&otherID, nil)
+	mockStore.On("CreateReport", mock.Anything, mock.Anything).Return(ErrReportExists)
+
+	cases := []struct {
+		req  models.CreateReportRequest
+		want int
+	}{
+		{models.CreateReportRequest{ReporterID: reporterID.String(), TargetType: "booking", TargetID: otherID.String(), Reason: "spam"}, http.StatusBadRequest},
+		{models.CreateReportRequest{ReporterID: reporterID.String(), TargetType: "user", TargetID: otherID.String(), Reason: "rude"}, http.StatusBadRequest},
+		{models.CreateReportRequest{ReporterID: reporterID.String(), TargetType: "user", TargetID: reporterID.String(), Reason: "spam"}, http.StatusBadRequest},
+		{models.CreateReportRequest{ReporterID: reporterID.String(), TargetType: "review", TargetID: otherID.String(), Reason: "spam"}, http.StatusNotFound},
+		{models.CreateReportRequest{ReporterID: reporterID.String(), TargetType: "user", TargetID: otherID.String(), Reason: "harassment"}, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(tc.req)
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/reports", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, tc.want, w.Code, string(body))
+	}
+}
+
+func TestResolveReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.PUT("/reports/:id", server.ResolveReport)
+
+	adminID := uuid.New()
+	openID := uuid.New()
+	closedID := uuid.New()
+	resolvedAt := time.Now()
+	mockStore.On("ResolveReport", mock.Anything, openID, ReportResolved, adminID, "user warned").Return(true, nil)
+	mockStore.On("GetReport", mock.Anything, openID).Return(&Report{
+		ID: openID, Status: ReportResolved, ResolvedBy: &adminID, ResolvedAt: &resolvedAt, ResolutionNote: "user warned",
+	}, nil)
+	mockStore.On("ResolveReport", mock.Anything, closedID, ReportDismissed, adminID, "").Return(false, nil)
+	mockStore.On("GetReport", mock.Anything, closedID).Return(&Report{ID: closedID, Status: ReportResolved}, nil)
+
+	body, _ := json.Marshal(models.ResolveReportRequest{Status: ReportResolved, Note: " user warned ", ResolvedBy: adminID.String()})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("PUT", "/reports/"+openID.String(), bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.ReportResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, ReportResolved, resp.Status)
+	assert.Equal(t, adminID.String(), resp.ResolvedBy)
+
+	body, _ = json.Marshal(models.ResolveReportRequest{Status: ReportDismissed, ResolvedBy: adminID.String()})
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("PUT", "/reports/"+closedID.String(), bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	body, _ = json.Marshal(models.ResolveReportRequest{Status: ReportOpen, ResolvedBy: adminID.String()})
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("PUT", "/reports/"+openID.String(), bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSuspendedUserRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/login", server.Login)
+	r.POST("/validate", server.ValidateToken)
+
+	password := "password123"
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	suspendedAt := time.Now()
+	user := &User{
+		ID:           uuid.New(),
+		Email:        "banned@example.com",
+		PasswordHash: string(hashedPassword),
+		Role:         "client",
+		SuspendedAt:  &suspendedAt,
+	}
+	mockStore.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockStore.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockStore.On("RecordLoginAttempt", mock.Anything, mock.Anything).Return(nil)
+
+	body, _ := json.Marshal(models.LoginRequest{Email: user.Email, Password: password})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	body, _ = json.Marshal(models.LoginRequest{Email: user.Email, Password: "wrong"})
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	token, _ := auth.GenerateToken(user.ID.String(), user.Email, user.Role, "secret", time.Hour)
+	body, _ = json.Marshal(models.ValidateTokenRequest{Token: token})
+	w = httptest.NewRecorder()
+	httpReq, _ = http.NewRequest("POST", "/validate", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSetUserSuspension(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.PUT("/admin/users/:id/suspension", server.SetUserSuspension)
+
+	adminID := uuid.New()
+	otherAdminID := uuid.New()
+	userID := uuid.New()
+	suspendedAt := time.Now()
+	mockStore.On("GetByID", mock.Anything, otherAdminID).Return(&User{ID: otherAdminID, Role: "admin"}, nil)
+	mockStore.On("GetByID", mock.Anything, userID).Return(&User{ID: userID, Role: "client"}, nil).Once()
+	mockStore.On("SetSuspension", mock.Anything, userID, true, "spam").Return(nil)
+	mockStore.On("GetByID", mock.Anything, userID).Return(&User{
+		ID: userID, Role: "client", SuspendedAt: &suspendedAt, SuspensionReason: "spam",
+	}, nil).Once()
+
+	body, _ := json.Marshal(models.SetSuspensionRequest{Suspended: true, Reason: " spam ", AdminID: adminID.String()})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("PUT", "/admin/users/"+userID.String()+"/suspension", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp models.AdminUserResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.SuspendedAt)
+	assert.Equal(t, "spam", resp.SuspensionReason)
+
+	cases := []struct {
+		target string
+		want   int
+	}{
+		{adminID.String(), http.StatusBadRequest},
+		{otherAdminID.String(), http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		body, _ := json.Marshal(models.SetSuspensionRequest{Suspended: true, AdminID: adminID.String()})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("PUT", "/admin/users/"+tc.target+"/suspension", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		assert.Equal(t, tc.want, w.Code, tc.target)
+	}
+	mockStore.AssertNumberOfCalls(t, "SetSuspension", 1)
+}
+
+func TestUserFilterBuild(t *testing.T) {
+	suspended := true
+	f, err := newUserFilter(&models.ListUsersRequest{Query: " 50%_off ", Role: "provider", Suspended: &suspended})
+	assert.NoError(t, err)
+
+	where, args := f.build()
+	assert.Equal(t, "TRUE AND (u.email ILIKE $1 OR u.full_name ILIKE $1) AND u.role = $2 AND u.suspended_at IS NOT NULL", where)
+	assert.Equal(t, queryArgs{`%50\%\_off%`, "provider"}, args)
+	assert.Equal(t, defaultUserPageSize, f.Limit)
+
+	_, err = newUserFilter(&models.ListUsersRequest{Role: "superuser"})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/refresh", server.Refresh)
+
+	uid := uuid.New()
+	user := &User{ID: uid, Email: "test@example.com", Role: "client"}
+	mockStore.On("GetByID", mock.Anything, uid).Return(user, nil)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.RefreshRequest{RefreshToken: token})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/refresh", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	current := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uid,
+		FamilyID:  uuid.New(),
+		TokenHash: auth.HashOpaqueToken("current"),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	mockStore.On("GetRefreshToken", mock.Anything, current.TokenHash).Return(current, nil)
+	mockStore.On("RotateRefreshToken", mock.Anything, current.ID, mock.MatchedBy(func(next *RefreshToken) bool {
+		return next.FamilyID == current.FamilyID && next.TokenHash != current.TokenHash
+	})).Return(true, nil)
+
+	w := refresh("current")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp models.AuthResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Token)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.NotEqual(t, "current", resp.RefreshToken)
+
+	// Reusing an already rotated token revokes the whole session.
+	revokedAt := time.Now()
+	reused := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uid,
+		FamilyID:  uuid.New(),
+		TokenHash: auth.HashOpaqueToken("reused"),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+	mockStore.On("GetRefreshToken", mock.Anything, reused.TokenHash).Return(reused, nil)
+	mockStore.On("RevokeRefreshFamily", mock.Anything, reused.FamilyID).Return(nil)
+
+	w = refresh("reused")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockStore.AssertCalled(t, "RevokeRefreshFamily", mock.Anything, reused.FamilyID)
+
+	expired := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    uid,
+		FamilyID:  uuid.New(),
+		TokenHash: auth.HashOpaqueToken("expired"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	mockStore.On("GetRefreshToken", mock.Anything, expired.TokenHash).Return(expired, nil)
+	mockStore.On("GetRefreshToken", mock.Anything, auth.HashOpaqueToken("unknown")).Return(nil, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, refresh("expired").Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh("unknown").Code)
+	assert.Equal(t, http.StatusBadRequest, refresh("").Code)
+}
+
+func TestLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/logout", server.Logout)
+
+	logout := func(req models.LogoutRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/logout", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	uid := uuid.New()
+	session := &RefreshToken{ID: uuid.New(), UserID: uid, FamilyID: uuid.New()}
+	mockStore.On("GetRefreshToken", mock.Anything, auth.HashOpaqueToken("mine")).Return(session, nil)
+	mockStore.On("RevokeRefreshFamily", mock.Anything, session.FamilyID).Return(nil)
+
+	w := logout(models.LogoutRequest{RefreshToken: "mine", UserID: uid.String()})
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStore.AssertCalled(t, "RevokeRefreshFamily", mock.Anything, session.FamilyID)
+
+	// Someone else's token is left alone.
+	w = logout(models.LogoutRequest{RefreshToken: "mine", UserID: uuid.NewString()})
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStore.AssertNumberOfCalls(t, "RevokeRefreshFamily", 1)
+
+	mockStore.On("RevokeUserRefreshTokens", mock.Anything, uid).Return(nil)
+	w = logout(models.LogoutRequest{All: true, UserID: uid.String()})
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStore.AssertCalled(t, "RevokeUserRefreshTokens", mock.Anything, uid)
+
+	w = logout(models.LogoutRequest{UserID: uid.String()})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := auth.GenerateKeySet()
+	assert.NoError(t, err)
+	server := NewServer(new(MockStore), keys, newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.GET("/.well-known/jwks.json", server.JWKS)
+
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var set auth.JWKSet
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, keys.ActiveKeyID(), set.Keys[0].Kid)
+	assert.Equal(t, "EdDSA", set.Keys[0].Alg)
+	assert.Empty(t, set.Keys[0].N)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/verify-email", server.VerifyEmail)
+
+	verify := func(token string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.VerifyEmailRequest{Token: token})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/verify-email", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	uid := uuid.New()
+	mockStore.On("VerifyEmail", mock.Anything, auth.HashOpaqueToken("good")).Return(&uid, nil)
+	mockStore.On("VerifyEmail", mock.Anything, auth.HashOpaqueToken("used")).Return(nil, nil)
+
+	assert.Equal(t, http.StatusOK, verify("good").Code)
+	assert.Equal(t, http.StatusBadRequest, verify("used").Code)
+	assert.Equal(t, http.StatusBadRequest, verify("").Code)
+}
+
+func TestResendVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	mailer := new(recordingMailer)
+	server := NewServer(mockStore, auth.Secret("secret"), NewAccountMailer(mailer, "http://app.test/"), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/resend-verification", server.ResendVerification)
+
+	resend := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ResendVerificationRequest{UserID: userID.String()})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/resend-verification", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	user := &User{ID: uuid.New(), Email: "test@example.com", FullName: "Test User"}
+	mockStore.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockStore.On("CreateAccountToken", mock.Anything, mock.MatchedBy(func(token *AccountToken) bool {
+		return token.UserID == user.ID && token.Purpose == AccountTokenVerifyEmail
+	})).Return(nil)
+
+	for i := 0; i < maxVerificationResends; i++ {
+		assert.Equal(t, http.StatusAccepted, resend(user.ID).Code)
+	}
+	w := resend(user.ID)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	assert.Eventually(t, func() bool { return len(mailer.Sent()) == maxVerificationResends }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, mailer.Sent()[0].Body, "http://app.test/verify-email?token=")
+}
+
+func TestForgotPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	mailer := new(recordingMailer)
+	server := NewServer(mockStore, auth.Secret("secret"), NewAccountMailer(mailer, "http://app.test/"), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/forgot-password", server.ForgotPassword)
+
+	forgot := func(email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ForgotPasswordRequest{Email: email})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/forgot-password", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	user := &User{ID: uuid.New(), Email: "test@example.com", FullName: "Test User"}
+	mockStore.On("GetByEmail", mock.Anything, user.Email).Return(user, nil)
+	mockStore.On("GetByEmail", mock.Anything, "nobody@example.com").Return(nil, nil)
+	mockStore.On("CreateAccountToken", mock.Anything, mock.MatchedBy(func(token *AccountToken) bool {
+		return token.UserID == user.ID && token.Purpose == AccountTokenResetPassword
+	})).Return(nil)
+
+	known, unknown := forgot(user.Email), forgot("nobody@example.com")
+	assert.Equal(t, http.StatusAccepted, known.Code)
+	assert.Equal(t, known.Code, unknown.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	assert.Eventually(t, func() bool { return len(mailer.Sent()) == 1 }, time.Second, 10*time.Millisecond)
+	sent := mailer.Sent()[0]
+	assert.Equal(t, user.Email, sent.To)
+	assert.Contains(t, sent.Body, "http://app.test/reset-password?token=")
+
+	assert.Equal(t, http.StatusBadRequest, forgot("").Code)
+}
+
+func TestForgotPasswordThrottling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+	mockStore.On("GetByEmail", mock.Anything, mock.Anything).Return(nil, nil)
+
+	r := gin.Default()
+	assert.NoError(t, TrustGateway(r, []string{testGatewayAddr}))
+	r.POST("/forgot-password", server.ForgotPassword)
+
+	forgot := func(email, ip string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ForgotPasswordRequest{Email: email})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, fromGateway(httptest.NewRequest("POST", "/forgot-password", bytes.NewBuffer(body)), ip))
+		return w
+	}
+
+	for i := 0; i < maxPasswordResetsPerEmail; i++ {
+		assert.Equal(t, http.StatusAccepted, forgot("Test@example.com", "203.0.113.1").Code)
+	}
+	w := forgot("test@example.com", "203.0.113.2")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// One address asking for many emails is cut off as well.
+	for i := 0; i < maxPasswordResetsPerIP; i++ {
+		forgot(uuid.NewString()+"@example.com", "203.0.113.3")
+	}
+	assert.Equal(t, http.StatusTooManyRequests, forgot("fresh@example.com", "203.0.113.3").Code)
+	assert.Equal(t, http.StatusAccepted, forgot("fresh@example.com", "203.0.113.4").Code)
+}
+
+func TestResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	r.POST("/reset-password", server.ResetPassword)
+
+	reset := func(token, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ResetPasswordRequest{Token: token, Password: password})
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/reset-password", bytes.NewBuffer(body))
+		r.ServeHTTP(w, httpReq)
+		return w
+	}
+
+	uid := uuid.New()
+	mockStore.On("ResetPassword", mock.Anything, auth.HashOpaqueToken("good"), mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("new-password1")) == nil
+	})).Return(&uid, nil)
+	mockStore.On("ResetPassword", mock.Anything, auth.HashOpaqueToken("expired"), mock.Anything).Return(nil, nil)
+
+	w := reset("good", "new-password1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp models.ResetPasswordResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, uid.String(), resp.UserID)
+
+	assert.Equal(t, http.StatusBadRequest, reset("expired", "new-password1").Code)
+	assert.Equal(t, http.StatusBadRequest, reset("good", "short1").Code)
+	assert.Equal(t, http.StatusBadRequest, reset("good", "no-digits-here").Code)
+	mockStore.AssertNumberOfCalls(t, "ResetPassword", 2)
+}
+
+func TestRenderMail(t *testing.T) {
+	from := &mail.Address{Name: "Qasynda", Address: "no-reply@qasynda.local"}
+
+	to, data, err := render(from, &Mail{To: "Test User <test@example.com>", Subject: "Hello", Body: "line one\nline two"})
+	assert.NoError(t, err)
+	assert.Equal(t, "test@example.com", to)
+	assert.Contains(t, string(data), "Subject: Hello\r\n")
+	assert.True(t, strings.HasSuffix(string(data), "\r\n\r\nline one\r\nline two"))
+
+	_, _, err = render(from, &Mail{To: "test@example.com\r\nBcc: victim@example.com", Subject: "Hello"})
+	assert.Error(t, err)
+}
+
+// fakeSMTP accepts one connection and accepts whatever it is sent, passing
+// the message data on to the returned channel. Without greet it never
+// answers at all.
+func fakeSMTP(t *testing.T, greet bool) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if !greet {
+			io.Copy(io.Discard, conn)
+			return
+		}
+		rd := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 fake\r\n")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				received <- data.String()
+				fmt.Fprint(conn, "250 queued\r\n")
+			case inData:
+				data.WriteString(line)
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				fmt.Fprint(conn, "354 go ahead\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 ok\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func newTestSMTPMailer(t *testing.T, addr string) *SMTPMailer {
+	host, port, err := net.SplitHostPort(addr)
+	assert.NoError(t, err)
+	from := &mail.Address{Address: "no-reply@qasynda.local"}
+	return NewSMTPMailer(config.MailConfig{SMTPHost: host, SMTPPort: port}, from)
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	addr, received := fakeSMTP(t, true)
+	err := newTestSMTPMailer(t, addr).Send(t.Context(), &Mail{To: "test@example.com", Subject: "Hello", Body: "hi"})
+	assert.NoError(t, err)
+	assert.Contains(t, <-received, "Subject: Hello\r\n")
+}
+
+// A server that never answers must not hold the sender past its deadline.
+func TestSMTPMailerTimeout(t *testing.T) {
+	addr, _ := fakeSMTP(t, false)
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := newTestSMTPMailer(t, addr).Send(ctx, &Mail{To: "test@example.com", Subject: "Hello"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+const testGatewayAddr = "192.0.2.10"
+
+// fromGateway makes req look like the gateway forwarding a call from ip.
+func fromGateway(req *http.Request, ip string) *http.Request {
+	req.RemoteAddr = testGatewayAddr + ":40000"
+	req.Header.Set(models.ClientIPHeader, ip)
+	return req
+}
+
+// Only the gateway may say which address a request comes from.
+func TestTrustGateway(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	assert.NoError(t, TrustGateway(r, []string{testGatewayAddr}))
+	r.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, fromGateway(httptest.NewRequest("GET", "/ip", nil), "203.0.113.7"))
+	assert.Equal(t, "203.0.113.7", w.Body.String())
+
+	req := httptest.NewRequest("GET", "/ip", nil)
+	req.RemoteAddr = "198.51.100.20:40000"
+	req.Header.Set(models.ClientIPHeader, "203.0.113.7")
+	req.Header.Set("X-Forwarded-For", "203.0.113.8")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "198.51.100.20", w.Body.String())
+}
+
+type failingCache struct{ cache.Store }
+
+func (failingCache) Get(ctx context.Context, key string) (string, bool, error) {
+	return "", false, errors.New("cache down")
+}
+
+func TestLoginFailsClosedWithoutThrottling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), NewLoginGuard(failingCache{}))
+
+	r := gin.Default()
+	r.POST("/login", server.Login)
+
+	body, _ := json.Marshal(models.LoginRequest{Email: "test@example.com", Password: "password123"})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest("POST", "/login", bytes.NewBuffer(body))
+	r.ServeHTTP(w, httpReq)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	mockStore.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
+}
+
+func TestLoginThrottling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockStore := new(MockStore)
+	server := NewServer(mockStore, auth.Secret("secret"), newTestMail(), newTestLogins())
+
+	r := gin.Default()
+	assert.NoError(t, TrustGateway(r, []string{testGatewayAddr}))
+	r.POST("/login", server.Login)
+
+	mockStore.On("GetByEmail", mock.Anything, mock.Anything).Return(nil, nil)
+	mockStore.On("RecordLoginAttempt", mock.Anything, mock.Anything).Return(nil)
+
+	login := func(email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.LoginRequest{Email: email, Password: "wrong"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, fromGateway(httptest.NewRequest("POST", "/login", bytes.NewBuffer(body)), "203.0.113.7"))
+		return w
+	}
+
+	// Unknown emails are throttled like real ones.
+	for i := int64(0); i <= accountLoginLimit.free; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("nobody@example.com").Code)
+	}
+	w := login("Nobody@example.com")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Other accounts from the same address are not held off yet.
+	assert.Equal(t, http.StatusUnauthorized, login("other@example.com").Code)
+
+	mockStore.AssertCalled(t, "RecordLoginAttempt", mock.Anything, mock.MatchedBy(func(attempt *LoginAttempt) bool {
+		return attempt.Outcome == LoginThrottled && attempt.IPAddress == "203.0.113.7" && attempt.UserID == nil
+	}))
+	mockStore.AssertCalled(t, "RecordLoginAttempt", mock.Anything, mock.MatchedBy(func(attempt *LoginAttempt) bool {
+		return attempt.Outcome == LoginInvalidCredentials && attempt.Email == "other@example.com"
+	}))
+}
+
+func TestLoginGuard(t *testing.T) {
+	ctx := context.Background()
+	guard := newTestLogins()
+	now := time.Now().Truncate(time.Millisecond)
+	guard.now = func() time.Time { return now }
+
+	// login waits out any block, reserves an attempt and settles it.
+	login := func(email, ip string, ok bool) {
+		wait, err := guard.Wait(ctx, email, ip)
+		assert.NoError(t, err)
+		now = now.Add(wait)
+
+		r, wait, err := guard.Reserve(ctx, email, ip)
+		if assert.NoError(t, err) && assert.Zero(t, wait) {
+			if ok {
+				assert.NoError(t, guard.Succeeded(ctx, r))
+			} else {
+				assert.NoError(t, guard.Failed(ctx, r))
+			}
+		}
+	}
+
+	for i := int64(1); i < accountLoginLimit.lockAfter; i++ {
+		login("user@example.com", "198.51.100.1", false)
+	}
+	wait, err := guard.Wait(ctx, "user@example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, accountLoginLimit.delay(accountLoginLimit.lockAfter-1), wait)
+
+	// A successful login clears the account but not the address, which
+	// keeps its failures and is slowed down after two more.
+	login("user@example.com", "198.51.100.1", true)
+	wait, err = guard.Wait(ctx, "user@example.com", "198.51.100.1")
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+
+	login("someone@example.com", "198.51.100.1", false)
+	login("someone@example.com", "198.51.100.1", false)
+	wait, err = guard.Wait(ctx, "other@example.com", "198.51.100.1")
+	assert.NoError(t, err)
+	assert.Equal(t, loginBaseDelay, wait)
+
+	for i := int64(1); i <= accountLoginLimit.lockAfter; i++ {
+		login("locked@example.com", "", false)
+	}
+	wait, err = guard.Wait(ctx, "locked@example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, accountLoginLimit.lockFor, wait)
+}
+
+func TestLoginGuardConcurrentReservations(t *testing.T) {
+	ctx := context.Background()
+	guard := newTestLogins()
+
+	// Logins that all arrive before any of them has failed still only get
+	// lockAfter attempts between them.
+	const attempts = 30
+	var reserved, held atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, wait, err := guard.Reserve(ctx, "user@example.com", "")
+			assert.NoError(t, err)
+			if r != nil {
+				reserved.Add(1)
+			} else if wait == accountLoginLimit.lockFor {
+				held.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, accountLoginLimit.lockAfter, reserved.Load())
+	assert.Equal(t, attempts-accountLoginLimit.lockAfter, held.Load())
+}
+
+func TestLoginLimitDelay(t *testing.T) {
+	limit := loginLimit{free: 2, lockAfter: 6, lockFor: time.Minute}
+
+	assert.Zero(t, limit.delay(1))
+	assert.Zero(t, limit.delay(2))
+	assert.Equal(t, time.Second, limit.delay(3))
+	assert.Equal(t, 2*time.Second, limit.delay(4))
+	assert.Equal(t, 4*time.Second, limit.delay(5))
+	assert.Equal(t, time.Minute, limit.delay(6))
+	assert.Equal(t, time.Minute, limit.delay(100))
+
+	limit.lockAfter = 1000
+	assert.Equal(t, time.Minute, limit.delay(20))
+	assert.Equal(t, time.Minute, limit.delay(999))
+}
+
+func TestLoadSigningKeysRequiresDir(t *testing.T) {
+	_, err := loadSigningKeys(config.JWTKeyConfig{})
+	assert.ErrorIs(t, err, errNoSigningKeys)
+
+	keys, err := loadSigningKeys(config.JWTKeyConfig{TemporaryKey: true})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, keys.ActiveKeyID())
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+
+// issueTokens signs a new access token for user and pairs it with a refresh
+// token. With a previous token the new one replaces it in the same family;
+// otherwise a new family (one login session) is started.
+func (s *Server) issueTokens(ctx context.Context, user *User, previous *RefreshToken) (*models.AuthResponse, error) {
+	token, err := auth.IssueToken(
+		s.keys,
+		user.ID.String(),
+		user.Email,
+		user.Role,
+		auth.AccessTokenTTL,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	next := &RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  uuid.New(),
+		TokenHash: hash,
+		ExpiresAt: now.Add(auth.RefreshTokenTTL),
+		CreatedAt: now,
+	}
+
+	if previous == nil {
+		if err := s.store.CreateRefreshToken(ctx, next); err != nil {
+			return nil, err
+		}
+	} else {
+		next.FamilyID = previous.FamilyID
+		rotated, err := s.store.RotateRefreshToken(ctx, previous.ID, next)
+		if err != nil {
+			return nil, err
+		}
+		if !rotated {
+			return nil, ErrRefreshTokenInvalid
+		}
+	}
+
+	return &models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		User: &models.UserResponse{
+			ID:            user.ID.String(),
+			Email:         user.Email,
+			FullName:      user.FullName,
+			Role:          user.Role,
+			Phone:         user.Phone,
+			EmailVerified: user.EmailVerifiedAt != nil,
+		},
+	}, nil
+}
+
+// Refresh trades a refresh token for a new access and refresh token pair.
+// Presenting a token that was already rotated means it was copied, so the
+// whole family is revoked and the user has to log in again.
+func (s *Server) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	stored, err := s.store.GetRefreshToken(ctx, auth.HashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		logger.Error("failed to get refresh token", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if stored == nil || time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrRefreshTokenInvalid.Error()})
+		return
+	}
+	if stored.RevokedAt != nil {
+		if err := s.store.RevokeRefreshFamily(ctx, stored.FamilyID); err != nil {
+			logger.Error("failed to revoke refresh token family", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrRefreshTokenInvalid.Error()})
+		return
+	}
+
+	user, err := s.store.GetByID(ctx, stored.UserID)
+	if err != nil {
+		logger.Error("failed to get user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": ErrRefreshTokenInvalid.Error()})
+		return
+	}
+	if user.SuspendedAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": ErrAccountSuspended.Error()})
+		return
+	}
+
+	res, err := s.issueTokens(ctx, user, stored)
+	if errors.Is(err, ErrRefreshTokenInvalid) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logger.Error("failed to issue tokens", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Logout revokes the session the refresh token belongs to, or every session
+// of the user with all set. Unknown tokens are ignored so logging out twice
+// is harmless.
+func (s *Server) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if req.All {
+		if err := s.store.RevokeUserRefreshTokens(ctx, userID); err != nil {
+			logger.Error("failed to revoke refresh tokens", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+		return
+	}
+
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+		return
+	}
+	stored, err := s.store.GetRefreshToken(ctx, auth.HashOpaqueToken(req.RefreshToken))
+	if err != nil {
+		logger.Error("failed to get refresh token", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if stored != nil && stored.UserID == userID {
+		if err := s.store.RevokeRefreshFamily(ctx, stored.FamilyID); err != nil {
+			logger.Error("failed to revoke refresh token family", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// JWKS publishes the public keys that verify access tokens, so other
+// services never need the private ones.
+func (s *Server) JWKS(c *gin.Context) {
+	published, ok := s.keys.(interface{ JWKS() *auth.JWKSet })
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tokens are not signed with published keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, published.JWKS())
+}
@@ -4,20 +4,60 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type IStore interface {
 	Create(ctx context.Context, user *User) error
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*User, error)
 	ListProviders(limit, offset int) ([]*DetailedProvider, error)
 	UpdateProviderStatus(ctx context.Context, userID uuid.UUID, isAvailable bool) error
 	GetProviderStatus(ctx context.Context, userID uuid.UUID) (bool, error)
+	SearchProviders(ctx context.Context, filter *ProviderFilter) ([]*DetailedProvider, int, error)
+	ProviderFacets(ctx context.Context, filter *ProviderFilter) (*ProviderFacets, error)
+	NearbyProviders(ctx context.Context, lat, lng, radiusKm float64, limit int) ([]*DetailedProvider, error)
+	GetProvider(ctx context.Context, providerID uuid.UUID) (*DetailedProvider, error)
+	GetProviderByUserID(ctx context.Context, userID uuid.UUID) (*DetailedProvider, error)
+	UpdateProviderProfile(ctx context.Context, userID uuid.UUID, update *ProviderProfileUpdate) error
+	SearchUsers(ctx context.Context, filter *UserFilter) ([]*User, int, error)
+	SetSuspension(ctx context.Context, userID uuid.UUID, suspended bool, reason string) error
+	BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]*BlockedUser, error)
+	ReportTargetOwner(ctx context.Context, reporterID uuid.UUID, targetType string, targetID uuid.UUID) (*uuid.UUID, error)
+	CreateReport(ctx context.Context, report *Report) error
+	ListReports(ctx context.Context, status string, limit, offset int) ([]*Report, int, error)
+	GetReport(ctx context.Context, id uuid.UUID) (*Report, error)
+	ResolveReport(ctx context.Context, id uuid.UUID, status string, resolvedBy uuid.UUID, note string) (bool, error)
+	CreateRefreshToken(ctx context.Context, token *RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *RefreshToken) (bool, error)
+	RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error
+	CreateAccountToken(ctx context.Context, token *AccountToken) error
+	VerifyEmail(ctx context.Context, tokenHash string) (*uuid.UUID, error)
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (*uuid.UUID, error)
+	RecordLoginAttempt(ctx context.Context, attempt *LoginAttempt) error
 }
 
+const providerColumns = `
+	u.id, sp.id as service_provider_id, u.email, u.full_name, u.role, u.phone,
+	COALESCE(sp.hourly_rate, 0) as hourly_rate,
+	COALESCE(sp.experience_years, 0) as experience_years,
+	COALESCE(sp.location, '') as location,
+	COALESCE(sp.bio, '') as bio,
+	COALESCE(sp.is_available, false) as is_available,
+	COALESCE(sp.rating, 0) as rating,
+	COALESCE(sp.total_reviews, 0) as total_reviews,
+	sp.latitude, sp.longitude
+`
+
 type UserStore struct {
 	db *sqlx.DB
 }
@@ -84,16 +124,20 @@ func (s *UserStore) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	return &user, nil
 }
 
+// GetByIDs returns the users among ids that exist, in no particular order.
+func (s *UserStore) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*User, error) {
+	users := []*User{}
+	query := `SELECT * FROM users WHERE id = ANY($1)`
+	if err := s.db.SelectContext(ctx, &users, query, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 func (s *UserStore) ListProviders(limit, offset int) ([]*DetailedProvider, error) {
 	var providers []*DetailedProvider
 	query := `
-		SELECT u.id, sp.id as service_provider_id, u.email, u.full_name, u.role, u.phone, 
-		       COALESCE(sp.hourly_rate, 0) as hourly_rate,
-		       COALESCE(sp.experience_years, 0) as experience_years,
-		       COALESCE(sp.location, '') as location,
-		       COALESCE(sp.bio, '') as bio,
-		       COALESCE(sp.is_available, false) as is_available,
-		       COALESCE(sp.rating, 0) as rating
+		SELECT ` + providerColumns + `
 		FROM users u
 		LEFT JOIN service_providers sp ON u.id = sp.user_id
 		WHERE u.role = 'provider'
@@ -127,3 +171,467 @@ func (s *UserStore) GetProviderStatus(ctx context.Context, userID uuid.UUID) (bo
 	}
 	return isAvailable, nil
 }
+
+func (s *UserStore) SearchProviders(ctx context.Context, filter *ProviderFilter) ([]*DetailedProvider, int, error) {
+	where, order, args := filter.build()
+
+	var total int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM users u
+		INNER JOIN service_providers sp ON u.id = sp.user_id
+		WHERE ` + where
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	var providers []*DetailedProvider
+	limit := args.add(filter.Limit)
+	offset := args.add(filter.Offset)
+	query := `
+		SELECT ` + providerColumns + `
+		FROM users u
+		INNER JOIN service_providers sp ON u.id = sp.user_id
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + limit + ` OFFSET ` + offset
+	err := s.db.SelectContext(ctx, &providers, query, args...)
+	return providers, total, err
+}
+
+func (s *UserStore) ProviderFacets(ctx context.Context, filter *ProviderFilter) (*ProviderFacets, error) {
+	where, _, args := filter.build()
+	matching := `
+		SELECT sp.id, sp.location, sp.rating, sp.hourly_rate, sp.is_available
+		FROM users u
+		INNER JOIN service_providers sp ON u.id = sp.user_id
+		WHERE ` + where
+
+	var facets ProviderFacets
+	summaryQuery := `
+		SELECT COALESCE(MIN(m.hourly_rate), 0) AS min_rate,
+		       COALESCE(MAX(m.hourly_rate), 0) AS max_rate,
+		       COUNT(*) FILTER (WHERE m.is_available) AS available
+		FROM (` + matching + `) m
+	`
+	if err := s.db.GetContext(ctx, &facets, summaryQuery, args...); err != nil {
+		return nil, err
+	}
+
+	locationQuery := `
+		SELECT m.location AS value, '' AS label, COUNT(*) AS count
+		FROM (` + matching + `) m
+		WHERE COALESCE(m.location, '') <> ''
+		GROUP BY m.location
+		ORDER BY count DESC, m.location
+		LIMIT 20
+	`
+	if err := s.db.SelectContext(ctx, &facets.Locations, locationQuery, args...); err != nil {
+		return nil, err
+	}
+
+	serviceQuery := `
+		SELECT s.id::text AS value, s.name AS label, COUNT(DISTINCT m.id) AS count
+		FROM (` + matching + `) m
+		INNER JOIN provider_services ps ON ps.provider_id = m.id
+		INNER JOIN services s ON s.id = ps.service_id
+		GROUP BY s.id, s.name
+		ORDER BY count DESC, s.name
+	`
+	if err := s.db.SelectContext(ctx, &facets.Services, serviceQuery, args...); err != nil {
+		return nil, err
+	}
+
+	ratingQuery := `
+		SELECT b.min_rating::text AS value, b.min_rating::text || '+' AS label, COUNT(m.id) AS count
+		FROM unnest(ARRAY[4, 3, 2, 1]) AS b(min_rating)
+		LEFT JOIN (` + matching + `) m ON m.rating >= b.min_rating
+		GROUP BY b.min_rating
+		ORDER BY b.min_rating DESC
+	`
+	if err := s.db.SelectContext(ctx, &facets.Ratings, ratingQuery, args...); err != nil {
+		return nil, err
+	}
+
+	return &facets, nil
+}
+
+func (s *UserStore) NearbyProviders(ctx context.Context, lat, lng, radiusKm float64, limit int) ([]*DetailedProvider, error) {
+	box := nearbyBox(lat, lng, radiusKm)
+
+	var providers []*DetailedProvider
+	query := `
+		SELECT * FROM (
+			SELECT ` + providerColumns + `,
+			       haversine_km($1, $2, sp.latitude, sp.longitude) AS distance_km
+			FROM users u
+			INNER JOIN service_providers sp ON u.id = sp.user_id
+			WHERE u.role = 'provider'
+			  AND sp.latitude BETWEEN $4 AND $5
+			  AND sp.longitude BETWEEN $6 AND $7
+		) nearby
+		WHERE distance_km <= $3
+		ORDER BY distance_km, rating DESC
+		LIMIT $8
+	`
+	err := s.db.SelectContext(ctx, &providers, query, lat, lng, radiusKm, box.MinLat, box.MaxLat, box.MinLng, box.MaxLng, limit)
+	return providers, err
+}
+
+func (s *UserStore) GetProvider(ctx context.Context, providerID uuid.UUID) (*DetailedProvider, error) {
+	return s.getProvider(ctx, "sp.id", providerID)
+}
+
+func (s *UserStore) GetProviderByUserID(ctx context.Context, userID uuid.UUID) (*DetailedProvider, error) {
+	return s.getProvider(ctx, "u.id", userID)
+}
+
+func (s *UserStore) getProvider(ctx context.Context, column string, id uuid.UUID) (*DetailedProvider, error) {
+	var provider DetailedProvider
+	query := `
+		SELECT ` + providerColumns + `
+		FROM users u
+		INNER JOIN service_providers sp ON u.id = sp.user_id
+		WHERE u.role = 'provider' AND ` + column + ` = $1
+	`
+	err := s.db.GetContext(ctx, &provider, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+func (s *UserStore) UpdateProviderProfile(ctx context.Context, userID uuid.UUID, update *ProviderProfileUpdate) error {
+	query := `
+		INSERT INTO service_providers (id, user_id, bio, hourly_rate, experience_years, location, latitude, longitude)
+		VALUES (uuid_generate_v4(), $1, $2::text, COALESCE($3::numeric, 0), COALESCE($4::integer, 0), $5::text, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE
+		SET bio = COALESCE($2::text, service_providers.bio),
+		    hourly_rate = COALESCE($3::numeric, service_providers.hourly_rate),
+		    experience_years = COALESCE($4::integer, service_providers.experience_years),
+		    location = COALESCE($5::text, service_providers.location),
+		    latitude = COALESCE($6, service_providers.latitude),
+		    longitude = COALESCE($7, service_providers.longitude)
+	`
+	_, err := s.db.ExecContext(ctx, query, userID, update.Bio, update.HourlyRate, update.ExperienceYears, update.Location, update.Latitude, update.Longitude)
+	return err
+}
+
+func (s *UserStore) SearchUsers(ctx context.Context, filter *UserFilter) ([]*User, int, error) {
+	where, args := filter.build()
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM users u WHERE ` + where
+	if err := s.db.GetContext(ctx, &total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	var users []*User
+	query := `
+		SELECT u.* FROM users u
+		WHERE ` + where + `
+		ORDER BY u.created_at DESC
+		LIMIT ` + args.add(filter.Limit) + ` OFFSET ` + args.add(filter.Offset)
+	err := s.db.SelectContext(ctx, &users, query, args...)
+	return users, total, err
+}
+
+// SetSuspension suspends or reinstates an account. Suspending an already
+// suspended account keeps the original time and replaces the reason.
+func (s *UserStore) SetSuspension(ctx context.Context, userID uuid.UUID, suspended bool, reason string) error {
+	query := `
+		UPDATE users
+		SET suspended_at = CASE WHEN $2 THEN COALESCE(suspended_at, NOW()) ELSE NULL END,
+		    suspension_reason = $3
+		WHERE id = $1
+	`
+	_, err := s.db.ExecContext(ctx, query, userID, suspended, reason)
+	return err
+}
+
+func (s *UserStore) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	query := `
+		INSERT INTO user_blocks (blocker_id, blocked_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+func (s *UserStore) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	_, err := s.db.ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+func (s *UserStore) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]*BlockedUser, error) {
+	var blocks []*BlockedUser
+	query := `
+		SELECT b.blocked_id, u.full_name, b.created_at
+		FROM user_blocks b
+		INNER JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`
+	err := s.db.SelectContext(ctx, &blocks, query, blockerID)
+	return blocks, err
+}
+
+// ReportTargetOwner returns the user responsible for a report target, or nil
+// if the target does not exist or the reporter could not have seen it. Only
+// the receiver of a message can report it; reviews are public.
+func (s *UserStore) ReportTargetOwner(ctx context.Context, reporterID uuid.UUID, targetType string, targetID uuid.UUID) (*uuid.UUID, error) {
+	var query string
+	args := []interface{}{targetID}
+	switch targetType {
+	case "user":
+		query = `SELECT id FROM users WHERE id = $1`
+	case "message":
+		query = `SELECT sender_id FROM messages WHERE id = $1 AND receiver_id = $2 AND kind = 'user'`
+		args = append(args, reporterID)
+	case "review":
+		query = `SELECT client_id FROM reviews WHERE id = $1`
+	default:
+		return nil, nil
+	}
+
+	var owner uuid.UUID
+	if err := s.db.GetContext(ctx, &owner, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &owner, nil
+}
+
+func (s *UserStore) CreateReport(ctx context.Context, report *Report) error {
+	query := `
+		INSERT INTO reports (id, reporter_id, target_type, target_id, reported_user_id, reason, details, status, created_at)
+		VALUES (:id, :reporter_id, :target_type, :target_id, :reported_user_id, :reason, :details, :status, :created_at)
+	`
+	_, err := s.db.NamedExecContext(ctx, query, report)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrReportExists
+	}
+	return err
+}
+
+// ListReports returns reports oldest first so the queue is worked in order.
+// An empty status lists every report.
+func (s *UserStore) ListReports(ctx context.Context, status string, limit, offset int) ([]*Report, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM reports WHERE $1 = '' OR status::text = $1`
+	if err := s.db.GetContext(ctx, &total, countQuery, status); err != nil {
+		return nil, 0, err
+	}
+
+	var reports []*Report
+	query := `
+		SELECT * FROM reports
+		WHERE $1 = '' OR status::text = $1
+		ORDER BY created_at ASC
+		LIMIT $2 OFFSET $3
+	`
+	err := s.db.SelectContext(ctx, &reports, query, status, limit, offset)
+	return reports, total, err
+}
+
+func (s *UserStore) GetReport(ctx context.Context, id uuid.UUID) (*Report, error) {
+	var report Report
+	query := `SELECT * FROM reports WHERE id = $1`
+	err := s.db.GetContext(ctx, &report, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ResolveReport closes a report that is still open and reports whether it
+// was.
+func (s *UserStore) ResolveReport(ctx context.Context, id uuid.UUID, status string, resolvedBy uuid.UUID, note string) (bool, error) {
+	query := `
+		UPDATE reports
+		SET status = $2, resolved_by = $3, resolution_note = $4, resolved_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`
+	res, err := s.db.ExecContext(ctx, query, id, status, resolvedBy, note)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *UserStore) CreateRefreshToken(ctx context.Context, token *RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :family_id, :token_hash, :expires_at, :created_at)
+	`
+	_, err := s.db.NamedExecContext(ctx, query, token)
+	return err
+}
+
+func (s *UserStore) GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	var token RefreshToken
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+	err := s.db.GetContext(ctx, &token, query, tokenHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken revokes oldID and stores next in its place. It reports
+// false without storing anything if oldID was already revoked, which happens
+// when the same token is redeemed twice at once.
+func (s *UserStore) RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *RefreshToken) (bool, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, oldID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :family_id, :token_hash, :expires_at, :created_at)
+	`
+	if _, err := tx.NamedExecContext(ctx, query, next); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *UserStore) RevokeRefreshFamily(ctx context.Context, familyID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, familyID)
+	return err
+}
+
+func (s *UserStore) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// CreateAccountToken stores a one-time token. Earlier unused tokens of the
+// same purpose are retired, so only the newest email link works.
+func (s *UserStore) CreateAccountToken(ctx context.Context, token *AccountToken) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	retire := `UPDATE account_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+	if _, err := tx.ExecContext(ctx, retire, token.UserID, token.Purpose); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO account_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+		VALUES (:id, :user_id, :purpose, :token_hash, :expires_at, :created_at)
+	`
+	if _, err := tx.NamedExecContext(ctx, query, token); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// consumeAccountToken marks a live token used and returns its user, or nil
+// if the token is unknown, used or expired.
+func consumeAccountToken(ctx context.Context, tx *sqlx.Tx, purpose, tokenHash string) (*uuid.UUID, error) {
+	var token AccountToken
+	query := `SELECT * FROM account_tokens WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL FOR UPDATE`
+	if err := tx.GetContext(ctx, &token, query, tokenHash, purpose); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return nil, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE account_tokens SET used_at = NOW() WHERE id = $1`, token.ID); err != nil {
+		return nil, err
+	}
+	return &token.UserID, nil
+}
+
+func (s *UserStore) VerifyEmail(ctx context.Context, tokenHash string) (*uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeAccountToken(ctx, tx, AccountTokenVerifyEmail, tokenHash)
+	if err != nil || userID == nil {
+		return nil, err
+	}
+
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, *userID); err != nil {
+		return nil, err
+	}
+	return userID, tx.Commit()
+}
+
+// ResetPassword sets a new password with a reset token and ends every
+// session of the user. Receiving the link also proves the email address.
+func (s *UserStore) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (*uuid.UUID, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeAccountToken(ctx, tx, AccountTokenResetPassword, tokenHash)
+	if err != nil || userID == nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`
+	if _, err := tx.ExecContext(ctx, query, *userID, passwordHash); err != nil {
+		return nil, err
+	}
+	revoke := `UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.ExecContext(ctx, revoke, *userID); err != nil {
+		return nil, err
+	}
+	return userID, tx.Commit()
+}
+
+func (s *UserStore) RecordLoginAttempt(ctx context.Context, attempt *LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (id, user_id, email, ip_address, outcome, created_at)
+		VALUES (:id, :user_id, :email, :ip_address, :outcome, :created_at)
+	`
+	_, err := s.db.NamedExecContext(ctx, query, attempt)
+	return err
+}
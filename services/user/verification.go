@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"qasynda/shared/pkg/auth"
+	"qasynda/shared/pkg/logger"
+	"qasynda/shared/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AccountTokenVerifyEmail   = "verify_email"
+	AccountTokenResetPassword = "reset_password"
+
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+	mailSendTimeout  = 30 * time.Second
+
+	// A few workers are plenty for account emails; the queue absorbs bursts
+	// and anything beyond it is dropped rather than piling up goroutines.
+	mailWorkers   = 4
+	mailQueueSize = 256
+)
+
+var errMailQueueFull = errors.New("mail queue is full")
+
+type mailJob struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// AccountMailer writes the emails that carry one-time links back to the
+// frontend at appURL. Requests hand their emails to a small pool of workers
+// so they never wait on the mail server.
+type AccountMailer struct {
+	mailer Mailer
+	appURL string
+	queue  chan mailJob
+}
+
+func NewAccountMailer(mailer Mailer, appURL string) *AccountMailer {
+	m := &AccountMailer{
+		mailer: mailer,
+		appURL: strings.TrimSuffix(appURL, "/"),
+		queue:  make(chan mailJob, mailQueueSize),
+	}
+	for range mailWorkers {
+		go m.work()
+	}
+	return m
+}
+
+// enqueue schedules run on a mail worker, or returns errMailQueueFull if too
+// many emails are already waiting. Queued emails are lost if the service
+// stops before they are sent; users can ask for them again.
+func (m *AccountMailer) enqueue(name string, run func(ctx context.Context) error) error {
+	select {
+	case m.queue <- mailJob{name: name, run: run}:
+		return nil
+	default:
+		return errMailQueueFull
+	}
+}
+
+func (m *AccountMailer) work() {
+	for job := range m.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		if err := job.run(ctx); err != nil {
+			logger.Error("failed to send "+job.name, err)
+		}
+		cancel()
+	}
+}
+
+func (m *AccountMailer) link(path, token string) string {
+	return m.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (m *AccountMailer) SendVerification(ctx context.Context, user *User, token string) error {
+	return m.mailer.Send(ctx, &Mail{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\nThe link is valid for 24 hours.\n",
+			user.FullName, m.link("/verify-email", token)),
+	})
+}
+
+func (m *AccountMailer) SendPasswordReset(ctx context.Context, user *User, token string) error {
+	return m.mailer.Send(ctx, &Mail{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new one, open this link:\n\n%s\n\nThe link is valid for 1 hour. If this was not you, you can ignore this email.\n",
+			user.FullName, m.link("/reset-password", token)),
+	})
+}
+
+// newAccountToken stores a one-time token for user and returns the raw value
+// to put in the email.
+func (s *Server) newAccountToken(ctx context.Context, user *User, purpose string, ttl time.Duration) (string, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = s.store.CreateAccountToken(ctx, &AccountToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hash,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	return token, err
+}
+
+func (s *Server) sendVerification(ctx context.Context, user *User) error {
+	token, err := s.newAccountToken(ctx, user, AccountTokenVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.mail.SendVerification(ctx, user, token)
+}
+
+func (s *Server) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	userID, err := s.store.VerifyEmail(c.Request.Context(), auth.HashOpaqueToken(req.Token))
+	if err != nil {
+		logger.Error("failed to verify email", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if userID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func (s *Server) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	ok, err := s.logins.AllowVerificationResend(ctx, userID)
+	if err != nil {
+		logger.Error("failed to check verification resend throttling", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again later"})
+		return
+	}
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(verificationResendWindow)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many verification email requests"})
+		return
+	}
+
+	user, err := s.store.GetByID(ctx, userID)
+	if err != nil {
+		logger.Error("failed to get user", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already verified"})
+		return
+	}
+
+	err = s.mail.enqueue("verification email", func(ctx context.Context) error {
+		return s.sendVerification(ctx, user)
+	})
+	if err != nil {
+		logger.Error("failed to queue verification email", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again later"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "verification email sent"})
+}
+
+// ForgotPassword answers the same way whether or not the email belongs to an
+// account, and sends the email in the background so response times do not
+// give it away either. Requests are limited per email and per client address
+// so the endpoint cannot be used to flood someone's inbox.
+func (s *Server) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
+		return
+	}
+
+	ok, err := s.logins.AllowPasswordReset(c.Request.Context(), req.Email, c.ClientIP())
+	if err != nil {
+		logger.Error("failed to check password reset throttling", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again later"})
+		return
+	}
+	if !ok {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(passwordResetWindow)))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many password reset requests"})
+		return
+	}
+
+	email := req.Email
+	err = s.mail.enqueue("password reset email", func(ctx context.Context) error {
+		user, err := s.store.GetByEmail(ctx, email)
+		if err != nil || user == nil || user.SuspendedAt != nil {
+			return err
+		}
+		token, err := s.newAccountToken(ctx, user, AccountTokenResetPassword, resetPasswordTTL)
+		if err != nil {
+			return err
+		}
+		return s.mail.SendPasswordReset(ctx, user, token)
+	})
+	if err != nil {
+		logger.Error("failed to queue password reset email", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a reset link has been sent"})
+}
+
+func (s *Server) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewValidationError(err))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("failed to hash password", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	userID, err := s.store.ResetPassword(c.Request.Context(), auth.HashOpaqueToken(req.Token), string(hashedPassword))
+	if err != nil {
+		logger.Error("failed to reset password", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if userID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, &models.ResetPasswordResponse{UserID: userID.String()})
+}
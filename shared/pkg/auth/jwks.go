@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwksRefreshInterval = 10 * time.Minute
+	// jwksMinRefetch limits how often an unknown kid can trigger a fetch, so
+	// tokens with made-up kids cannot hammer the user service.
+	jwksMinRefetch = 10 * time.Second
+	jwksTimeout    = 5 * time.Second
+)
+
+type jwksKey struct {
+	alg    string
+	public interface{}
+}
+
+// JWKS verifies tokens against the public keys the user service publishes.
+// Keys are cached and fetched again periodically, or sooner when a token
+// names a kid that is not cached yet, which is how a rotated-in key shows
+// up.
+type JWKS struct {
+	url    string
+	client *http.Client
+
+	// fetchMu lets one caller fetch at a time without holding mu, so
+	// tokens with cached keys are verified while a fetch is in flight.
+	fetchMu     sync.Mutex
+	attemptedAt time.Time
+
+	mu        sync.RWMutex
+	keys      map[string]jwksKey
+	fetchedAt time.Time
+}
+
+func NewJWKS(url string) *JWKS {
+	return &JWKS{url: url, client: &http.Client{Timeout: jwksTimeout}}
+}
+
+func (j *JWKS) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	key, ok, stale := j.lookup(kid)
+	if !ok || stale {
+		if err := j.refresh(); err != nil && !ok {
+			return nil, err
+		}
+		key, ok, _ = j.lookup(kid)
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.alg {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+func (j *JWKS) lookup(kid string) (jwksKey, bool, bool) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	key, ok := j.keys[kid]
+	return key, ok, time.Since(j.fetchedAt) > jwksRefreshInterval
+}
+
+func (j *JWKS) refresh() error {
+	j.fetchMu.Lock()
+	defer j.fetchMu.Unlock()
+	if time.Since(j.attemptedAt) < jwksMinRefetch {
+		return nil
+	}
+	j.attemptedAt = time.Now()
+
+	keys, err := j.fetch()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *JWKS) fetch() (map[string]jwksKey, error) {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKSet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for i := range set.Keys {
+		jwk := &set.Keys[i]
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		public, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = jwksKey{alg: jwk.Alg, public: public}
+	}
+	return keys, nil
+}
@@ -1,16 +1,28 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 )
 
+const (
+	// AccessTokenTTL is kept short because access tokens are checked locally;
+	// clients renew them with a refresh token.
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
@@ -18,7 +30,38 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// Keys looks up the key that verifies a parsed but unverified token. It is
+// implemented by Secret, KeySet and JWKS.
+type Keys interface {
+	verificationKey(token *jwt.Token) (interface{}, error)
+}
+
+// Signer issues tokens that the matching Keys can verify.
+type Signer interface {
+	Keys
+	Sign(claims *Claims) (string, error)
+}
+
+// Secret signs and verifies HS256 tokens with a shared secret.
+type Secret string
+
+func (s Secret) Sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s))
+}
+
+func (s Secret) verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("unexpected signing method")
+	}
+	return []byte(s), nil
+}
+
 func GenerateToken(userID, email, role, secret string, expiration time.Duration) (string, error) {
+	return IssueToken(Secret(secret), userID, email, role, expiration)
+}
+
+func IssueToken(signer Signer, userID, email, role string, expiration time.Duration) (string, error) {
 	claims := &Claims{
 		UserID: userID,
 		Email:  email,
@@ -27,20 +70,19 @@ func GenerateToken(userID, email, role, secret string, expiration time.Duration)
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return signer.Sign(claims)
 }
 
 func ValidateToken(tokenString, secret string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+	return ParseToken(tokenString, Secret(secret))
+}
+
+func ParseToken(tokenString string, keys Keys) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.verificationKey)
 
 	if err != nil {
 		return nil, ErrInvalidToken
@@ -52,3 +94,19 @@ func ValidateToken(tokenString, secret string) (*Claims, error) {
 
 	return nil, ErrInvalidToken
 }
+
+// NewOpaqueToken returns a random token to hand to the user and the hash to
+// store in its place, as used for refresh and one-time email tokens.
+func NewOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashOpaqueToken(token), nil
+}
+
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,9 +1,20 @@
 package auth
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -67,3 +78,195 @@ func TestWrongSecret(t *testing.T) {
 	assert.Nil(t, claims)
 	assert.Equal(t, ErrInvalidToken, err)
 }
+
+func TestTokenID(t *testing.T) {
+	first, err := GenerateToken("user-123", "test@example.com", "client", "test-secret", time.Hour)
+	assert.NoError(t, err)
+	second, err := GenerateToken("user-123", "test@example.com", "client", "test-secret", time.Hour)
+	assert.NoError(t, err)
+
+	a, err := ValidateToken(first, "test-secret")
+	assert.NoError(t, err)
+	b, err := ValidateToken(second, "test-secret")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, a.ID)
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func TestOpaqueToken(t *testing.T) {
+	token, hash, err := NewOpaqueToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, HashOpaqueToken(token))
+
+	other, _, err := NewOpaqueToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func newEd25519Key(t *testing.T, id string) *SigningKey {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	key, err := NewSigningKey(id, private)
+	assert.NoError(t, err)
+	return key
+}
+
+func newRSAKey(t *testing.T, id string) *SigningKey {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	key, err := NewSigningKey(id, private)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "2025-01"), newEd25519Key(t, "2025-02")
+
+	before, err := NewKeySet("2025-01", oldKey)
+	assert.NoError(t, err)
+	oldToken, err := IssueToken(before, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+
+	// After the rotation the old key is still published, so its tokens keep
+	// working while new tokens are signed with the new key.
+	after, err := NewKeySet("2025-02", oldKey, newKey)
+	assert.NoError(t, err)
+	newToken, err := IssueToken(after, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		claims, err := ParseToken(token, after)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-123", claims.UserID)
+	}
+
+	_, err = ParseToken(newToken, before)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = NewKeySet("missing", oldKey)
+	assert.Error(t, err)
+}
+
+func TestKeySetRejectsOtherAlgorithms(t *testing.T) {
+	key := newEd25519Key(t, "k1")
+	keys, err := NewKeySet("k1", key)
+	assert.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "user-123"})
+	forged.Header["kid"] = "k1"
+	token, err := forged.SignedString([]byte("guess"))
+	assert.NoError(t, err)
+
+	_, err = ParseToken(token, keys)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	hmacToken, err := GenerateToken("user-123", "test@example.com", "client", "test-secret", time.Hour)
+	assert.NoError(t, err)
+	_, err = ParseToken(hmacToken, keys)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"2025-01", "2025-02"} {
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		assert.NoError(t, err)
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600))
+	}
+
+	keys, err := LoadKeySet(dir, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-02", keys.ActiveKeyID())
+	assert.Len(t, keys.JWKS().Keys, 2)
+
+	keys, err = LoadKeySet(dir, "2025-01")
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-01", keys.ActiveKeyID())
+
+	_, err = LoadKeySet(t.TempDir(), "")
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	keys, err := NewKeySet("k1", newRSAKey(t, "k1"), newEd25519Key(t, "k2"))
+	assert.NoError(t, err)
+
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	defer srv.Close()
+
+	remote := NewJWKS(srv.URL)
+	token, err := IssueToken(keys, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		claims, err := ParseToken(token, remote)
+		assert.NoError(t, err)
+		assert.Equal(t, "user-123", claims.UserID)
+	}
+	assert.Equal(t, 1, fetches)
+
+	other, err := NewKeySet("k3", newEd25519Key(t, "k3"))
+	assert.NoError(t, err)
+	unknown, err := IssueToken(other, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+	_, err = ParseToken(unknown, remote)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+// A slow fetch for an unknown kid must not hold up tokens whose keys are
+// already cached.
+func TestJWKSFetchDoesNotBlockCachedKeys(t *testing.T) {
+	keys, err := NewKeySet("k1", newEd25519Key(t, "k1"))
+	assert.NoError(t, err)
+
+	fetching, release := make(chan struct{}), make(chan struct{})
+	var fetches int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if fetches > 1 {
+			close(fetching)
+			<-release
+		}
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	remote := NewJWKS(srv.URL)
+	token, err := IssueToken(keys, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+	_, err = ParseToken(token, remote)
+	assert.NoError(t, err)
+
+	other, err := NewKeySet("k2", newEd25519Key(t, "k2"))
+	assert.NoError(t, err)
+	unknown, err := IssueToken(other, "user-123", "test@example.com", "client", time.Hour)
+	assert.NoError(t, err)
+
+	remote.fetchMu.Lock()
+	remote.attemptedAt = time.Time{}
+	remote.fetchMu.Unlock()
+	go ParseToken(unknown, remote)
+	<-fetching
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ParseToken(token, remote)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("cached key waited for the jwks fetch")
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSABits = 2048
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is a private key with the kid it is published under.
+type SigningKey struct {
+	ID     string
+	key    crypto.Signer
+	method jwt.SigningMethod
+}
+
+// NewSigningKey accepts RSA keys, which sign RS256, and Ed25519 keys, which
+// sign EdDSA.
+func NewSigningKey(id string, key crypto.Signer) (*SigningKey, error) {
+	if id == "" {
+		return nil, errors.New("signing key needs an id")
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key %q is shorter than %d bits", id, minRSABits)
+		}
+		return &SigningKey{ID: id, key: k, method: jwt.SigningMethodRS256}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, key: k, method: jwt.SigningMethodEdDSA}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T for %q", key, id)
+	}
+}
+
+// KeySet holds every key the user service publishes. One of them signs new
+// tokens; the others keep verifying tokens issued before a rotation.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeySet(activeID string, keys ...*SigningKey) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	set.active = set.keys[activeID]
+	if set.active == nil {
+		return nil, fmt.Errorf("active key %q not found", activeID)
+	}
+	return set, nil
+}
+
+// LoadKeySet reads every *.pem private key in dir, using the file name as
+// the kid. Without activeID the last key by name signs, so naming keys by
+// date rotates to the newest one.
+func LoadKeySet(dir, activeID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.pem keys in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := parsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key, err := NewSigningKey(strings.TrimSuffix(filepath.Base(path), ".pem"), signer)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if activeID == "" {
+		activeID = keys[len(keys)-1].ID
+	}
+	return NewKeySet(activeID, keys...)
+}
+
+// GenerateKeySet makes a single throwaway Ed25519 key. Tokens signed with it
+// stop verifying when the process restarts.
+func GenerateKeySet() (*KeySet, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	key, err := NewSigningKey("ephemeral-"+hex.EncodeToString(id), private)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key.ID, key)
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func (s *KeySet) ActiveKeyID() string {
+	return s.active.ID
+}
+
+func (s *KeySet) Sign(claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.method, claims)
+	token.Header["kid"] = s.active.ID
+	return token.SignedString(s.active.key)
+}
+
+func (s *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.key.Public(), nil
+}
+
+// JWK is the public half of a signing key as published in a JWKS document
+// (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys of the set, ordered by kid.
+func (s *KeySet) JWKS() *JWKSet {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := &JWKSet{Keys: make([]JWK, 0, len(ids))}
+	for _, id := range ids {
+		key := s.keys[id]
+		jwk := JWK{Kid: id, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// publicKey turns a published JWK back into a key jwt can verify with.
+func (k *JWK) publicKey() (interface{}, error) {
+	switch {
+	case k.Kty == "RSA" && k.Alg == jwt.SigningMethodRS256.Alg():
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if public.N.BitLen() < minRSABits {
+			return nil, errors.New("rsa key too short")
+		}
+		return public, nil
+	case k.Kty == "OKP" && k.Crv == "Ed25519" && k.Alg == jwt.SigningMethodEdDSA.Alg():
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key %s/%s", k.Kty, k.Alg)
+	}
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"qasynda/shared/pkg/cache"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationList(t *testing.T) {
+	ctx := context.Background()
+	list := NewRevocationList(cache.NewMemory())
+
+	claims := &Claims{UserID: "user-1"}
+	claims.ID = "token-1"
+	claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	revoked, err := list.IsRevoked(ctx, claims)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, list.RevokeToken(ctx, "token-1", time.Now().Add(time.Minute)))
+	revoked, err = list.IsRevoked(ctx, claims)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	other := &Claims{UserID: "user-1"}
+	other.ID = "token-2"
+	other.IssuedAt = jwt.NewNumericDate(time.Now())
+	assert.NoError(t, list.RevokeUser(ctx, "user-1"))
+
+	// Issue times are whole seconds, so a token from the same second as the
+	// revocation is blocked too.
+	revoked, err = list.IsRevoked(ctx, other)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	other.IssuedAt = jwt.NewNumericDate(time.Now().Add(2 * time.Second))
+	revoked, err = list.IsRevoked(ctx, other)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+}
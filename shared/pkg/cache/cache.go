@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"qasynda/shared/pkg/logger"
+)
+
+// Store is the small key/value surface the services need for short-lived
+// shared state. Keys expire after their ttl.
+type Store interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// Incr adds one to a counter and returns the new value. A counter that
+	// did not exist starts at one and expires after ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Decr takes one off a counter without touching its expiry. A counter
+	// that does not exist is left alone and reads as zero.
+	Decr(ctx context.Context, key string) (int64, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// New connects to the Redis server at redisURL and fails if it cannot be
+// reached, since state kept in one process would let other instances disagree
+// about it. An empty redisURL selects the in-process store, which is only
+// correct while a single instance of the service runs.
+func New(redisURL string) (Store, error) {
+	if redisURL == "" {
+		logger.Info("REDIS_URL not set, using in-memory store")
+		return NewMemory(), nil
+	}
+
+	r, err := NewRedis(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err := r.Ping(ctx); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	logger.Info("using redis store", "addr", r.Addr())
+	return r, nil
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	assert.NoError(t, m.Set(ctx, "live", "1", time.Hour))
+	assert.NoError(t, m.Set(ctx, "gone", "1", -time.Second))
+
+	value, ok, err := m.Get(ctx, "live")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+
+	_, ok, err = m.Get(ctx, "gone")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = m.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestNewRedisURL(t *testing.T) {
+	r, err := NewRedis("localhost:6379")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:6379", r.Addr())
+
+	r, err = NewRedis("redis://:pass@cache/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "cache:6379", r.Addr())
+	assert.Equal(t, "pass", r.client.Options().Password)
+	assert.Equal(t, 2, r.client.Options().DB)
+
+	_, err = NewRedis("http://cache:6379")
+	assert.Error(t, err)
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	store, err := New(srv.Addr())
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set(ctx, "name", "text", time.Minute))
+	value, ok, err := store.Get(ctx, "name")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "text", value)
+
+	_, ok, err = store.Get(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := store.Incr(ctx, "hits", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+	assert.Equal(t, time.Minute, srv.TTL("hits"))
+
+	n, err := store.Decr(ctx, "hits")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, time.Minute, srv.TTL("hits"))
+	n, err = store.Decr(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	assert.False(t, srv.Exists("missing"))
+
+	assert.NoError(t, store.Delete(ctx, "hits", "name"))
+	assert.False(t, srv.Exists("hits"))
+	assert.False(t, srv.Exists("name"))
+
+	_, err = store.Incr(ctx, "name", time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(ctx, "text", "abc", time.Minute))
+	_, err = store.Incr(ctx, "text", time.Minute)
+	assert.Error(t, err)
+}
+
+// A configured Redis that cannot be reached is an error, not a silent switch
+// to per-process state.
+func TestNewRequiresRedis(t *testing.T) {
+	srv := miniredis.RunT(t)
+	addr := srv.Addr()
+	srv.Close()
+
+	_, err := New(addr)
+	assert.Error(t, err)
+
+	store, err := New("")
+	assert.NoError(t, err)
+	assert.IsType(t, &Memory{}, store)
+}
+
+func TestMemoryCounter(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	for want := int64(1); want <= 3; want++ {
+		n, err := m.Incr(ctx, "hits", time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+
+	n, err := m.Decr(ctx, "hits")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = m.Decr(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	_, ok, _ := m.Get(ctx, "missing")
+	assert.False(t, ok)
+
+	assert.NoError(t, m.Delete(ctx, "hits", "missing"))
+	n, err = m.Incr(ctx, "hits", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = m.Incr(ctx, "short", -time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = m.Incr(ctx, "short", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	assert.NoError(t, m.Set(ctx, "name", "text", time.Hour))
+	_, err = m.Incr(ctx, "name", time.Hour)
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const memorySweepEvery = 1024
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.put(key, memoryEntry{value: value, expiresAt: time.Now().Add(ttl)})
+	return nil
+}
+
+func (m *Memory) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := m.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		entry = memoryEntry{value: "0", expiresAt: now.Add(ttl)}
+	}
+	n, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache: %s is not a counter", key)
+	}
+	n++
+	entry.value = strconv.FormatInt(n, 10)
+	m.put(key, entry)
+	return n, nil
+}
+
+func (m *Memory) Decr(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cache: %s is not a counter", key)
+	}
+	n--
+	entry.value = strconv.FormatInt(n, 10)
+	m.entries[key] = entry
+	return n, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// put stores an entry with m.mu held. Expired keys are otherwise only
+// dropped when read, so it sweeps now and then to keep keys that are never
+// read again from piling up.
+func (m *Memory) put(key string, entry memoryEntry) {
+	m.entries[key] = entry
+
+	m.writes++
+	if m.writes%memorySweepEvery == 0 {
+		now := time.Now()
+		for k, e := range m.entries {
+			if !now.Before(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	dialTimeout    = 2 * time.Second
+	commandTimeout = 2 * time.Second
+)
+
+// Redis keeps Store in a Redis server so every instance of a service sees the
+// same state.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis accepts either host:port or a redis://[:password@]host:port[/db]
+// URL. It does not connect until the first command.
+func NewRedis(redisURL string) (*Redis, error) {
+	opts := &redis.Options{Addr: redisURL}
+	if strings.Contains(redisURL, "://") {
+		var err error
+		if opts, err = redis.ParseURL(redisURL); err != nil {
+			return nil, err
+		}
+	}
+	opts.DialTimeout = dialTimeout
+	opts.ReadTimeout = commandTimeout
+	opts.WriteTimeout = commandTimeout
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Addr() string {
+	return r.client.Options().Addr
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, max(ttl, time.Millisecond)).Err()
+}
+
+// incrScript sets the expiry in the same step as the first increment, so a
+// counter can never be left behind without one.
+var incrScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	return incrScript.Run(ctx, r.client, []string{key}, max(ttl, time.Millisecond).Milliseconds()).Int64()
+}
+
+// decrScript only decrements a counter that exists, since DECR would create
+// one without an expiry.
+var decrScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+return redis.call('DECR', KEYS[1])
+`)
+
+func (r *Redis) Decr(ctx context.Context, key string) (int64, error) {
+	return decrScript.Run(ctx, r.client, []string{key}).Int64()
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
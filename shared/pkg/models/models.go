@@ -1,29 +1,72 @@
 package models
 
+// ClientIPHeader carries the caller's address from the gateway to the user
+// service, which only believes it on connections from the gateway.
+const ClientIPHeader = "X-Real-IP"
+
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	FullName string `json:"full_name"`
-	Role     string `json:"role"`
-	Phone    string `json:"phone"`
+	Email    string `json:"email" binding:"required,max=255,email"`
+	Password string `json:"password" binding:"required,password"`
+	FullName string `json:"full_name" binding:"required,max=255"`
+	Role     string `json:"role" binding:"required,signup_role"`
+	Phone    string `json:"phone" binding:"omitempty,e164"`
 }
 
 type UserResponse struct {
-	ID       string `json:"id"`
-	Email    string `json:"email"`
-	FullName string `json:"full_name"`
-	Role     string `json:"role"`
-	Phone    string `json:"phone"`
+	ID            string `json:"id"`
+	Email         string `json:"email"`
+	FullName      string `json:"full_name"`
+	Role          string `json:"role"`
+	Phone         string `json:"phone"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// UsersResponse answers a batch lookup; ids that match no user are left out.
+type UsersResponse struct {
+	Users []*UserResponse `json:"users"`
 }
 
 type AuthResponse struct {
-	Token string        `json:"token"`
-	User  *UserResponse `json:"user"`
+	Token        string        `json:"token"`
+	RefreshToken string        `json:"refresh_token"`
+	ExpiresIn    int           `json:"expires_in"`
+	User         *UserResponse `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+type ResetPasswordResponse struct {
+	UserID string `json:"user_id"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+	UserID       string `json:"user_id"`
 }
 
 type ValidateTokenRequest struct {
@@ -47,13 +90,74 @@ type ProviderResponse struct {
 	Bio             string        `json:"bio"`
 	IsAvailable     bool          `json:"is_available"`
 	Rating          float64       `json:"rating"`
+	TotalReviews    int           `json:"total_reviews"`
 	ProviderID      string        `json:"provider_id"`
+	Latitude        *float64      `json:"latitude,omitempty"`
+	Longitude       *float64      `json:"longitude,omitempty"`
+	DistanceKm      *float64      `json:"distance_km,omitempty"`
 }
 
 type ListProvidersResponse struct {
 	Providers []*ProviderResponse `json:"providers"`
 }
 
+// UpdateProviderProfileRequest changes only the fields that are present.
+// Latitude and longitude have to be sent together.
+type UpdateProviderProfileRequest struct {
+	Bio             *string  `json:"bio"`
+	HourlyRate      *float64 `json:"hourly_rate"`
+	ExperienceYears *int     `json:"experience_years"`
+	Location        *string  `json:"location"`
+	Latitude        *float64 `json:"latitude"`
+	Longitude       *float64 `json:"longitude"`
+}
+
+type NearbyProvidersRequest struct {
+	Latitude  float64 `form:"lat" json:"lat"`
+	Longitude float64 `form:"lng" json:"lng"`
+	RadiusKm  float64 `form:"radius_km" json:"radius_km"`
+	Limit     int     `form:"limit" json:"limit"`
+}
+
+type SearchProvidersRequest struct {
+	Query         string   `form:"q" json:"q"`
+	Location      string   `form:"location" json:"location"`
+	MinRating     *float64 `form:"min_rating" json:"min_rating"`
+	MinRate       *float64 `form:"min_rate" json:"min_rate"`
+	MaxRate       *float64 `form:"max_rate" json:"max_rate"`
+	Available     *bool    `form:"available" json:"available"`
+	MinExperience *int     `form:"min_experience" json:"min_experience"`
+	ServiceID     string   `form:"service_id" json:"service_id"`
+	Sort          string   `form:"sort" json:"sort"`
+	Limit         int      `form:"limit" json:"limit"`
+	Offset        int      `form:"offset" json:"offset"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+type PriceFacet struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type ProviderFacets struct {
+	Locations []*FacetCount `json:"locations"`
+	Services  []*FacetCount `json:"services"`
+	Ratings   []*FacetCount `json:"ratings"`
+	Price     *PriceFacet   `json:"price"`
+	Available int           `json:"available"`
+}
+
+type SearchProvidersResponse struct {
+	Providers []*ProviderResponse `json:"providers"`
+	Total     int                 `json:"total"`
+	Facets    *ProviderFacets     `json:"facets"`
+}
+
 type CreateServiceRequest struct {
 	UserID      string  `json:"user_id"`
 	Title       string  `json:"title"`
@@ -66,6 +170,8 @@ type ServiceResponse struct {
 	ID          string  `json:"id"`
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	IconURL     string  `json:"icon_url,omitempty"`
 	Price       float64 `json:"price"`
 	ProviderID  string  `json:"provider_id"`
 }
@@ -78,16 +184,45 @@ type GetServicesResponse struct {
 	Services []*ServiceResponse `json:"services"`
 }
 
+type OfferServiceRequest struct {
+	ServiceID   string   `json:"service_id"`
+	UserID      string   `json:"user_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Price       *float64 `json:"price"`
+}
+
+type ServiceOfferingResponse struct {
+	ServiceID    string  `json:"service_id"`
+	ProviderID   string  `json:"provider_id"`
+	UserID       string  `json:"user_id,omitempty"`
+	FullName     string  `json:"full_name,omitempty"`
+	Title        string  `json:"title"`
+	Description  string  `json:"description"`
+	Price        float64 `json:"price"`
+	Location     string  `json:"location,omitempty"`
+	Rating       float64 `json:"rating"`
+	TotalReviews int     `json:"total_reviews"`
+	IsAvailable  bool    `json:"is_available"`
+}
+
+type ListServiceProvidersResponse struct {
+	Providers []*ServiceOfferingResponse `json:"providers"`
+}
+
 type CreateBookingRequest struct {
-	ServiceID     string `json:"service_id"`
-	UserID        string `json:"user_id"`
-	ProviderID    string `json:"provider_id"`
-	ScheduledTime string `json:"scheduled_time"`
+	ServiceID     string  `json:"service_id"`
+	UserID        string  `json:"user_id"`
+	ProviderID    string  `json:"provider_id"`
+	ScheduledTime string  `json:"scheduled_time"`
+	DurationHours float64 `json:"duration_hours"`
 }
 
 type BookingResponse struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
+	ID         string  `json:"id"`
+	Status     string  `json:"status"`
+	TotalPrice float64 `json:"total_price,omitempty"`
+	Currency   string  `json:"currency,omitempty"`
 }
 
 type ListBookingsRequest struct {
@@ -96,14 +231,18 @@ type ListBookingsRequest struct {
 }
 
 type BookingDetails struct {
-	ID             string `json:"id"`
-	ServiceID      string `json:"service_id"`
-	ClientID       string `json:"client_id"`
-	ProviderID     string `json:"provider_id"`
-	Status         string `json:"status"`
-	ScheduledTime  string `json:"scheduled_time"`
-	ServiceTitle   string `json:"service_title"`
-	OtherPartyName string `json:"other_party_name"`
+	ID             string  `json:"id"`
+	ServiceID      string  `json:"service_id"`
+	ClientID       string  `json:"client_id"`
+	ProviderID     string  `json:"provider_id"`
+	ProviderUserID string  `json:"provider_user_id,omitempty"`
+	Status         string  `json:"status"`
+	ScheduledTime  string  `json:"scheduled_time"`
+	ServiceTitle   string  `json:"service_title"`
+	OtherPartyName string  `json:"other_party_name"`
+	DurationHours  float64 `json:"duration_hours"`
+	TotalPrice     float64 `json:"total_price"`
+	Currency       string  `json:"currency"`
 }
 
 type ListBookingsResponse struct {
@@ -116,19 +255,123 @@ type UpdateBookingStatusRequest struct {
 	UserID    string `json:"user_id"`
 }
 
+type TransitionErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+type CreateReviewRequest struct {
+	BookingID string `json:"booking_id"`
+	UserID    string `json:"user_id"`
+	Rating    int    `json:"rating"`
+	Comment   string `json:"comment"`
+}
+
+type ReviewResponse struct {
+	ID         string `json:"id"`
+	BookingID  string `json:"booking_id"`
+	ProviderID string `json:"provider_id"`
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name,omitempty"`
+	Rating     int    `json:"rating"`
+	Comment    string `json:"comment"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ListReviewsRequest struct {
+	ProviderID string `json:"provider_id"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type ListReviewsResponse struct {
+	Reviews []*ReviewResponse `json:"reviews"`
+	Total   int               `json:"total"`
+}
+
+type WorkingHours struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+type SetWorkingHoursRequest struct {
+	UserID string          `json:"user_id"`
+	Hours  []*WorkingHours `json:"hours"`
+}
+
+type WorkingHoursResponse struct {
+	ProviderID string          `json:"provider_id"`
+	Hours      []*WorkingHours `json:"hours"`
+}
+
+type CreateBlackoutRequest struct {
+	UserID   string `json:"user_id"`
+	StartsAt string `json:"starts_at"`
+	EndsAt   string `json:"ends_at"`
+	Reason   string `json:"reason"`
+}
+
+type BlackoutResponse struct {
+	ID         string `json:"id"`
+	ProviderID string `json:"provider_id"`
+	StartsAt   string `json:"starts_at"`
+	EndsAt     string `json:"ends_at"`
+	Reason     string `json:"reason"`
+}
+
+type ListBlackoutsResponse struct {
+	Blackouts []*BlackoutResponse `json:"blackouts"`
+}
+
+type GetAvailabilityRequest struct {
+	ProviderID string `json:"provider_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+type TimeSlot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type AvailabilityResponse struct {
+	ProviderID string      `json:"provider_id"`
+	From       string      `json:"from"`
+	To         string      `json:"to"`
+	Slots      []*TimeSlot `json:"slots"`
+}
+
 type GetHistoryRequest struct {
-	UserID1 string `json:"user_id_1"`
-	UserID2 string `json:"user_id_2"`
-	Limit   int    `json:"limit"`
-	Offset  int    `json:"offset"`
+	UserID1   string `json:"user_id_1"`
+	UserID2   string `json:"user_id_2"`
+	BookingID string `json:"booking_id"`
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
 }
 
 type Message struct {
-	ID         string `json:"id"`
-	SenderID   string `json:"sender_id"`
-	ReceiverID string `json:"receiver_id"`
-	Content    string `json:"content"`
-	Timestamp  string `json:"timestamp"`
+	ID          string        `json:"id"`
+	SenderID    string        `json:"sender_id"`
+	ReceiverID  string        `json:"receiver_id"`
+	Content     string        `json:"content"`
+	BookingID   string        `json:"booking_id,omitempty"`
+	Kind        string        `json:"kind,omitempty"`
+	Status      string        `json:"status,omitempty"`
+	EditedAt    string        `json:"edited_at,omitempty"`
+	DeletedAt   string        `json:"deleted_at,omitempty"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	Timestamp   string        `json:"timestamp"`
+}
+
+type Attachment struct {
+	ID          string `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	SizeBytes   int64  `json:"size_bytes"`
+	URL         string `json:"url"`
 }
 
 type GetHistoryResponse struct {
@@ -145,3 +388,193 @@ type SendMessageResponse struct {
 	ID        string `json:"id"`
 	Timestamp string `json:"timestamp"`
 }
+
+type MarkReadRequest struct {
+	UserID string `json:"user_id"`
+	PeerID string `json:"peer_id"`
+}
+
+type EditMessageRequest struct {
+	UserID  string `json:"user_id"`
+	Content string `json:"content"`
+}
+
+type MarkReadResponse struct {
+	Updated int    `json:"updated"`
+	ReadAt  string `json:"read_at,omitempty"`
+}
+
+type UnreadConversation struct {
+	PeerID        string `json:"peer_id"`
+	Count         int    `json:"count"`
+	LastMessageAt string `json:"last_message_at"`
+}
+
+type UnreadCountsResponse struct {
+	Total         int                   `json:"total"`
+	Conversations []*UnreadConversation `json:"conversations"`
+}
+
+type ListConversationsRequest struct {
+	UserID string `json:"user_id"`
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+}
+
+type ConversationResponse struct {
+	PeerID        string        `json:"peer_id"`
+	Peer          *UserResponse `json:"peer,omitempty"`
+	LastMessage   *Message      `json:"last_message"`
+	LastMessageAt string        `json:"last_message_at"`
+	UnreadCount   int           `json:"unread_count"`
+}
+
+type ListConversationsResponse struct {
+	Conversations []*ConversationResponse `json:"conversations"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+type DeadLetterResponse struct {
+	MessageID string   `json:"message_id"`
+	Message   *Message `json:"message,omitempty"`
+	Body      string   `json:"body,omitempty"`
+	Attempts  int      `json:"attempts"`
+	LastError string   `json:"last_error"`
+	FailedAt  string   `json:"failed_at,omitempty"`
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []*DeadLetterResponse `json:"dead_letters"`
+	Total       int                   `json:"total"`
+}
+
+type ReplayDeadLettersRequest struct {
+	MessageIDs []string `json:"message_ids"`
+	Limit      int      `json:"limit"`
+}
+
+type ReplayDeadLettersResponse struct {
+	Replayed int `json:"replayed"`
+}
+
+const (
+	MarketplaceEventsExchange = "marketplace.events"
+	BookingStatusChangedKey   = "booking.status_changed"
+)
+
+type BookingStatusChangedEvent struct {
+	BookingID      string `json:"booking_id"`
+	ClientID       string `json:"client_id"`
+	ProviderUserID string `json:"provider_user_id"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+	ChangedBy      string `json:"changed_by"`
+	ChangedAt      string `json:"changed_at"`
+}
+
+type BlockUserRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type BlockedUser struct {
+	UserID    string `json:"user_id"`
+	FullName  string `json:"full_name"`
+	BlockedAt string `json:"blocked_at"`
+}
+
+type ListBlocksResponse struct {
+	Blocks []*BlockedUser `json:"blocks"`
+}
+
+type CreateReportRequest struct {
+	ReporterID string `json:"reporter_id"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type ReportResponse struct {
+	ID             string `json:"id"`
+	ReporterID     string `json:"reporter_id"`
+	TargetType     string `json:"target_type"`
+	TargetID       string `json:"target_id"`
+	ReportedUserID string `json:"reported_user_id,omitempty"`
+	Reason         string `json:"reason"`
+	Details        string `json:"details,omitempty"`
+	Status         string `json:"status"`
+	ResolutionNote string `json:"resolution_note,omitempty"`
+	ResolvedBy     string `json:"resolved_by,omitempty"`
+	ResolvedAt     string `json:"resolved_at,omitempty"`
+	CreatedAt      string `json:"created_at"`
+}
+
+type ListReportsRequest struct {
+	Status string `json:"status"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type ListReportsResponse struct {
+	Reports []*ReportResponse `json:"reports"`
+	Total   int               `json:"total"`
+}
+
+type ResolveReportRequest struct {
+	Status     string `json:"status"`
+	Note       string `json:"note"`
+	ResolvedBy string `json:"resolved_by"`
+}
+
+type ListUsersRequest struct {
+	Query     string `json:"q"`
+	Role      string `json:"role"`
+	Suspended *bool  `json:"suspended"`
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+}
+
+type AdminUserResponse struct {
+	ID               string `json:"id"`
+	Email            string `json:"email"`
+	FullName         string `json:"full_name"`
+	Role             string `json:"role"`
+	Phone            string `json:"phone"`
+	SuspendedAt      string `json:"suspended_at,omitempty"`
+	SuspensionReason string `json:"suspension_reason,omitempty"`
+	CreatedAt        string `json:"created_at"`
+}
+
+type ListUsersResponse struct {
+	Users []*AdminUserResponse `json:"users"`
+	Total int                  `json:"total"`
+}
+
+type SetSuspensionRequest struct {
+	Suspended bool   `json:"suspended"`
+	Reason    string `json:"reason"`
+	AdminID   string `json:"admin_id"`
+}
+
+type UpdateServiceRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	IconURL     *string `json:"icon_url"`
+	Category    *string `json:"category"`
+}
+
+type AdminListBookingsRequest struct {
+	Status     string `json:"status"`
+	ClientID   string `json:"client_id"`
+	ProviderID string `json:"provider_id"`
+	ServiceID  string `json:"service_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+type AdminListBookingsResponse struct {
+	Bookings []*BookingDetails `json:"bookings"`
+	Total    int               `json:"total"`
+}
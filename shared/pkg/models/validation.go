@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything after 72 bytes.
+	maxPasswordBytes = 72
+)
+
+// SignupRoles are the roles a user can pick when registering; admins are
+// only ever made by hand.
+var SignupRoles = []string{"client", "provider"}
+
+// RegisterValidators adds the password and signup_role rules to gin's
+// validator and makes field errors use JSON names. Every service that binds
+// these requests calls it once at startup so they all enforce the same rules.
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin validator is not go-playground/validator")
+	}
+	v.RegisterTagNameFunc(jsonFieldName)
+	if err := v.RegisterValidation("password", validatePassword); err != nil {
+		return err
+	}
+	return v.RegisterValidation("signup_role", validateSignupRole)
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// validatePassword requires 8 to 72 bytes with at least one letter and one
+// digit.
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len([]rune(password)) < minPasswordLength || len(password) > maxPasswordBytes {
+		return false
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return letter && digit
+}
+
+func validateSignupRole(fl validator.FieldLevel) bool {
+	role := fl.Field().String()
+	for _, allowed := range SignupRoles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the 400 body for a request that failed binding.
+// Fields is empty when the body could not be decoded at all.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+func NewValidationError(err error) *ValidationErrorResponse {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return &ValidationErrorResponse{Error: "invalid request body: " + err.Error()}
+	}
+
+	res := &ValidationErrorResponse{Error: "validation failed", Fields: make([]FieldError, 0, len(fieldErrs))}
+	for _, fe := range fieldErrs {
+		res.Fields = append(res.Fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return res
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "e164":
+		return "must be an international phone number such as +77011234567"
+	case "password":
+		return fmt.Sprintf("must be %d to %d characters and contain a letter and a digit", minPasswordLength, maxPasswordBytes)
+	case "signup_role":
+		return "must be one of: " + strings.Join(SignupRoles, ", ")
+	case "max":
+		return "must be at most " + fe.Param() + " characters"
+	default:
+		return "is invalid"
+	}
+}